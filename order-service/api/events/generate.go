@@ -0,0 +1,12 @@
+// Package events holds the JSON Schemas of the events order-service
+// publishes, generated from domain.EventCatalog.
+package events
+
+import "embed"
+
+//go:generate go run ../../cmd/eventschema -out .
+
+// Schemas contains every *.schema.json file in this directory.
+//
+//go:embed *.schema.json
+var Schemas embed.FS
@@ -0,0 +1,4 @@
+// Package orderv1 holds the generated protobuf and gRPC code for order.v1.
+package orderv1
+
+//go:generate protoc -I ../.. --go_out=../.. --go_opt=paths=source_relative --go-grpc_out=../.. --go-grpc_opt=paths=source_relative order/v1/order.proto
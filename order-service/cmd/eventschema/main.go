@@ -0,0 +1,33 @@
+// Command eventschema writes a JSON Schema file for every event in
+// domain.EventCatalog.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+
+	"order-service/internal/domain"
+	"order-service/internal/eventschema"
+)
+
+func main() {
+	out := flag.String("out", "api/events", "directory to write schema files to")
+	flag.Parse()
+
+	if err := os.MkdirAll(*out, 0o755); err != nil {
+		log.Fatal(err)
+	}
+	for _, e := range domain.EventCatalog {
+		data, err := eventschema.Generate(e)
+		if err != nil {
+			log.Fatal(err)
+		}
+		path := filepath.Join(*out, eventschema.FileName(e))
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("wrote %s", path)
+	}
+}
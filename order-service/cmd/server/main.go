@@ -3,13 +3,18 @@ package main
 import (
 	"context"
 	"log"
+	"net"
 	"os"
 	"runtime"
 	"strconv"
+	"strings"
 	"time"
 
+	"order-service/internal/auth"
+	grpcapi "order-service/internal/controllers/grpc"
 	"order-service/internal/controllers/http"
 	"order-service/internal/infra"
+	"order-service/internal/infra/messaging"
 	mmysql "order-service/internal/infra/mysql"
 	"order-service/internal/infra/rabbitmq"
 	mysqlrepo "order-service/internal/repository/mysql"
@@ -17,6 +22,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
+	"google.golang.org/grpc"
 )
 
 func getEnvInt(key string, defaultVal int) int {
@@ -37,7 +43,33 @@ func getEnvDuration(key string, defaultVal time.Duration) time.Duration {
 	return defaultVal
 }
 
+// newRabbitPublisher connects to RABBITMQ_URL with the envelopes chosen
+// by EVENT_ENCODINGS.
+func newRabbitPublisher() *rabbitmq.Publisher {
+	publisher, err := rabbitmq.NewPublisher(os.Getenv("RABBITMQ_URL"), "order.exchange")
+	if err != nil {
+		log.Fatalf("failed to init publisher: %v", err)
+	}
+	// Envelope per routing key, e.g. "order.created=cloudevents-binary,*=nestjs"
+	if spec := os.Getenv("EVENT_ENCODINGS"); spec != "" {
+		source := os.Getenv("EVENT_SOURCE")
+		if source == "" {
+			source = "/order-service"
+		}
+		encoders, err := rabbitmq.ParseEncoders(spec, source)
+		if err != nil {
+			log.Fatalf("EVENT_ENCODINGS: %v", err)
+		}
+		publisher.SetEncoders(encoders)
+	}
+	return publisher
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "replay-events" {
+		os.Exit(replayEvents(os.Args[2:]))
+	}
+
 	// Set optimal Go runtime settings
 	numCPU := runtime.NumCPU()
 	runtime.GOMAXPROCS(numCPU)
@@ -64,14 +96,69 @@ func main() {
 
 	// Product client with optimized timeouts
 	productTimeout := getEnvDuration("PRODUCT_CLIENT_TIMEOUT", 500*time.Millisecond)
-	productClient := infra.NewProductClient(os.Getenv("PRODUCT_SERVICE_URL"), productTimeout)
+	resilience := infra.DefaultResilienceConfig()
+	resilience.MaxRetries = getEnvInt("PRODUCT_CLIENT_MAX_RETRIES", resilience.MaxRetries)
+	resilience.BaseBackoff = getEnvDuration("PRODUCT_CLIENT_BASE_BACKOFF", resilience.BaseBackoff)
+	resilience.MaxBackoff = getEnvDuration("PRODUCT_CLIENT_MAX_BACKOFF", resilience.MaxBackoff)
+	resilience.Breaker.OpenTimeout = getEnvDuration("PRODUCT_BREAKER_OPEN_TIMEOUT", resilience.Breaker.OpenTimeout)
+	resilience.Breaker.SlowCallThreshold = getEnvDuration("PRODUCT_BREAKER_SLOW_CALL", resilience.Breaker.SlowCallThreshold)
+	resilience.HedgeEnabled = os.Getenv("PRODUCT_CLIENT_HEDGE") == "true"
+	resilience.HedgeMinDelay = getEnvDuration("PRODUCT_CLIENT_HEDGE_MIN_DELAY", resilience.HedgeMinDelay)
+	productClient := infra.NewResilientProductClient(
+		infra.NewProductClient(os.Getenv("PRODUCT_SERVICE_URL"), productTimeout),
+		resilience,
+		infra.RealClock(),
+	)
 
-	publisher, err := rabbitmq.NewPublisher(os.Getenv("RABBITMQ_URL"), "order.exchange")
-	if err != nil {
-		log.Fatalf("failed to init publisher: %v", err)
+	// Coalesce concurrent single lookups into batch calls
+	batching := infra.DefaultBatcherConfig()
+	batching.Window = getEnvDuration("PRODUCT_BATCH_WINDOW", batching.Window)
+	batching.MaxBatch = getEnvInt("PRODUCT_BATCH_MAX", batching.MaxBatch)
+	productBatcher := infra.NewProductBatcher(productClient, batching, infra.RealClock())
+
+	// MESSAGE_BROKER=memory runs without RabbitMQ; events then only reach
+	// subscribers inside this process
+	var broker *messaging.MemoryBroker
+	var pipeline *rabbitmq.Pipeline
+	var publisher messaging.Publisher
+	if os.Getenv("MESSAGE_BROKER") == "memory" {
+		log.Printf("Using in-memory message broker; events stay in this process")
+		broker = messaging.NewMemoryBroker(messaging.DefaultMemoryBrokerConfig())
+		publisher = broker
+	} else {
+		// Events are queued and published in confirmed batches off the
+		// request path
+		pipelineCfg := rabbitmq.DefaultPipelineConfig()
+		pipelineCfg.QueueSize = getEnvInt("PUBLISH_QUEUE_SIZE", pipelineCfg.QueueSize)
+		pipelineCfg.Workers = getEnvInt("PUBLISH_WORKERS", pipelineCfg.Workers)
+		pipelineCfg.BatchSize = getEnvInt("PUBLISH_BATCH_SIZE", pipelineCfg.BatchSize)
+		pipelineCfg.ConfirmTimeout = getEnvDuration("PUBLISH_CONFIRM_TIMEOUT", pipelineCfg.ConfirmTimeout)
+		pipelineCfg.MaxRetries = getEnvInt("PUBLISH_MAX_RETRIES", pipelineCfg.MaxRetries)
+		pipelineCfg.MaxRetryDelay = getEnvDuration("PUBLISH_MAX_RETRY_DELAY", pipelineCfg.MaxRetryDelay)
+		pipelineCfg.HighWatermark = float64(getEnvInt("PUBLISH_HIGH_WATERMARK_PCT", int(pipelineCfg.HighWatermark*100))) / 100
+		pipeline = rabbitmq.NewPipeline(newRabbitPublisher(), pipelineCfg)
+		publisher = pipeline
 	}
 
-	s := services.NewOrderService(repo, productClient, publisher)
+	s := services.NewOrderService(repo, productBatcher, publisher)
+	s.SetRefundRepository(mysqlrepo.NewRefundRepository(db))
+
+	// Adaptive admission control for order creation
+	limits := services.DefaultLimiterConfig()
+	limits.InitialLimit = getEnvInt("ORDER_LIMIT_INITIAL", numCPU*20)
+	limits.MinLimit = getEnvInt("ORDER_LIMIT_MIN", limits.MinLimit)
+	limits.MaxLimit = getEnvInt("ORDER_LIMIT_MAX", limits.MaxLimit)
+	limits.LatencyTarget = getEnvDuration("ORDER_LIMIT_LATENCY_TARGET", limits.LatencyTarget)
+	s.SetConcurrencyLimiter(services.NewAdaptiveLimiter(limits, infra.RealClock()))
+
+	// Optional write-behind group commit: concurrent creates share one INSERT transaction
+	if os.Getenv("ORDER_GROUP_COMMIT") == "true" {
+		groupCommit := services.DefaultGroupCommitConfig()
+		groupCommit.MaxBatch = getEnvInt("ORDER_GROUP_COMMIT_MAX_BATCH", groupCommit.MaxBatch)
+		groupCommit.Linger = getEnvDuration("ORDER_GROUP_COMMIT_LINGER", groupCommit.Linger)
+		s.EnableGroupCommit(groupCommit)
+		log.Printf("Group commit enabled: batch=%d linger=%v", groupCommit.MaxBatch, groupCommit.Linger)
+	}
 
 	// Redis with optimized connection pool
 	redisPoolSize := getEnvInt("REDIS_POOL_SIZE", numCPU*50)
@@ -103,18 +190,117 @@ func main() {
 
 	s.SetRedisClient(redisClient)
 
-	// Aggressive cache warmup
+	// Share order status changes across replicas for streaming clients
+	if err := s.EnableStatusFanout(context.Background()); err != nil {
+		log.Printf("Status fan-out disabled, streaming is local only: %v", err)
+	}
+
+	// Take payment for orders: authorize on create, capture once stock is
+	// reserved. Only the in-process fake provider exists so far.
+	var payments *infra.FakePaymentProvider
+	switch provider := os.Getenv("PAYMENT_PROVIDER"); provider {
+	case "":
+	case "fake":
+		payments = infra.NewFakePaymentProvider()
+		if limit := getEnvInt("PAYMENT_FAKE_DECLINE_ABOVE", 0); limit > 0 {
+			payments.Fail(infra.PaymentFailure{Op: infra.PaymentAuthorize, MinAmount: int64(limit) + 1, Err: infra.ErrPaymentDeclined})
+		}
+		s.SetPaymentProvider(payments)
+		log.Printf("Payments enabled: provider=%s", provider)
+	default:
+		log.Fatalf("PAYMENT_PROVIDER: unknown provider %q", provider)
+	}
+
+	// Optionally drive fulfilment as an orchestrated saga: reserve stock,
+	// charge payment, confirm, with compensation when a step fails
+	var sagas *services.SagaOrchestrator
+	if os.Getenv("ORDER_SAGA") == "true" {
+		// Without a provider the charge step sends payment.charge, which
+		// nothing in this deployment answers, so every saga would time out
+		if payments == nil {
+			log.Fatalf("ORDER_SAGA=true requires PAYMENT_PROVIDER")
+		}
+		sagaCfg := services.DefaultSagaConfig()
+		sagaCfg.StepTimeout = getEnvDuration("SAGA_STEP_TIMEOUT", sagaCfg.StepTimeout)
+		sagaCfg.MaxAttempts = getEnvInt("SAGA_MAX_ATTEMPTS", sagaCfg.MaxAttempts)
+		sagaCfg.ScanInterval = getEnvDuration("SAGA_SCAN_INTERVAL", sagaCfg.ScanInterval)
+		sagas = services.NewSagaOrchestrator(s, mysqlrepo.NewSagaRepository(db), publisher, sagaCfg, infra.RealClock())
+		s.SetSagaOrchestrator(sagas)
+		log.Printf("Saga orchestration enabled: step timeout=%v attempts=%d", sagaCfg.StepTimeout, sagaCfg.MaxAttempts)
+	}
+	// Notify partner endpoints of order status changes with signed,
+	// retried webhooks
+	var webhooks *services.WebhookService
+	if os.Getenv("WEBHOOKS_ENABLED") == "true" {
+		webhookCfg := services.DefaultWebhookConfig()
+		webhookCfg.PollInterval = getEnvDuration("WEBHOOK_POLL_INTERVAL", webhookCfg.PollInterval)
+		webhookCfg.Timeout = getEnvDuration("WEBHOOK_TIMEOUT", webhookCfg.Timeout)
+		webhookCfg.MaxAttempts = getEnvInt("WEBHOOK_MAX_ATTEMPTS", webhookCfg.MaxAttempts)
+		webhookCfg.BaseDelay = getEnvDuration("WEBHOOK_BASE_DELAY", webhookCfg.BaseDelay)
+		webhookCfg.MaxDelay = getEnvDuration("WEBHOOK_MAX_DELAY", webhookCfg.MaxDelay)
+		webhookCfg.DisableAfter = getEnvInt("WEBHOOK_DISABLE_AFTER", webhookCfg.DisableAfter)
+		webhookCfg.AllowPrivateNetworks = os.Getenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS") == "true"
+		webhooks = services.NewWebhookService(mysqlrepo.NewWebhookRepository(db), nil, webhookCfg, infra.RealClock())
+		s.SetWebhookService(webhooks)
+		go webhooks.Run(context.Background())
+		log.Printf("Webhooks enabled: attempts=%d disable after=%d failures", webhookCfg.MaxAttempts, webhookCfg.DisableAfter)
+	}
+	registerHandlers := func(sub messaging.Subscriber) {
+		if sagas != nil {
+			sagas.RegisterHandlers(sub)
+		} else {
+			s.RegisterEventHandlers(sub)
+		}
+	}
+
+	// Apply stock confirmations/failures published by the product service
+	var consumer *rabbitmq.Consumer
+	if broker != nil {
+		registerHandlers(broker)
+	} else {
+		statusQueue := os.Getenv("ORDER_STATUS_QUEUE")
+		if statusQueue == "" {
+			statusQueue = "product_queue"
+		}
+		retry := rabbitmq.DefaultRetryPolicy()
+		retry.MaxRetries = getEnvInt("CONSUMER_MAX_RETRIES", retry.MaxRetries)
+		retry.BaseDelay = getEnvDuration("CONSUMER_RETRY_BASE_DELAY", retry.BaseDelay)
+		retry.MaxDelay = getEnvDuration("CONSUMER_RETRY_MAX_DELAY", retry.MaxDelay)
+		consumer, err = rabbitmq.NewConsumer(os.Getenv("RABBITMQ_URL"), statusQueue, false, getEnvInt("CONSUMER_PREFETCH", 50), retry)
+		if err != nil {
+			log.Fatalf("failed to init consumer: %v", err)
+		}
+		registerHandlers(consumer)
+		go func() {
+			if err := consumer.Start(context.Background()); err != nil {
+				log.Fatalf("consumer stopped: %v", err)
+			}
+		}()
+	}
+	if sagas != nil {
+		// Also resumes sagas interrupted by the last shutdown
+		go sagas.Run(context.Background())
+	}
+
+	// Forget consumed message ids once redelivery is no longer plausible
+	inboxCfg := services.DefaultInboxPrunerConfig()
+	inboxCfg.Retention = getEnvDuration("INBOX_RETENTION", inboxCfg.Retention)
+	inboxCfg.Interval = getEnvDuration("INBOX_PRUNE_INTERVAL", inboxCfg.Interval)
+	inboxPruner := services.NewInboxPruner(mysqlrepo.NewInboxRepository(db), inboxCfg, infra.RealClock())
+	go inboxPruner.Run(context.Background())
+
+	// Warm the cache with the most-ordered products
 	go func() {
 		time.Sleep(2 * time.Second) // Reduced warmup delay
-		warmupProducts := []uint64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10} // More products
+		warmupLimit := getEnvInt("CACHE_WARMUP_TOP_PRODUCTS", 50)
 		
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 		
-		if err := s.WarmupProductCache(ctx, warmupProducts); err != nil {
+		if n, err := s.WarmupPopularProducts(ctx, warmupLimit); err != nil {
 			log.Printf("Failed to warm up cache: %v", err)
 		} else {
-			log.Println("Cache warmed up successfully")
+			log.Printf("Cache warmed up successfully with %d products", n)
 		}
 	}()
 
@@ -129,12 +315,69 @@ func main() {
 		}
 	}()
 
+	// Authentication: hashed static API keys and/or JWTs checked against a local JWKS
+	var authn auth.Chain
+	if path := os.Getenv("AUTH_API_KEYS_FILE"); path != "" {
+		keys, err := auth.LoadAPIKeys(path)
+		if err != nil {
+			log.Fatalf("auth: %v", err)
+		}
+		authn = append(authn, keys)
+	}
+	if path := os.Getenv("AUTH_JWKS_FILE"); path != "" {
+		jwts, err := auth.LoadJWKS(path, auth.JWTConfig{
+			Issuer:   os.Getenv("AUTH_JWT_ISSUER"),
+			Audience: os.Getenv("AUTH_JWT_AUDIENCE"),
+			Leeway:   getEnvDuration("AUTH_JWT_LEEWAY", 30*time.Second),
+		})
+		if err != nil {
+			log.Fatalf("auth: %v", err)
+		}
+		authn = append(authn, jwts)
+	}
+	authRequired := len(authn) > 0 && os.Getenv("AUTH_REQUIRED") != "false"
+	if len(authn) == 0 {
+		log.Printf("Authentication disabled: set AUTH_API_KEYS_FILE and/or AUTH_JWKS_FILE")
+	}
+
+	// gRPC API on its own port, sharing the same OrderService
+	var grpcOpts []grpc.ServerOption
+	if len(authn) > 0 {
+		grpcOpts = grpcapi.AuthOptions(authn, authRequired)
+	}
+	grpcMetrics := grpcapi.NewMetrics()
+	grpcServer := grpcapi.NewGRPCServer(s, grpcMetrics, grpcOpts...)
+	grpcPort := os.Getenv("GRPC_PORT")
+	if grpcPort == "" {
+		grpcPort = "9090"
+	}
+	go func() {
+		lis, err := net.Listen("tcp", ":"+grpcPort)
+		if err != nil {
+			log.Fatalf("grpc listen: %v", err)
+		}
+		log.Printf("Starting gRPC server on port %s", grpcPort)
+		if err := grpcServer.Serve(lis); err != nil {
+			log.Fatalf("grpc serve: %v", err)
+		}
+	}()
+
 	handler := http.NewHandler(s, redisClient)
 
 	// Optimize Gin for production
 	gin.SetMode(gin.ReleaseMode)
 	r := gin.New()
 	
+	// Client IPs, which rate limits are keyed on, are only taken from
+	// X-Forwarded-For when the request came through a trusted proxy
+	var trustedProxies []string
+	if v := os.Getenv("TRUSTED_PROXIES"); v != "" {
+		trustedProxies = strings.Split(strings.ReplaceAll(v, " ", ""), ",")
+	}
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		log.Fatalf("TRUSTED_PROXIES: %v", err)
+	}
+
 	// Use optimized middleware
 	r.Use(gin.Recovery())
 	r.Use(func(c *gin.Context) {
@@ -143,18 +386,93 @@ func main() {
 		c.Header("X-Frame-Options", "DENY")
 		c.Next()
 	})
-	
-	handler.RegisterRoutes(r)
 
-	// Health check endpoint
+	// Per-client rate limits, shared across replicas through Redis
+	routeLimits := http.RouteLimits{}
+	for route, env := range map[string]string{
+		"POST /orders": "RATE_LIMIT_CREATE_ORDER",
+		"*":            "RATE_LIMIT_DEFAULT",
+	} {
+		spec := os.Getenv(env)
+		if spec == "" && route == "POST /orders" {
+			spec = "1000/1s:2000"
+		}
+		if spec == "" {
+			continue
+		}
+		rl, err := infra.ParseRateLimit(spec)
+		if err != nil {
+			log.Fatalf("%s: %v", env, err)
+		}
+		routeLimits[route] = rl
+	}
+	rateLimiter := infra.NewFallbackRateLimiter(
+		infra.NewRedisRateLimiter(redisClient, getEnvDuration("RATE_LIMIT_REDIS_TIMEOUT", 50*time.Millisecond)),
+		infra.NewMemoryRateLimiter(infra.RealClock()),
+	)
+
+	// Health check endpoint, registered before auth so probes need no credentials
 	r.GET("/health", func(c *gin.Context) {
 		stats := s.GetServiceStats()
-		c.JSON(200, gin.H{
-			"status": "healthy",
-			"stats":  stats,
-		})
+		health := gin.H{
+			"status":         "healthy",
+			"stats":          stats,
+			"product_client": productClient.GetStats(),
+			"product_batch":  productBatcher.GetStats(),
+			"grpc":           grpcMetrics.GetStats(),
+			"rate_limit":     rateLimiter.GetStats(),
+			"inbox":          inboxPruner.GetStats(),
+		}
+		if consumer != nil {
+			health["consumer"] = consumer.GetStats()
+		}
+		if pipeline != nil {
+			health["publisher"] = pipeline.GetStats()
+		}
+		if broker != nil {
+			health["broker"] = broker.GetStats()
+		}
+		if sagas != nil {
+			health["saga"] = sagas.GetStats()
+		}
+		if payments != nil {
+			health["payments"] = payments.GetStats()
+		}
+		if webhooks != nil {
+			health["webhooks"] = webhooks.GetStats()
+		}
+		c.JSON(200, health)
 	})
 
+	// Limit before authenticating, so guessing credentials is limited too
+	r.Use(http.RateLimit(rateLimiter, routeLimits))
+	if len(authn) > 0 {
+		r.Use(http.Authenticate(authn, authRequired))
+	}
+
+	handler.RegisterRoutes(r)
+	if webhooks != nil {
+		http.NewWebhookHandler(webhooks).RegisterRoutes(r)
+	}
+
+	// Maintenance endpoints, gated by RBAC and audited
+	policy := http.DefaultPolicy()
+	if path := os.Getenv("RBAC_CONFIG_FILE"); path != "" {
+		if policy, err = http.LoadPolicy(path); err != nil {
+			log.Fatalf("rbac: %v", err)
+		}
+	}
+	auditLog := services.NewAuditLog(mysqlrepo.NewAuditRepository(db))
+	admin := http.NewAdminHandler(s, auditLog, policy)
+	if consumer != nil {
+		admin.SetDeadLetters(consumer)
+	}
+	admin.SetReplayer(services.NewEventReplayer(repo, publisher, infra.RealClock()))
+	if sagas != nil {
+		admin.SetSagas(sagas)
+	}
+	admin.RegisterRoutes(r)
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
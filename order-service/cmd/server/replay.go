@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"order-service/internal/domain"
+	"order-service/internal/infra"
+	mmysql "order-service/internal/infra/mysql"
+	"order-service/internal/repository"
+	mysqlrepo "order-service/internal/repository/mysql"
+	"order-service/internal/services"
+)
+
+// replayEvents implements "order-service replay-events [flags]": it
+// re-publishes the events of the selected orders to RabbitMQ, logging
+// progress after every page, and prints the final report as JSON. It
+// returns the process exit code.
+func replayEvents(args []string) int {
+	fs := flag.NewFlagSet("replay-events", flag.ContinueOnError)
+	fromID := fs.Uint64("from-id", 0, "first order id (inclusive)")
+	toID := fs.Uint64("to-id", 0, "last order id (inclusive)")
+	statuses := fs.String("status", "", "comma-separated order statuses")
+	productID := fs.Uint64("product-id", 0, "only orders for this product")
+	createdFrom := fs.String("created-from", "", "only orders created at or after this RFC 3339 time")
+	createdTo := fs.String("created-to", "", "only orders created before this RFC 3339 time")
+	events := fs.String("events", domain.EventOrderCreated, "comma-separated event types to publish")
+	rate := fs.Float64("rate", services.DefaultReplayRate, "maximum events published per second")
+	maxOrders := fs.Int("max-orders", 0, "stop after this many orders (0 = no limit)")
+	dryRun := fs.Bool("dry-run", false, "report what would be published without publishing")
+	force := fs.Bool("force", false, "also publish order.created for orders that are no longer pending")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	req := services.ReplayRequest{
+		Filter: repository.ReplayFilter{
+			FromID:    *fromID,
+			ToID:      *toID,
+			ProductID: *productID,
+		},
+		Events:    splitList(*events),
+		Rate:      *rate,
+		MaxOrders: *maxOrders,
+		DryRun:    *dryRun,
+		Force:     *force,
+	}
+	for _, s := range splitList(*statuses) {
+		req.Filter.Statuses = append(req.Filter.Statuses, domain.OrderStatus(s))
+	}
+	for _, tf := range []struct {
+		flag, value string
+		dst         *time.Time
+	}{
+		{"created-from", *createdFrom, &req.Filter.CreatedFrom},
+		{"created-to", *createdTo, &req.Filter.CreatedTo},
+	} {
+		if tf.value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, tf.value)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "-%s: %v\n", tf.flag, err)
+			return 2
+		}
+		*tf.dst = t
+	}
+
+	db, err := mmysql.NewMySQLFromEnv()
+	if err != nil {
+		log.Printf("db: connect: %v", err)
+		return 1
+	}
+	var replayer *services.EventReplayer
+	if *dryRun {
+		// Nothing is published, so do not require a broker
+		replayer = services.NewEventReplayer(mysqlrepo.NewOrderRepository(db), nil, infra.RealClock())
+	} else {
+		replayer = services.NewEventReplayer(mysqlrepo.NewOrderRepository(db), newRabbitPublisher(), infra.RealClock())
+	}
+
+	// Ctrl-C stops after the current publish and still prints the report
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	rep, err := replayer.Replay(ctx, req, func(r services.ReplayReport) {
+		log.Printf("replay progress: matched=%d published=%d skipped=%d failed=%d last_order_id=%d",
+			r.Matched, r.Published, r.Skipped, r.Failed, r.LastOrderID)
+	})
+	out, _ := json.MarshalIndent(rep, "", "  ")
+	fmt.Println(string(out))
+	if err != nil {
+		log.Printf("replay-events: %v", err)
+		if !rep.Complete && rep.LastOrderID > 0 {
+			log.Printf("resume with -from-id %d", rep.LastOrderID+1)
+		}
+		return 1
+	}
+	return 0
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeyAuthenticator(t *testing.T) {
+	a, err := NewAPIKeyAuthenticator([]APIKey{
+		{ID: "checkout", Hash: HashAPIKey("s3cret"), Roles: []string{"orders:write"}},
+	})
+	require.NoError(t, err)
+
+	p, err := a.Authenticate(context.Background(), Credentials{APIKey: "s3cret"})
+	require.NoError(t, err)
+	assert.Equal(t, "api_key:checkout", p.ID())
+	assert.True(t, p.HasRole("orders:write"))
+
+	_, err = a.Authenticate(context.Background(), Credentials{APIKey: "guess"})
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	_, err = a.Authenticate(context.Background(), Credentials{})
+	assert.ErrorIs(t, err, ErrNoCredentials)
+
+	_, err = NewAPIKeyAuthenticator([]APIKey{{ID: "plain", Hash: "s3cret"}})
+	assert.Error(t, err, "clear-text keys are refused")
+}
+
+func testJWKS(t *testing.T, kid string, pub *rsa.PublicKey) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}}})
+	require.NoError(t, err)
+	return data
+}
+
+func signToken(t *testing.T, key *rsa.PrivateKey, kid string, c jwt.MapClaims) string {
+	t.Helper()
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, c)
+	tok.Header["kid"] = kid
+	s, err := tok.SignedString(key)
+	require.NoError(t, err)
+	return s
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	a, err := NewJWTAuthenticator(testJWKS(t, "k1", &key.PublicKey), JWTConfig{Issuer: "https://idp.test", Audience: "orders"})
+	require.NoError(t, err)
+
+	valid := jwt.MapClaims{
+		"sub":   "42",
+		"iss":   "https://idp.test",
+		"aud":   "orders",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"roles": []string{"customer"},
+	}
+	p, err := a.Authenticate(context.Background(), Credentials{BearerToken: signToken(t, key, "k1", valid)})
+	require.NoError(t, err)
+	assert.Equal(t, "jwt:42", p.ID())
+	assert.Equal(t, []string{"customer"}, p.Roles)
+
+	tests := map[string]string{
+		"wrong signer": signToken(t, other, "k1", valid),
+		"unknown kid":  signToken(t, key, "k2", valid),
+		"expired":      signToken(t, key, "k1", jwt.MapClaims{"sub": "42", "iss": "https://idp.test", "aud": "orders", "exp": time.Now().Add(-time.Hour).Unix()}),
+		"no expiry":    signToken(t, key, "k1", jwt.MapClaims{"sub": "42", "iss": "https://idp.test", "aud": "orders"}),
+		"wrong issuer": signToken(t, key, "k1", jwt.MapClaims{"sub": "42", "iss": "https://evil.test", "aud": "orders", "exp": time.Now().Add(time.Hour).Unix()}),
+		"garbage":      "not.a.token",
+	}
+	for name, token := range tests {
+		_, err := a.Authenticate(context.Background(), Credentials{BearerToken: token})
+		assert.ErrorIs(t, err, ErrInvalidCredentials, name)
+	}
+}
+
+func TestChain(t *testing.T) {
+	keys, err := NewAPIKeyAuthenticator([]APIKey{{ID: "svc", Hash: HashAPIKey("k")}})
+	require.NoError(t, err)
+	ch := Chain{keys}
+
+	p, err := ch.Authenticate(context.Background(), Credentials{APIKey: "k"})
+	require.NoError(t, err)
+	assert.Equal(t, "svc", p.Subject)
+
+	_, err = ch.Authenticate(context.Background(), Credentials{BearerToken: "abc"})
+	assert.ErrorIs(t, err, ErrNoCredentials, "no authenticator in the chain handles bearer tokens")
+}
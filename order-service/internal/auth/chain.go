@@ -0,0 +1,21 @@
+package auth
+
+import (
+	"context"
+	"errors"
+)
+
+// Chain tries each authenticator in turn. The first one that recognises the
+// credentials decides the outcome.
+type Chain []Authenticator
+
+func (ch Chain) Authenticate(ctx context.Context, creds Credentials) (*Principal, error) {
+	for _, a := range ch {
+		p, err := a.Authenticate(ctx, creds)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return p, err
+	}
+	return nil, ErrNoCredentials
+}
@@ -0,0 +1,16 @@
+package auth
+
+import "context"
+
+type Authenticator interface {
+	// Authenticate returns ErrNoCredentials when creds hold nothing it
+	// handles, and an error wrapping ErrInvalidCredentials when it rejects
+	// them.
+	Authenticate(ctx context.Context, creds Credentials) (*Principal, error)
+}
+
+var (
+	_ Authenticator = (*APIKeyAuthenticator)(nil)
+	_ Authenticator = (*JWTAuthenticator)(nil)
+	_ Authenticator = Chain(nil)
+)
@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type JWTConfig struct {
+	Issuer   string        // required "iss" when set
+	Audience string        // required "aud" when set
+	Leeway   time.Duration // clock skew tolerated on exp/nbf
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// claims are the registered claims plus the custom claims issued by our
+// IdP. Tokens without customer_id belong to the customer named by sub.
+type claims struct {
+	jwt.RegisteredClaims
+	Roles      []string `json:"roles"`
+	CustomerID string   `json:"customer_id"`
+}
+
+// JWTAuthenticator validates bearer tokens signed by one of the keys in a
+// local JWKS file (RSA and P-256/P-384 EC keys).
+type JWTAuthenticator struct {
+	cfg  JWTConfig
+	keys map[string]crypto.PublicKey // by kid
+}
+
+func NewJWTAuthenticator(jwks []byte, cfg JWTConfig) (*JWTAuthenticator, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(jwks, &set); err != nil {
+		return nil, fmt.Errorf("parse jwks: %w", err)
+	}
+	a := &JWTAuthenticator{cfg: cfg, keys: make(map[string]crypto.PublicKey, len(set.Keys))}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("jwks key %q: %w", k.Kid, err)
+		}
+		a.keys[k.Kid] = pub
+	}
+	if len(a.keys) == 0 {
+		return nil, errors.New("jwks contains no signing keys")
+	}
+	return a, nil
+}
+
+// LoadJWKS reads the key set from path.
+func LoadJWKS(path string, cfg JWTConfig) (*JWTAuthenticator, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read jwks: %w", err)
+	}
+	return NewJWTAuthenticator(data, cfg)
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+func (a *JWTAuthenticator) Authenticate(ctx context.Context, creds Credentials) (*Principal, error) {
+	if creds.BearerToken == "" {
+		return nil, ErrNoCredentials
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(a.cfg.Leeway),
+	}
+	if a.cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(a.cfg.Issuer))
+	}
+	if a.cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(a.cfg.Audience))
+	}
+
+	var c claims
+	_, err := jwt.ParseWithClaims(creds.BearerToken, &c, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, ok := a.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+		return key, nil
+	}, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+	}
+	if c.Subject == "" {
+		return nil, fmt.Errorf("%w: token has no subject", ErrInvalidCredentials)
+	}
+	customer := c.CustomerID
+	if customer == "" {
+		customer = c.Subject
+	}
+	return &Principal{Subject: c.Subject, Method: MethodJWT, Roles: c.Roles, CustomerID: customer}, nil
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"context"
+	"errors"
+)
+
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+// RoleAdmin may act on any customer's orders.
+const RoleAdmin = "admin"
+
+var (
+	// ErrNoCredentials means the request carried nothing this
+	// authenticator understands; another one may still accept it.
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials means credentials were presented but rejected.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Principal is the authenticated caller.
+type Principal struct {
+	Subject string   // API key id or JWT "sub"
+	Method  string   // MethodAPIKey or MethodJWT
+	Roles   []string // as configured for the key or carried in the token
+
+	// CustomerID is set for principals acting as a customer. Service
+	// accounts leave it empty.
+	CustomerID string
+}
+
+// ID identifies the principal across authentication methods, e.g.
+// "api_key:checkout" or "jwt:42".
+func (p *Principal) ID() string {
+	return p.Method + ":" + p.Subject
+}
+
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Credentials are the raw secrets extracted from a request by a transport.
+type Credentials struct {
+	APIKey      string
+	BearerToken string
+}
+
+type principalKey struct{}
+
+type anonymousKey struct{}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// WithAnonymous marks a request that reached a transport with
+// authentication configured but carried no credentials. Unlike internal
+// callers, which have no principal either, anonymous callers are
+// restricted.
+func WithAnonymous(ctx context.Context) context.Context {
+	return context.WithValue(ctx, anonymousKey{}, true)
+}
+
+// IsAnonymous reports whether ctx was marked by WithAnonymous.
+func IsAnonymous(ctx context.Context) bool {
+	anon, _ := ctx.Value(anonymousKey{}).(bool)
+	return anon
+}
+
+// CustomerScope returns the customer the caller is restricted to. Internal
+// callers, service accounts and admins are not restricted. Anonymous
+// callers get the empty scope, which matches no customer, so they only see
+// orders nobody owns.
+func CustomerScope(ctx context.Context) (string, bool) {
+	p, ok := FromContext(ctx)
+	if !ok {
+		return "", IsAnonymous(ctx)
+	}
+	if p.CustomerID == "" || p.HasRole(RoleAdmin) {
+		return "", false
+	}
+	return p.CustomerID, true
+}
+
+// FromContext returns the caller attached by the auth middleware, if any.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
@@ -0,0 +1,70 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"order-service/internal/auth"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// AuthOptions authenticates every call from the "x-api-key" or
+// "authorization: Bearer" metadata, mirroring the REST middleware.
+func AuthOptions(a auth.Authenticator, required bool) []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			ctx, err := authenticate(ctx, a, required)
+			if err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			ctx, err := authenticate(ss.Context(), a, required)
+			if err != nil {
+				return err
+			}
+			return handler(srv, &authedStream{ServerStream: ss, ctx: ctx})
+		}),
+	}
+}
+
+func authenticate(ctx context.Context, a auth.Authenticator, required bool) (context.Context, error) {
+	var creds auth.Credentials
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get("x-api-key"); len(v) > 0 {
+			creds.APIKey = v[0]
+		}
+		if v := md.Get("authorization"); len(v) > 0 {
+			if scheme, token, ok := strings.Cut(v[0], " "); ok && strings.EqualFold(scheme, "Bearer") {
+				creds.BearerToken = strings.TrimSpace(token)
+			}
+		}
+	}
+
+	p, err := a.Authenticate(ctx, creds)
+	switch {
+	case err == nil:
+		return auth.WithPrincipal(ctx, p), nil
+	case errors.Is(err, auth.ErrNoCredentials) && !required:
+		return auth.WithAnonymous(ctx), nil
+	case errors.Is(err, auth.ErrNoCredentials):
+		return nil, status.Error(codes.Unauthenticated, "credentials required")
+	default:
+		return nil, status.Error(codes.Unauthenticated, "invalid credentials")
+	}
+}
+
+type authedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authedStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,64 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"order-service/internal/services"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// codeForKind mirrors the HTTP mapping in controllers/http.
+func codeForKind(kind services.ErrorKind) codes.Code {
+	switch kind {
+	case services.KindNotFound:
+		return codes.NotFound
+	case services.KindValidation:
+		return codes.InvalidArgument
+	case services.KindConflict:
+		return codes.FailedPrecondition
+	case services.KindUnauthenticated:
+		return codes.Unauthenticated
+	case services.KindForbidden:
+		return codes.PermissionDenied
+	case services.KindPreconditionFailed:
+		return codes.Aborted
+	case services.KindRateLimited, services.KindOverloaded:
+		return codes.ResourceExhausted
+	case services.KindUnavailable:
+		return codes.Unavailable
+	case services.KindTimeout:
+		return codes.DeadlineExceeded
+	default:
+		return codes.Internal
+	}
+}
+
+// toStatus maps service errors to gRPC status codes. Only client-facing
+// messages are returned; dependency failures are logged.
+func toStatus(err error) error {
+	if errors.Is(err, context.Canceled) {
+		return status.Error(codes.Canceled, "request cancelled")
+	}
+
+	kind := services.KindOf(err)
+	code := codeForKind(kind)
+
+	msg := "internal error"
+	if e, ok := services.AsError(err); ok {
+		msg = e.Message
+		if kind == services.KindValidation || kind == services.KindConflict {
+			msg = err.Error()
+		}
+	} else if kind == services.KindTimeout {
+		msg = "deadline exceeded"
+	}
+
+	if code == codes.Internal || code == codes.Unavailable {
+		log.Printf("grpc: %v", err)
+	}
+	return status.Error(code, msg)
+}
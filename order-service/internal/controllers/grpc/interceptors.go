@@ -0,0 +1,139 @@
+package grpc
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func RecoveryUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("grpc: panic in %s: %v", info.FullMethod, r)
+				err = status.Error(codes.Internal, "internal error")
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+func RecoveryStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("grpc: panic in %s: %v", info.FullMethod, r)
+				err = status.Error(codes.Internal, "internal error")
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+func LoggingUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logCall(info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+func LoggingStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		logCall(info.FullMethod, start, err)
+		return err
+	}
+}
+
+func logCall(method string, start time.Time, err error) {
+	elapsed := time.Since(start)
+	code := status.Code(err)
+	if code != codes.OK || elapsed > 500*time.Millisecond {
+		log.Printf("grpc: %s code=%s duration=%v", method, code, elapsed)
+	}
+}
+
+type methodStats struct {
+	Calls     int64
+	Errors    int64
+	TotalTime time.Duration
+	ByCode    map[string]int64
+}
+
+// Metrics collects per-method call counts, error counts and latency.
+type Metrics struct {
+	mu      sync.RWMutex
+	methods map[string]*methodStats
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{methods: make(map[string]*methodStats)}
+}
+
+func (m *Metrics) observe(method string, elapsed time.Duration, err error) {
+	code := status.Code(err)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ms, ok := m.methods[method]
+	if !ok {
+		ms = &methodStats{ByCode: make(map[string]int64)}
+		m.methods[method] = ms
+	}
+	ms.Calls++
+	ms.TotalTime += elapsed
+	ms.ByCode[code.String()]++
+	if code != codes.OK {
+		ms.Errors++
+	}
+}
+
+func (m *Metrics) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		m.observe(info.FullMethod, time.Since(start), err)
+		return resp, err
+	}
+}
+
+func (m *Metrics) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		m.observe(info.FullMethod, time.Since(start), err)
+		return err
+	}
+}
+
+func (m *Metrics) GetStats() map[string]interface{} {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	out := make(map[string]interface{}, len(m.methods))
+	for method, ms := range m.methods {
+		avg := float64(0)
+		if ms.Calls > 0 {
+			avg = float64(ms.TotalTime) / float64(ms.Calls) / float64(time.Millisecond)
+		}
+		codes := make(map[string]int64, len(ms.ByCode))
+		for k, v := range ms.ByCode {
+			codes[k] = v
+		}
+		out[method] = map[string]interface{}{
+			"calls":          ms.Calls,
+			"errors":         ms.Errors,
+			"avg_latency_ms": avg,
+			"codes":          codes,
+		}
+	}
+	return out
+}
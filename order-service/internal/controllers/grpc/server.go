@@ -0,0 +1,128 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	orderv1 "order-service/api/order/v1"
+	"order-service/internal/domain"
+	"order-service/internal/services"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server implements orderv1.OrderServiceServer on top of the same
+// OrderService used by the REST handlers.
+type Server struct {
+	orderv1.UnimplementedOrderServiceServer
+	service *services.OrderService
+}
+
+func NewServer(s *services.OrderService) *Server {
+	return &Server{service: s}
+}
+
+// NewGRPCServer builds a grpc.Server with the shared interceptors and the
+// order service registered. Extra options, such as AuthOptions, run after
+// the shared interceptors.
+func NewGRPCServer(s *services.OrderService, metrics *Metrics, extra ...grpc.ServerOption) *grpc.Server {
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(RecoveryUnaryInterceptor(), LoggingUnaryInterceptor(), metrics.UnaryInterceptor()),
+		grpc.ChainStreamInterceptor(RecoveryStreamInterceptor(), LoggingStreamInterceptor(), metrics.StreamInterceptor()),
+	}
+	srv := grpc.NewServer(append(opts, extra...)...)
+	orderv1.RegisterOrderServiceServer(srv, NewServer(s))
+	return srv
+}
+
+func (s *Server) CreateOrder(ctx context.Context, req *orderv1.CreateOrderRequest) (*orderv1.Order, error) {
+	if req.GetProductId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "product_id is required")
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get("x-request-priority"); len(v) > 0 {
+			ctx = services.WithPriority(ctx, services.RequestedPriority(ctx, v[0]))
+		}
+	}
+	o, err := s.service.CreateOrder(ctx, req.GetProductId(), req.GetTotalPrice())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toProto(o), nil
+}
+
+func (s *Server) GetOrder(ctx context.Context, req *orderv1.GetOrderRequest) (*orderv1.Order, error) {
+	o, err := s.service.GetOrderById(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toProto(o), nil
+}
+
+func (s *Server) ListOrdersByProduct(ctx context.Context, req *orderv1.ListOrdersByProductRequest) (*orderv1.ListOrdersByProductResponse, error) {
+	orders, err := s.service.GetOrderByProductId(ctx, req.GetProductId())
+	if err != nil && !errors.Is(err, services.ErrOrderNotFound) {
+		return nil, toStatus(err)
+	}
+	resp := &orderv1.ListOrdersByProductResponse{Orders: make([]*orderv1.Order, 0, len(orders))}
+	for i := range orders {
+		resp.Orders = append(resp.Orders, toProto(&orders[i]))
+	}
+	return resp, nil
+}
+
+func (s *Server) CancelOrder(ctx context.Context, req *orderv1.CancelOrderRequest) (*orderv1.Order, error) {
+	o, err := s.service.CancelOrder(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toProto(o), nil
+}
+
+func (s *Server) WatchOrder(req *orderv1.WatchOrderRequest, stream grpc.ServerStreamingServer[orderv1.Order]) error {
+	updates, err := s.service.WatchOrder(stream.Context(), req.GetId())
+	if err != nil {
+		return toStatus(err)
+	}
+	for o := range updates {
+		if err := stream.Send(toProto(&o)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func toProto(o *domain.Order) *orderv1.Order {
+	return &orderv1.Order{
+		Id:         o.ID,
+		ProductId:  o.ProductId,
+		TotalPrice: o.TotalPrice,
+		Status:     toProtoStatus(o.Status),
+		CreatedAt:  timestamppb.New(o.CreatedAt),
+	}
+}
+
+func toProtoStatus(s domain.OrderStatus) orderv1.OrderStatus {
+	switch s {
+	case domain.StatusPending:
+		return orderv1.OrderStatus_ORDER_STATUS_PENDING
+	case domain.StatusConfirmed:
+		return orderv1.OrderStatus_ORDER_STATUS_CONFIRMED
+	case domain.StatusFailed:
+		return orderv1.OrderStatus_ORDER_STATUS_FAILED
+	case domain.StatusCancelled:
+		return orderv1.OrderStatus_ORDER_STATUS_CANCELLED
+	case domain.StatusPaid:
+		return orderv1.OrderStatus_ORDER_STATUS_PAID
+	case domain.StatusRefunded:
+		return orderv1.OrderStatus_ORDER_STATUS_REFUNDED
+	case domain.StatusPartiallyRefunded:
+		return orderv1.OrderStatus_ORDER_STATUS_PARTIALLY_REFUNDED
+	default:
+		return orderv1.OrderStatus_ORDER_STATUS_UNSPECIFIED
+	}
+}
@@ -0,0 +1,114 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	orderv1 "order-service/api/order/v1"
+	"order-service/internal/domain"
+	"order-service/internal/infra"
+	"order-service/internal/mocks"
+	"order-service/internal/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestClient(t *testing.T, repo *mocks.MockOrderRepository, prod *mocks.MockProductClient, pub *mocks.MockPublisher) (orderv1.OrderServiceClient, *Metrics) {
+	t.Helper()
+
+	metrics := NewMetrics()
+	srv := NewGRPCServer(services.NewOrderService(repo, prod, pub), metrics)
+	lis := bufconn.Listen(1 << 20)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return orderv1.NewOrderServiceClient(conn), metrics
+}
+
+func TestServer_CreateAndGetOrder(t *testing.T) {
+	repo := new(mocks.MockOrderRepository)
+	prod := new(mocks.MockProductClient)
+	pub := new(mocks.MockPublisher)
+
+	prod.On("GetProductById", mock.Anything, uint64(1)).Return(&infra.ProductInfo{ID: 1, Qty: 5}, nil)
+	repo.On("Save", mock.AnythingOfType("*domain.Order")).Return(nil).Run(func(args mock.Arguments) {
+		args.Get(0).(*domain.Order).ID = 42
+	})
+	pub.On("Publish", mock.Anything, "order.created", mock.Anything).Return(nil).Maybe()
+	repo.On("FindByID", uint64(42)).Return(&domain.Order{ID: 42, ProductId: 1, TotalPrice: 1000, Status: domain.StatusConfirmed, CreatedAt: time.Now()}, nil)
+
+	client, metrics := newTestClient(t, repo, prod, pub)
+	ctx := context.Background()
+
+	created, err := client.CreateOrder(ctx, &orderv1.CreateOrderRequest{ProductId: 1, TotalPrice: 1000})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(42), created.Id)
+	assert.Equal(t, orderv1.OrderStatus_ORDER_STATUS_PENDING, created.Status)
+
+	got, err := client.GetOrder(ctx, &orderv1.GetOrderRequest{Id: 42})
+	require.NoError(t, err)
+	assert.Equal(t, orderv1.OrderStatus_ORDER_STATUS_CONFIRMED, got.Status)
+
+	stats := metrics.GetStats()
+	assert.Contains(t, stats, orderv1.OrderService_CreateOrder_FullMethodName)
+	assert.Contains(t, stats, orderv1.OrderService_GetOrder_FullMethodName)
+}
+
+func TestServer_ErrorMapping(t *testing.T) {
+	repo := new(mocks.MockOrderRepository)
+	prod := new(mocks.MockProductClient)
+	pub := new(mocks.MockPublisher)
+
+	repo.On("FindByID", uint64(7)).Return(nil, nil)
+	repo.On("FindByID", uint64(8)).Return(&domain.Order{ID: 8, Status: domain.StatusConfirmed}, nil)
+	repo.On("FindByID", uint64(9)).Return(nil, errors.New("dial tcp 10.0.0.3:3306: i/o timeout"))
+
+	client, _ := newTestClient(t, repo, prod, pub)
+	ctx := context.Background()
+
+	_, err := client.GetOrder(ctx, &orderv1.GetOrderRequest{Id: 7})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.CancelOrder(ctx, &orderv1.CancelOrderRequest{Id: 8})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	_, err = client.CreateOrder(ctx, &orderv1.CreateOrderRequest{ProductId: 0})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.GetOrder(ctx, &orderv1.GetOrderRequest{Id: 9})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.NotContains(t, status.Convert(err).Message(), "10.0.0.3", "internal details must not leak")
+}
+
+func TestServer_WatchOrderEndsOnFinalStatus(t *testing.T) {
+	repo := new(mocks.MockOrderRepository)
+	repo.On("FindByID", uint64(5)).Return(&domain.Order{ID: 5, Status: domain.StatusFailed}, nil)
+
+	client, _ := newTestClient(t, repo, new(mocks.MockProductClient), new(mocks.MockPublisher))
+
+	stream, err := client.WatchOrder(context.Background(), &orderv1.WatchOrderRequest{Id: 5})
+	require.NoError(t, err)
+
+	o, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, orderv1.OrderStatus_ORDER_STATUS_FAILED, o.Status)
+
+	_, err = stream.Recv()
+	assert.Error(t, err, "stream closes once the order is final")
+}
@@ -0,0 +1,287 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"order-service/internal/domain"
+	rabbit "order-service/internal/infra/rabbitmq"
+	"order-service/internal/repository"
+	"order-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const auditDetailKey = "audit.detail"
+
+const CodeBrokerUnavailable = "BROKER_UNAVAILABLE"
+
+// Replays over HTTP run inside the request, so they stop after 1000 orders
+// unless maxOrders says otherwise, and never take more than 10000; larger
+// ones continue from lastOrderId or use the replay-events command.
+const defaultReplayMaxOrders = 1000
+
+// AdminHandler serves maintenance endpoints under /admin and refunds.
+// Every route requires a permission from the RBAC policy, and every
+// state-changing route leaves an audit record, including attempts that
+// were denied.
+type AdminHandler struct {
+	service     *services.OrderService
+	audit       *services.AuditLog
+	policy      *Policy
+	deadLetters rabbit.DeadLetterStore
+	replayer    *services.EventReplayer
+	sagas       *services.SagaOrchestrator
+}
+
+func NewAdminHandler(s *services.OrderService, audit *services.AuditLog, policy *Policy) *AdminHandler {
+	return &AdminHandler{service: s, audit: audit, policy: policy}
+}
+
+// SetDeadLetters enables the dead-letter routes. Call it before
+// RegisterRoutes.
+func (h *AdminHandler) SetDeadLetters(store rabbit.DeadLetterStore) {
+	h.deadLetters = store
+}
+
+// SetReplayer enables the event replay route. Call it before
+// RegisterRoutes.
+func (h *AdminHandler) SetReplayer(r *services.EventReplayer) {
+	h.replayer = r
+}
+
+// SetSagas enables the saga inspection route. Call it before
+// RegisterRoutes.
+func (h *AdminHandler) SetSagas(o *services.SagaOrchestrator) {
+	h.sagas = o
+}
+
+func (h *AdminHandler) RegisterRoutes(r gin.IRouter) {
+	admin := r.Group("/admin")
+	admin.PUT("/orders/:id/status", h.audited("order.status_override"), Require(h.policy, PermOrderStatusOverride), h.OverrideStatus)
+	admin.POST("/cache/flush", h.audited("cache.flush"), Require(h.policy, PermCacheFlush), h.FlushCache)
+	admin.GET("/audit", Require(h.policy, PermAuditRead), h.ListAudit)
+	r.POST("/orders/:id/refunds", h.audited("order.refund"), Require(h.policy, PermOrdersRefund), h.RefundOrder)
+	if h.deadLetters != nil {
+		admin.GET("/dead-letters", Require(h.policy, PermDeadLettersRead), h.ListDeadLetters)
+		admin.POST("/dead-letters/redrive", h.audited("dead_letters.redrive"), Require(h.policy, PermDeadLettersRedrive), h.RedriveDeadLetters)
+	}
+	if h.replayer != nil {
+		admin.POST("/events/replay", h.audited("events.replay"), Require(h.policy, PermEventsReplay), h.ReplayEvents)
+	}
+	if h.sagas != nil {
+		admin.GET("/sagas/:id", Require(h.policy, PermSagasRead), h.GetSaga)
+	}
+}
+
+// audited records the outcome of the rest of the chain. Handlers can add
+// context with c.Set(auditDetailKey, ...).
+func (h *AdminHandler) audited(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		outcome := "success"
+		switch status := c.Writer.Status(); {
+		case status == http.StatusUnauthorized || status == http.StatusForbidden:
+			outcome = "denied"
+		case status >= http.StatusBadRequest:
+			outcome = "failed"
+		}
+		target := c.Param("id")
+		if target == "" {
+			target = c.FullPath()
+		}
+		h.audit.Record(c.Request.Context(), action, target, c.GetString(auditDetailKey), outcome)
+	}
+}
+
+func (h *AdminHandler) OverrideStatus(c *gin.Context) {
+	id, ok := parseOrderID(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Status domain.OrderStatus `json:"status" binding:"required"`
+		Reason string             `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	c.Set(auditDetailKey, "to="+string(req.Status)+" reason="+req.Reason)
+
+	ifVersion, ok := parseIfMatch(c)
+	if !ok {
+		return
+	}
+	order, err := h.service.OverrideStatus(c.Request.Context(), id, req.Status, req.Reason, ifVersion)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	setETag(c, order)
+	c.JSON(http.StatusOK, order)
+}
+
+// RefundOrder gives back part or, without an amount, all of what is left
+// of an order's payment.
+func (h *AdminHandler) RefundOrder(c *gin.Context) {
+	id, ok := parseOrderID(c)
+	if !ok {
+		return
+	}
+	var req RefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondBindError(c, err)
+		return
+	}
+	c.Set(auditDetailKey, fmt.Sprintf("amount=%d reason=%s", req.Amount, req.Reason))
+
+	ifVersion, ok := parseIfMatch(c)
+	if !ok {
+		return
+	}
+	refund, err := h.service.RefundOrder(c.Request.Context(), id, req.Amount, req.Reason, ifVersion)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, refund)
+}
+
+func (h *AdminHandler) FlushCache(c *gin.Context) {
+	removed, err := h.service.FlushProductCache(c.Request.Context())
+	c.Set(auditDetailKey, "redis_keys_removed="+strconv.Itoa(removed))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"removed": removed})
+}
+
+// ListAudit pages through audit records, newest first; pass the last id
+// seen as ?before= for the next page.
+func (h *AdminHandler) ListAudit(c *gin.Context) {
+	limit, ok := parseLimit(c)
+	if !ok {
+		return
+	}
+	var before uint64
+	if raw := c.Query("before"); raw != "" {
+		v, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			writeProblem(c, http.StatusBadRequest, CodeInvalidParameter, "before must be a record id")
+			return
+		}
+		before = v
+	}
+	recs, err := h.audit.Recent(c.Request.Context(), before, limit)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, recs)
+}
+
+// ListDeadLetters shows up to ?limit= messages parked in the dead-letter
+// queue, with the failure that put them there.
+func (h *AdminHandler) ListDeadLetters(c *gin.Context) {
+	limit, ok := parseLimit(c)
+	if !ok {
+		return
+	}
+	msgs, err := h.deadLetters.DeadLetters(limit)
+	if err != nil {
+		log.Printf("list dead letters: %v", err)
+		writeProblem(c, http.StatusServiceUnavailable, CodeBrokerUnavailable, "message broker unavailable")
+		return
+	}
+	c.JSON(http.StatusOK, msgs)
+}
+
+// RedriveDeadLetters moves dead-lettered messages back to the consumer
+// queue, either one by messageId or up to limit of them.
+func (h *AdminHandler) RedriveDeadLetters(c *gin.Context) {
+	var req struct {
+		MessageID string `json:"messageId"`
+		Limit     int    `json:"limit" binding:"omitempty,min=1,max=1000"`
+	}
+	// An empty body redrives a page of messages
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondBindError(c, err)
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = services.DefaultPageSize
+	}
+
+	moved, err := h.deadLetters.Redrive(req.Limit, req.MessageID)
+	c.Set(auditDetailKey, fmt.Sprintf("message_id=%q moved=%d", req.MessageID, moved))
+	if err != nil {
+		log.Printf("redrive dead letters: %v", err)
+		writeProblem(c, http.StatusServiceUnavailable, CodeBrokerUnavailable, "message broker unavailable")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"redriven": moved})
+}
+
+// ReplayEvents re-publishes the events of the selected orders and returns
+// the report. The body holds the selection plus events, ratePerSecond,
+// maxOrders, dryRun and force; an incomplete report can be continued by sending
+// fromId = lastOrderId + 1.
+func (h *AdminHandler) ReplayEvents(c *gin.Context) {
+	var req struct {
+		repository.ReplayFilter
+		Events        []string `json:"events"`
+		RatePerSecond float64  `json:"ratePerSecond" binding:"omitempty,min=0,max=1000"`
+		MaxOrders     int      `json:"maxOrders" binding:"omitempty,min=1,max=10000"`
+		DryRun        bool     `json:"dryRun"`
+		Force         bool     `json:"force"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondBindError(c, err)
+		return
+	}
+	if req.MaxOrders == 0 {
+		req.MaxOrders = defaultReplayMaxOrders
+	}
+
+	rep, err := h.replayer.Replay(c.Request.Context(), services.ReplayRequest{
+		Filter:    req.ReplayFilter,
+		Events:    req.Events,
+		Rate:      req.RatePerSecond,
+		MaxOrders: req.MaxOrders,
+		DryRun:    req.DryRun,
+		Force:     req.Force,
+	}, nil)
+	detail := fmt.Sprintf("dry_run=%t matched=%d published=%d failed=%d last_order_id=%d complete=%t",
+		rep.DryRun, rep.Matched, rep.Published, rep.Failed, rep.LastOrderID, rep.Complete)
+	if req.Force {
+		detail += " force=true"
+	}
+	c.Set(auditDetailKey, detail)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, rep)
+}
+
+// GetSaga shows the fulfilment saga of an order: its state, the step it is
+// on and its log.
+func (h *AdminHandler) GetSaga(c *gin.Context) {
+	id, ok := parseOrderID(c)
+	if !ok {
+		return
+	}
+	saga, err := h.sagas.Get(id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, saga)
+}
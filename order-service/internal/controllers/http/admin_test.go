@@ -0,0 +1,301 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"order-service/internal/auth"
+	"order-service/internal/domain"
+	rabbit "order-service/internal/infra/rabbitmq"
+	"order-service/internal/mocks"
+	"order-service/internal/repository"
+	"order-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPolicy_Allows(t *testing.T) {
+	pol := NewPolicy(map[string][]string{
+		"admin":   {"*"},
+		"support": {"orders:*", PermAuditRead},
+	})
+	support := &auth.Principal{Roles: []string{"support"}}
+
+	assert.True(t, pol.Allows(support, PermOrderStatusOverride))
+	assert.True(t, pol.Allows(support, PermAuditRead))
+	assert.False(t, pol.Allows(support, PermCacheFlush))
+	assert.True(t, pol.Allows(&auth.Principal{Roles: []string{"admin"}}, PermEventsReplay))
+	assert.False(t, pol.Allows(&auth.Principal{}, PermAuditRead))
+}
+
+func TestAdminHandler_OverrideStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name    string
+		roles   []string
+		anon    bool
+		status  int
+		outcome string
+	}{
+		{"admin", []string{"admin"}, false, http.StatusOK, "success"},
+		{"customer", []string{"customer"}, false, http.StatusForbidden, "denied"},
+		{"anonymous", nil, true, http.StatusUnauthorized, "denied"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(mocks.MockOrderRepository)
+			repo.On("FindByID", uint64(5)).Return(&domain.Order{ID: 5, Status: domain.StatusPending}, nil).Maybe()
+			repo.On("UpdateStatus", uint64(5), domain.StatusPending, domain.StatusConfirmed).Return(nil).Maybe()
+			auditRepo := new(mocks.MockAuditRepository)
+			auditRepo.On("Append", mock.MatchedBy(func(rec *domain.AuditRecord) bool {
+				return rec.Action == "order.status_override" && rec.Target == "5" && rec.Outcome == tt.outcome
+			})).Return(nil).Once()
+
+			svc := services.NewOrderService(repo, new(mocks.MockProductClient), new(mocks.MockPublisher))
+			r := gin.New()
+			r.Use(func(c *gin.Context) {
+				if !tt.anon {
+					p := &auth.Principal{Subject: "u1", Method: auth.MethodJWT, Roles: tt.roles}
+					c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), p))
+				}
+				c.Next()
+			})
+			NewAdminHandler(svc, services.NewAuditLog(auditRepo), DefaultPolicy()).RegisterRoutes(r)
+
+			req := httptest.NewRequest(http.MethodPut, "/admin/orders/5/status", strings.NewReader(`{"status":"confirmed","reason":"stuck after broker outage"}`))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			auditRepo.AssertExpectations(t)
+			if tt.status != http.StatusOK {
+				repo.AssertNotCalled(t, "UpdateStatus", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestAdminHandler_OverrideStatusHonoursIfMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		ifMatch string
+		status  int
+		etag    string
+	}{
+		{"matching version", `"4"`, http.StatusOK, `"5"`},
+		{"stale version", `"3"`, http.StatusPreconditionFailed, ""},
+		{"weak tag", `W/"4"`, http.StatusPreconditionFailed, ""},
+		{"unconditional", "", http.StatusOK, `"5"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(mocks.MockOrderRepository)
+			repo.On("FindByID", uint64(5)).Return(&domain.Order{ID: 5, Status: domain.StatusPending, Version: 4}, nil)
+			repo.On("UpdateStatus", uint64(5), domain.StatusPending, domain.StatusConfirmed).Return(nil).Maybe()
+			auditRepo := new(mocks.MockAuditRepository)
+			auditRepo.On("Append", mock.Anything).Return(nil)
+
+			svc := services.NewOrderService(repo, new(mocks.MockProductClient), new(mocks.MockPublisher))
+			r := gin.New()
+			r.Use(func(c *gin.Context) {
+				p := &auth.Principal{Subject: "u1", Method: auth.MethodJWT, Roles: []string{"admin"}}
+				c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), p))
+				c.Next()
+			})
+			NewAdminHandler(svc, services.NewAuditLog(auditRepo), DefaultPolicy()).RegisterRoutes(r)
+
+			req := httptest.NewRequest(http.MethodPut, "/admin/orders/5/status", strings.NewReader(`{"status":"confirmed","reason":"stuck"}`))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, tt.etag, w.Header().Get("ETag"))
+		})
+	}
+}
+
+func TestAdminHandler_RefundOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		roles   []string
+		anon    bool
+		ifMatch string
+		status  int
+		outcome string
+	}{
+		{"admin", []string{"admin"}, false, `"4"`, http.StatusCreated, "success"},
+		{"stale version", []string{"admin"}, false, `"3"`, http.StatusPreconditionFailed, "failed"},
+		{"customer", []string{"customer"}, false, "", http.StatusForbidden, "denied"},
+		{"anonymous", nil, true, "", http.StatusUnauthorized, "denied"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(mocks.MockOrderRepository)
+			repo.On("FindByID", uint64(5)).Return(&domain.Order{ID: 5, Status: domain.StatusConfirmed, TotalPrice: 1000, Version: 4}, nil).Maybe()
+			refunds := new(mocks.MockRefundRepository)
+			refunds.On("Reserve", mock.Anything, mock.Anything, uint64(4)).Return(nil).Maybe()
+			refunds.On("Complete", mock.Anything).Return(nil).Maybe()
+			pub := new(mocks.MockPublisher)
+			pub.On("Publish", mock.Anything, domain.EventOrderRefunded, mock.Anything).Return(nil).Maybe()
+			auditRepo := new(mocks.MockAuditRepository)
+			auditRepo.On("Append", mock.MatchedBy(func(rec *domain.AuditRecord) bool {
+				return rec.Action == "order.refund" && rec.Target == "5" && rec.Outcome == tt.outcome
+			})).Return(nil).Once()
+
+			svc := services.NewOrderService(repo, new(mocks.MockProductClient), pub)
+			svc.SetRefundRepository(refunds)
+			r := gin.New()
+			r.Use(func(c *gin.Context) {
+				if !tt.anon {
+					p := &auth.Principal{Subject: "u1", Method: auth.MethodJWT, Roles: tt.roles}
+					c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), p))
+				}
+				c.Next()
+			})
+			NewAdminHandler(svc, services.NewAuditLog(auditRepo), DefaultPolicy()).RegisterRoutes(r)
+
+			req := httptest.NewRequest(http.MethodPost, "/orders/5/refunds", strings.NewReader(`{"amount":300,"reason":"damaged box"}`))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			auditRepo.AssertExpectations(t)
+			if tt.status != http.StatusCreated {
+				refunds.AssertNotCalled(t, "Reserve", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+type fakeDeadLetters struct {
+	letters   []rabbit.DeadLetter
+	redriveID string
+}
+
+func (f *fakeDeadLetters) DeadLetters(limit int) ([]rabbit.DeadLetter, error) {
+	return f.letters, nil
+}
+
+func (f *fakeDeadLetters) Redrive(limit int, messageID string) (int, error) {
+	f.redriveID = messageID
+	return 1, nil
+}
+
+func TestAdminHandler_DeadLetters(t *testing.T) {
+	store := &fakeDeadLetters{letters: []rabbit.DeadLetter{{MessageID: "m-1", Pattern: "order.qty_failed", Reason: "db down", Retries: 5, Body: []byte(`{}`)}}}
+	auditRepo := new(mocks.MockAuditRepository)
+	auditRepo.On("Append", mock.MatchedBy(func(rec *domain.AuditRecord) bool {
+		return rec.Action == "dead_letters.redrive" && rec.Outcome == "success" && strings.Contains(rec.Detail, `"m-1"`)
+	})).Return(nil).Once()
+
+	svc := services.NewOrderService(new(mocks.MockOrderRepository), new(mocks.MockProductClient), new(mocks.MockPublisher))
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		p := &auth.Principal{Subject: "u1", Method: auth.MethodJWT, Roles: []string{"admin"}}
+		c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), p))
+		c.Next()
+	})
+	h := NewAdminHandler(svc, services.NewAuditLog(auditRepo), DefaultPolicy())
+	h.SetDeadLetters(store)
+	h.RegisterRoutes(r)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/dead-letters?limit=10", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"reason":"db down"`)
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/dead-letters/redrive", strings.NewReader(`{"messageId":"m-1"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"redriven":1}`, w.Body.String())
+	assert.Equal(t, "m-1", store.redriveID)
+	auditRepo.AssertExpectations(t)
+}
+
+func TestAdminHandler_ReplayEvents(t *testing.T) {
+	repo := new(mocks.MockOrderRepository)
+	filter := repository.ReplayFilter{FromID: 10, Statuses: []domain.OrderStatus{domain.StatusPending}}
+	repo.On("FindForReplay", filter, uint64(0), 200).Return([]domain.Order{{ID: 10}, {ID: 12}}, nil)
+	pub := new(mocks.MockPublisher)
+	auditRepo := new(mocks.MockAuditRepository)
+	auditRepo.On("Append", mock.MatchedBy(func(rec *domain.AuditRecord) bool {
+		return rec.Action == "events.replay" && rec.Outcome == "success" && strings.Contains(rec.Detail, "dry_run=true matched=2")
+	})).Return(nil).Once()
+
+	svc := services.NewOrderService(repo, new(mocks.MockProductClient), pub)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		p := &auth.Principal{Subject: "u1", Method: auth.MethodJWT, Roles: []string{"admin"}}
+		c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), p))
+		c.Next()
+	})
+	h := NewAdminHandler(svc, services.NewAuditLog(auditRepo), DefaultPolicy())
+	h.SetReplayer(services.NewEventReplayer(repo, pub, nil))
+	h.RegisterRoutes(r)
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/events/replay", strings.NewReader(`{"fromId":10,"statuses":["pending"],"dryRun":true}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"matched":2`)
+	assert.Contains(t, w.Body.String(), `"lastOrderId":12`)
+	assert.Contains(t, w.Body.String(), `"complete":true`)
+	pub.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything, mock.Anything)
+	auditRepo.AssertExpectations(t)
+
+	req = httptest.NewRequest(http.MethodPost, "/admin/events/replay", strings.NewReader(`{"events":["order.shipped"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	auditRepo.On("Append", mock.Anything).Return(nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "INVALID_REPLAY")
+}
+
+func TestAdminHandler_GetSaga(t *testing.T) {
+	sagaRepo := new(mocks.MockSagaRepository)
+	sagaRepo.On("FindByOrderID", uint64(7)).Return(&domain.Saga{ID: 3, OrderID: 7, State: domain.SagaRunning, Step: 1, StepName: "charge_payment", Attempts: 2}, nil)
+	sagaRepo.On("FindLog", uint64(3)).Return([]domain.SagaLogEntry{{SagaID: 3, Step: "reserve_stock", Action: "command"}}, nil)
+	sagaRepo.On("FindByOrderID", uint64(8)).Return(nil, nil)
+
+	svc := services.NewOrderService(new(mocks.MockOrderRepository), new(mocks.MockProductClient), new(mocks.MockPublisher))
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		p := &auth.Principal{Subject: "u1", Method: auth.MethodJWT, Roles: []string{"support"}}
+		c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), p))
+		c.Next()
+	})
+	h := NewAdminHandler(svc, services.NewAuditLog(new(mocks.MockAuditRepository)), NewPolicy(map[string][]string{"support": {"sagas:read"}}))
+	h.SetSagas(services.NewSagaOrchestrator(svc, sagaRepo, new(mocks.MockPublisher), services.DefaultSagaConfig(), nil))
+	h.RegisterRoutes(r)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/sagas/7", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"stepName":"charge_payment"`)
+	assert.Contains(t, w.Body.String(), `"steps":["reserve_stock","charge_payment","confirm"]`)
+	assert.Contains(t, w.Body.String(), `"action":"command"`)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/sagas/8", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "SAGA_NOT_FOUND")
+}
@@ -0,0 +1,50 @@
+package http
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"order-service/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+const CodeUnauthenticated = "UNAUTHENTICATED"
+
+// Authenticate resolves the caller from X-API-Key or an
+// "Authorization: Bearer" token and stores it in the request context,
+// where services pick it up with auth.FromContext. When required is false,
+// requests without credentials continue marked auth.WithAnonymous, which
+// keeps them out of customers' data; bad credentials are always rejected.
+func Authenticate(a auth.Authenticator, required bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, err := a.Authenticate(c.Request.Context(), credentialsFrom(c.Request))
+		switch {
+		case err == nil:
+			c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), p))
+			c.Next()
+		case errors.Is(err, auth.ErrNoCredentials) && !required:
+			c.Request = c.Request.WithContext(auth.WithAnonymous(c.Request.Context()))
+			c.Next()
+		case errors.Is(err, auth.ErrNoCredentials):
+			c.Header("WWW-Authenticate", `Bearer realm="orders"`)
+			writeProblem(c, http.StatusUnauthorized, CodeUnauthenticated, "credentials required")
+		default:
+			if !errors.Is(err, auth.ErrInvalidCredentials) {
+				log.Printf("%s %s: authentication: %v", c.Request.Method, c.Request.URL.Path, err)
+			}
+			c.Header("WWW-Authenticate", `Bearer realm="orders", error="invalid_token"`)
+			writeProblem(c, http.StatusUnauthorized, CodeUnauthenticated, "invalid credentials")
+		}
+	}
+}
+
+func credentialsFrom(r *http.Request) auth.Credentials {
+	creds := auth.Credentials{APIKey: r.Header.Get("X-API-Key")}
+	if scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") {
+		creds.BearerToken = strings.TrimSpace(token)
+	}
+	return creds
+}
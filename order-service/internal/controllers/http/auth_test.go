@@ -0,0 +1,76 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"order-service/internal/auth"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newAuthRouter(t *testing.T, required bool) *gin.Engine {
+	t.Helper()
+	keys, err := auth.NewAPIKeyAuthenticator([]auth.APIKey{{ID: "checkout", Hash: auth.HashAPIKey("s3cret")}})
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Authenticate(auth.Chain{keys}, required))
+	r.GET("/whoami", func(c *gin.Context) {
+		p, ok := auth.FromContext(c.Request.Context())
+		if !ok {
+			if _, scoped := auth.CustomerScope(c.Request.Context()); scoped {
+				c.String(http.StatusOK, "anonymous")
+			}
+			return
+		}
+		c.String(http.StatusOK, p.ID())
+	})
+	return r
+}
+
+func TestAuthenticate(t *testing.T) {
+	tests := []struct {
+		name     string
+		required bool
+		apiKey   string
+		status   int
+		body     string
+	}{
+		{"valid key", true, "s3cret", http.StatusOK, "api_key:checkout"},
+		{"bad key", false, "nope", http.StatusUnauthorized, ""},
+		{"missing when required", true, "", http.StatusUnauthorized, ""},
+		{"missing when optional", false, "", http.StatusOK, "anonymous"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+			if tt.apiKey != "" {
+				req.Header.Set("X-API-Key", tt.apiKey)
+			}
+			w := httptest.NewRecorder()
+			newAuthRouter(t, tt.required).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			if tt.status == http.StatusUnauthorized {
+				assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+				assert.Equal(t, problemContentType, w.Header().Get("Content-Type"))
+				return
+			}
+			assert.Equal(t, tt.body, w.Body.String())
+		})
+	}
+}
+
+func TestCredentialsFrom(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "bearer abc.def.ghi")
+	assert.Equal(t, "abc.def.ghi", credentialsFrom(req).BearerToken)
+
+	req.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+	assert.Empty(t, credentialsFrom(req).BearerToken)
+}
@@ -7,4 +7,21 @@ type CreateOrderRequest struct {
 
 type CreateOrderResponse struct {
 	ID uint64 `json:"id"`
-}
\ No newline at end of file
+}
+
+// RefundRequest refunds Amount, or everything not yet refunded when it is
+// left out.
+type RefundRequest struct {
+	Amount int64  `json:"amount" binding:"min=0"`
+	Reason string `json:"reason" binding:"max=255"`
+}
+
+// WebhookEndpointRequest creates or, with only some fields set, updates a
+// webhook endpoint.
+type WebhookEndpointRequest struct {
+	URL        *string  `json:"url"`
+	EventTypes []string `json:"eventTypes"`
+	Secret     *string  `json:"secret"`
+	CustomerID *string  `json:"customerId"`
+	Enabled    *bool    `json:"enabled"`
+}
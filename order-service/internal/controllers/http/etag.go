@@ -0,0 +1,39 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"order-service/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+const CodePreconditionFailed = "PRECONDITION_FAILED"
+
+// setETag exposes the order version as a strong entity tag, which clients
+// send back in If-Match to make a mutation conditional.
+func setETag(c *gin.Context, o *domain.Order) {
+	c.Header("ETag", `"`+strconv.FormatUint(o.Version, 10)+`"`)
+}
+
+// parseIfMatch returns the version required by If-Match, or 0 when the
+// header is absent or "*". If-Match compares strongly, so a weak tag (or
+// anything else this service did not issue) fails with 412 right away.
+func parseIfMatch(c *gin.Context) (uint64, bool) {
+	raw := strings.TrimSpace(c.GetHeader("If-Match"))
+	if raw == "" || raw == "*" {
+		return 0, true
+	}
+	tag, ok := strings.CutPrefix(raw, `"`)
+	if ok {
+		tag, ok = strings.CutSuffix(tag, `"`)
+	}
+	version, err := strconv.ParseUint(tag, 10, 64)
+	if !ok || err != nil || version == 0 {
+		writeProblem(c, http.StatusPreconditionFailed, CodePreconditionFailed, "If-Match must be an ETag returned by this service")
+		return 0, false
+	}
+	return version, true
+}
@@ -3,7 +3,11 @@ package http
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"order-service/internal/auth"
+	"order-service/internal/domain"
 	"order-service/internal/services"
 	"strconv"
 	"time"
@@ -12,6 +16,11 @@ import (
 	"github.com/go-redis/redis/v8"
 )
 
+const (
+	defaultLongPollWait = 5 * time.Second
+	maxLongPollWait     = 30 * time.Second
+)
+
 type Handler struct {
 	service *services.OrderService
 	rdb *redis.Client
@@ -23,7 +32,13 @@ func NewHandler(u *services.OrderService, rdb *redis.Client) *Handler {
 
 func (h *Handler) RegisterRoutes(r *gin.Engine){
 	r.POST("/orders", h.CreateOrder)
+	r.GET("/orders/:id", h.GetOrder)
 	r.GET("/orders/product/:productId", h.GetOrderByProduct)
+	r.GET("/orders/:id/events", h.StreamOrderEvents)
+	r.GET("/orders/:id/history", h.GetOrderHistory)
+	r.GET("/orders/:id/refunds", h.ListRefunds)
+	r.GET("/customers/:id/orders", h.ListCustomerOrders)
+	r.GET("/me/orders", h.ListMyOrders)
 }
 
 func (h *Handler) CreateOrder(c *gin.Context) {
@@ -32,34 +47,144 @@ func (h *Handler) CreateOrder(c *gin.Context) {
 		TotalPrice int64 `json:"totalPrice" binding:"required,min=0"`
 	}
 	if err := c.ShouldBindJSON(&req); err !=nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
+		return
+	}
+
+	wait, err := parseWait(c.Query("wait"), 0)
+	if err != nil {
+		writeProblem(c, http.StatusBadRequest, CodeInvalidParameter, err.Error())
 		return
 	}
 
-	ctx:= c.Request.Context()
+	// Callers may ask to be shed later (critical) or earlier (low) under load
+	ctx := c.Request.Context()
+	ctx = services.WithPriority(ctx, services.RequestedPriority(ctx, c.GetHeader("X-Request-Priority")))
 
 	order, err := h.service.CreateOrder(ctx, req.ProductID, req.TotalPrice)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
 	cacheKey := "orders:product" + strconv.FormatUint(req.ProductID, 10)
 	h.rdb.Del(context.Background(), cacheKey)
 
-	c.JSON(http.StatusCreated, gin.H{"id": order.ID})
+	if wait == 0 {
+		c.JSON(http.StatusCreated, gin.H{"id": order.ID})
+		return
+	}
+
+	final, err := h.service.WaitForFinalStatus(ctx, order.ID, wait)
+	if err != nil {
+		// The order exists; report it as accepted rather than failing the request
+		c.JSON(http.StatusAccepted, order)
+		return
+	}
+	if !final.Status.IsFinal() {
+		c.JSON(http.StatusAccepted, final)
+		return
+	}
+	c.JSON(http.StatusCreated, final)
+}
+
+// GetOrder returns an order. With ?waitFor=final it long-polls until the
+// order is confirmed, failed or cancelled, answering 202 with the pending
+// order if ?wait (default 5s) expires first.
+func (h *Handler) GetOrder(c *gin.Context) {
+	id, ok := parseOrderID(c)
+	if !ok {
+		return
+	}
+
+	waitFor := c.Query("waitFor")
+	if waitFor != "" && waitFor != "final" {
+		writeProblem(c, http.StatusBadRequest, CodeInvalidParameter, "waitFor must be 'final'")
+		return
+	}
+
+	ctx := c.Request.Context()
+	if waitFor == "" {
+		order, err := h.service.GetOrderById(ctx, id)
+		if err != nil {
+			respondError(c, err)
+			return
+		}
+		setETag(c, order)
+		c.JSON(http.StatusOK, order)
+		return
+	}
+
+	wait, err := parseWait(c.Query("wait"), defaultLongPollWait)
+	if err != nil {
+		writeProblem(c, http.StatusBadRequest, CodeInvalidParameter, err.Error())
+		return
+	}
+
+	order, err := h.service.WaitForFinalStatus(ctx, id, wait)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if !order.Status.IsFinal() {
+		c.JSON(http.StatusAccepted, order)
+		return
+	}
+	c.JSON(http.StatusOK, order)
+}
+
+// GetOrderHistory lists an order's status changes, oldest first.
+func (h *Handler) GetOrderHistory(c *gin.Context) {
+	id, ok := parseOrderID(c)
+	if !ok {
+		return
+	}
+	history, err := h.service.GetStatusHistory(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
+
+// ListRefunds lists an order's refunds, oldest first.
+func (h *Handler) ListRefunds(c *gin.Context) {
+	id, ok := parseOrderID(c)
+	if !ok {
+		return
+	}
+	refunds, err := h.service.ListRefunds(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, refunds)
 }
 
 func (h *Handler) GetOrderByProduct(c *gin.Context) {
 	productIdStr := c.Param("productId")
-	if productIdStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "productId required"})
+	productId, err := strconv.ParseUint(productIdStr, 10, 64)
+	if err != nil {
+		writeProblem(c, http.StatusBadRequest, CodeInvalidParameter, "productId must be a positive integer")
 		return
 	}
-	productId, _ := strconv.ParseUint(productIdStr, 10, 64)
 	cacheKey := "orders:product" + productIdStr
 
-	ctx:= context.Background()
+	// The cached list holds every customer's orders, so customer-scoped
+	// callers always go to the service, which filters to their own
+	ctx := c.Request.Context()
+	if _, scoped := auth.CustomerScope(ctx); scoped {
+		orders, err := h.service.GetOrderByProductId(ctx, productId)
+		if errors.Is(err, services.ErrOrderNotFound) {
+			orders = []domain.Order{}
+		} else if err != nil {
+			respondError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, orders)
+		return
+	}
+
 	b, err := h.rdb.Get(ctx, cacheKey).Result()
 	if err == nil {
 		var orders []map[string]any
@@ -70,12 +195,125 @@ func (h *Handler) GetOrderByProduct(c *gin.Context) {
 
 	orders, err  := h.service.GetOrderByProductId(ctx, productId)
 
-	 if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-        return
-    }
+	if errors.Is(err, services.ErrOrderNotFound) {
+		orders = []domain.Order{}
+	} else if err != nil {
+		respondError(c, err)
+		return
+	}
     data, _ := json.Marshal(orders)
     h.rdb.Set(ctx, cacheKey, data, 10*time.Second)
 
     c.JSON(http.StatusOK, orders)
 }
+
+
+// ListCustomerOrders returns a page of a customer's orders, newest first.
+// Pass the returned nextCursor as ?cursor= to fetch the following page.
+func (h *Handler) ListCustomerOrders(c *gin.Context) {
+	limit, ok := parseLimit(c)
+	if !ok {
+		return
+	}
+	page, err := h.service.ListCustomerOrders(c.Request.Context(), c.Param("id"), c.Query("cursor"), limit)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// ListMyOrders is ListCustomerOrders for the authenticated customer.
+func (h *Handler) ListMyOrders(c *gin.Context) {
+	limit, ok := parseLimit(c)
+	if !ok {
+		return
+	}
+	page, err := h.service.ListMyOrders(c.Request.Context(), c.Query("cursor"), limit)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// StreamOrderEvents pushes the order and each status change as Server-Sent
+// Events until the order reaches a final status or the client disconnects.
+func (h *Handler) StreamOrderEvents(c *gin.Context) {
+	id, ok := parseOrderID(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	updates, err := h.service.WatchOrder(ctx, id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case o, ok := <-updates:
+			if !ok {
+				return false
+			}
+			c.SSEvent("status", o)
+			return true
+		case <-heartbeat.C:
+			_, err := io.WriteString(w, ": keep-alive\n\n")
+			return err == nil
+		case <-ctx.Done():
+			return false
+		}
+	})
+}
+
+// parseWait reads a long-poll duration such as "2s", capped at
+// maxLongPollWait. An empty value yields def.
+func parseWait(raw string, def time.Duration) (time.Duration, error) {
+	if raw == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		return 0, errors.New("wait must be a non-negative duration such as 2s")
+	}
+	if d > maxLongPollWait {
+		d = maxLongPollWait
+	}
+	return d, nil
+}
+
+// parseLimit reads the optional ?limit page size.
+func parseLimit(c *gin.Context) (int, bool) {
+	raw := c.Query("limit")
+	if raw == "" {
+		return services.DefaultPageSize, true
+	}
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit <= 0 || limit > services.MaxPageSize {
+		writeProblem(c, http.StatusBadRequest, CodeInvalidParameter, "limit must be between 1 and "+strconv.Itoa(services.MaxPageSize))
+		return 0, false
+	}
+	return limit, true
+}
+
+// parseOrderID reads the :id path parameter, writing a problem response
+// and returning false if it is not a valid id.
+func parseOrderID(c *gin.Context) (uint64, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		writeProblem(c, http.StatusBadRequest, CodeInvalidParameter, "order id must be a positive integer")
+		return 0, false
+	}
+	return id, true
+}
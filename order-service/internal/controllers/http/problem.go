@@ -0,0 +1,112 @@
+package http
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"order-service/internal/services"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+const problemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details body. Code is a stable,
+// machine-readable identifier clients can switch on.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+}
+
+// Codes for failures detected in the transport layer itself.
+const (
+	CodeMalformedRequest = "MALFORMED_REQUEST"
+	CodeValidationFailed = "VALIDATION_FAILED"
+	CodeInvalidParameter = "INVALID_PARAMETER"
+	CodeInternal         = "INTERNAL_ERROR"
+)
+
+func writeProblem(c *gin.Context, status int, code, detail string) {
+	p := Problem{
+		Type:     "/problems/" + strings.ReplaceAll(strings.ToLower(code), "_", "-"),
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: c.Request.URL.Path,
+		Code:     code,
+	}
+	c.Header("Content-Type", problemContentType)
+	c.AbortWithStatusJSON(status, p)
+}
+
+// statusForKind maps a service error kind to an HTTP status.
+func statusForKind(kind services.ErrorKind) int {
+	switch kind {
+	case services.KindNotFound:
+		return http.StatusNotFound
+	case services.KindValidation:
+		return http.StatusUnprocessableEntity
+	case services.KindConflict:
+		return http.StatusConflict
+	case services.KindUnauthenticated:
+		return http.StatusUnauthorized
+	case services.KindForbidden:
+		return http.StatusForbidden
+	case services.KindPreconditionFailed:
+		return http.StatusPreconditionFailed
+	case services.KindRateLimited:
+		return http.StatusTooManyRequests
+	case services.KindOverloaded, services.KindUnavailable:
+		return http.StatusServiceUnavailable
+	case services.KindTimeout:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// respondError writes err as a problem+json response. Only client-facing
+// messages are exposed; causes of dependency failures are logged instead.
+func respondError(c *gin.Context, err error) {
+	kind := services.KindOf(err)
+	status := statusForKind(kind)
+
+	code, detail := CodeInternal, "internal error"
+	if e, ok := services.AsError(err); ok {
+		code, detail = e.Code, e.Message
+		if kind == services.KindValidation || kind == services.KindConflict {
+			// These messages are composed by the service and describe the request
+			detail = err.Error()
+		}
+	} else if kind == services.KindTimeout {
+		code, detail = "TIMEOUT", "request timed out"
+	}
+
+	if status >= http.StatusInternalServerError {
+		log.Printf("%s %s: %v", c.Request.Method, c.Request.URL.Path, err)
+	}
+	if kind == services.KindOverloaded || kind == services.KindRateLimited || kind == services.KindUnavailable {
+		c.Header("Retry-After", "1")
+	}
+	writeProblem(c, status, code, detail)
+}
+
+// respondBindError reports a request body that failed to decode or validate.
+func respondBindError(c *gin.Context, err error) {
+	var ve validator.ValidationErrors
+	if errors.As(err, &ve) {
+		fields := make([]string, 0, len(ve))
+		for _, fe := range ve {
+			fields = append(fields, fe.Field()+" failed '"+fe.Tag()+"'")
+		}
+		writeProblem(c, http.StatusUnprocessableEntity, CodeValidationFailed, strings.Join(fields, "; "))
+		return
+	}
+	writeProblem(c, http.StatusBadRequest, CodeMalformedRequest, "request body is not valid JSON")
+}
@@ -0,0 +1,59 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"order-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRespondError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		err        error
+		status     int
+		code       string
+		retryAfter bool
+	}{
+		{"not found", services.ErrOrderNotFound, http.StatusNotFound, "ORDER_NOT_FOUND", false},
+		{"validation", fmt.Errorf("%w: productId is required", services.ErrInvalidOrder), http.StatusUnprocessableEntity, "INVALID_ORDER", false},
+		{"forbidden", services.ErrForbidden, http.StatusForbidden, "FORBIDDEN", false},
+		{"out of stock", services.ErrOutOfStock, http.StatusConflict, "OUT_OF_STOCK", false},
+		{"version mismatch", services.ErrVersionMismatch, http.StatusPreconditionFailed, "VERSION_MISMATCH", false},
+		{"overloaded", fmt.Errorf("%w: database connection timeout", services.ErrOverloaded), http.StatusServiceUnavailable, "SERVICE_OVERLOADED", true},
+		{"dependency down", fmt.Errorf("%w: %w", services.ErrProductServiceUnavailable, errors.New("dial tcp 10.0.0.7:3000: connection refused")), http.StatusServiceUnavailable, "PRODUCT_SERVICE_UNAVAILABLE", true},
+		{"timeout", fmt.Errorf("%w: slow", services.ErrStorageTimeout), http.StatusGatewayTimeout, "STORAGE_TIMEOUT", false},
+		{"untyped", errors.New("boom at 10.0.0.7"), http.StatusInternalServerError, CodeInternal, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/orders", nil)
+
+			respondError(c, tt.err)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, problemContentType, w.Header().Get("Content-Type"))
+			assert.Equal(t, tt.retryAfter, w.Header().Get("Retry-After") != "")
+
+			var p Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+			assert.Equal(t, tt.code, p.Code)
+			assert.Equal(t, tt.status, p.Status)
+			assert.Equal(t, "/orders", p.Instance)
+			assert.NotContains(t, p.Detail, "10.0.0.7", "internal causes must not leak")
+		})
+	}
+}
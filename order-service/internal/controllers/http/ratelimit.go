@@ -0,0 +1,71 @@
+package http
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"order-service/internal/infra"
+
+	"github.com/gin-gonic/gin"
+)
+
+const CodeRateLimited = "RATE_LIMITED"
+
+// RouteLimits maps a route as registered with Gin ("POST /orders",
+// "GET /orders/:id") to the limit each client gets on it. The "*" entry,
+// if present, applies to every route not listed.
+type RouteLimits map[string]infra.RateLimit
+
+// RateLimit enforces per-client limits and reports them with the
+// RateLimit-* headers from the IETF httpapi draft. It runs before
+// authentication, so failed credential guesses use up the bucket as well.
+// If the limiter itself fails the request is let through; availability
+// beats strictness here.
+func RateLimit(limiter infra.RateLimiter, limits RouteLimits) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.FullPath() == "" {
+			c.Next()
+			return
+		}
+		route := c.Request.Method + " " + c.FullPath()
+		rl, ok := limits[route]
+		if !ok {
+			route = "*"
+			if rl, ok = limits[route]; !ok {
+				c.Next()
+				return
+			}
+		}
+
+		res, err := limiter.Allow(c.Request.Context(), route+"|"+clientKey(c), rl)
+		if err != nil {
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Limit", strconv.Itoa(res.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		c.Header("RateLimit-Reset", ceilSeconds(res.Reset))
+		c.Header("RateLimit-Policy", strconv.Itoa(rl.Limit)+";w="+ceilSeconds(rl.Window))
+		if !res.Allowed {
+			c.Header("Retry-After", ceilSeconds(res.RetryAfter))
+			writeProblem(c, http.StatusTooManyRequests, CodeRateLimited, "rate limit exceeded")
+			return
+		}
+		c.Next()
+	}
+}
+
+// clientKey identifies the caller for rate limiting by IP. Nothing the
+// client sends counts: credentials are not verified yet, and
+// X-Forwarded-For is only honoured from the proxies the engine trusts
+// (see SetTrustedProxies).
+func clientKey(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
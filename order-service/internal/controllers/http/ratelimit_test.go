@@ -0,0 +1,102 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"order-service/internal/auth"
+	"order-service/internal/infra"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRateLimitedRouter(limits RouteLimits) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RateLimit(infra.NewMemoryRateLimiter(nil), limits))
+	r.POST("/orders", func(c *gin.Context) { c.Status(http.StatusCreated) })
+	r.GET("/orders/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return r
+}
+
+func TestRateLimit_RejectsWithHeaders(t *testing.T) {
+	r := newRateLimitedRouter(RouteLimits{
+		"POST /orders": {Limit: 2, Window: time.Minute},
+	})
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/orders", nil))
+		require.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/orders", nil))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+	assert.Equal(t, "2;w=60", w.Header().Get("RateLimit-Policy"))
+
+	var p Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	assert.Equal(t, CodeRateLimited, p.Code)
+
+	// Routes without a rule are untouched
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders/1", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+}
+
+func TestRateLimit_KeyedByClientIP(t *testing.T) {
+	r := newRateLimitedRouter(RouteLimits{
+		"*": {Limit: 1, Window: time.Minute},
+	})
+	require.NoError(t, r.SetTrustedProxies(nil))
+
+	send := func(remoteAddr, apiKey, forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodGet, "/orders/1", nil)
+		req.RemoteAddr = remoteAddr
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, send("198.51.100.7:1234", "alpha", ""))
+	assert.Equal(t, http.StatusTooManyRequests, send("198.51.100.7:1234", "beta", ""), "a new API key is no new bucket")
+	assert.Equal(t, http.StatusTooManyRequests, send("198.51.100.7:1234", "", "203.0.113.9"), "an untrusted X-Forwarded-For is ignored")
+	assert.Equal(t, http.StatusOK, send("198.51.100.8:1234", "", ""), "another IP has its own bucket")
+}
+
+func TestRateLimit_ThrottlesFailedAuthentication(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RateLimit(infra.NewMemoryRateLimiter(nil), RouteLimits{"*": {Limit: 2, Window: time.Minute}}))
+	keys, err := auth.NewAPIKeyAuthenticator([]auth.APIKey{{ID: "checkout", Hash: auth.HashAPIKey("s3cret")}})
+	require.NoError(t, err)
+	r.Use(Authenticate(auth.Chain{keys}, true))
+	r.GET("/orders/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	var codes []int
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/orders/1", nil)
+		req.Header.Set("X-API-Key", "guess-"+strconv.Itoa(i))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		codes = append(codes, w.Code)
+	}
+	assert.Equal(t, []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests}, codes)
+}
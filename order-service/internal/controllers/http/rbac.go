@@ -0,0 +1,90 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"order-service/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Permissions checked by admin and refund routes.
+const (
+	PermOrderStatusOverride = "orders:status:override"
+	PermOrdersRefund        = "orders:refund"
+	PermCacheFlush          = "cache:flush"
+	PermEventsReplay        = "events:replay"
+	PermAuditRead           = "audit:read"
+	PermDeadLettersRead     = "deadletters:read"
+	PermDeadLettersRedrive  = "deadletters:redrive"
+	PermSagasRead           = "sagas:read"
+)
+
+const CodeForbidden = "FORBIDDEN"
+
+// Policy maps roles to the permissions they grant. A "*" permission grants
+// everything; "orders:*" grants every permission with that prefix.
+type Policy struct {
+	roles map[string][]string
+}
+
+// NewPolicy builds a policy from role -> permissions.
+func NewPolicy(roles map[string][]string) *Policy {
+	return &Policy{roles: roles}
+}
+
+// DefaultPolicy grants everything to the admin role and nothing else.
+func DefaultPolicy() *Policy {
+	return NewPolicy(map[string][]string{auth.RoleAdmin: {"*"}})
+}
+
+// LoadPolicy reads {"roles": {"<role>": ["<permission>", ...]}} from path.
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read rbac config: %w", err)
+	}
+	var cfg struct {
+		Roles map[string][]string `json:"roles"`
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse rbac config: %w", err)
+	}
+	return NewPolicy(cfg.Roles), nil
+}
+
+// Allows reports whether any of p's roles grants perm.
+func (pol *Policy) Allows(p *auth.Principal, perm string) bool {
+	for _, role := range p.Roles {
+		for _, granted := range pol.roles[role] {
+			if granted == "*" || granted == perm {
+				return true
+			}
+			if prefix, ok := strings.CutSuffix(granted, "*"); ok && strings.HasPrefix(perm, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Require rejects callers whose roles do not grant perm: 401 when there is
+// no principal at all, 403 otherwise.
+func Require(pol *Policy, perm string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, ok := auth.FromContext(c.Request.Context())
+		if !ok {
+			writeProblem(c, http.StatusUnauthorized, CodeUnauthenticated, "credentials required")
+			return
+		}
+		if !pol.Allows(p, perm) {
+			writeProblem(c, http.StatusForbidden, CodeForbidden, "missing permission "+perm)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,167 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"order-service/internal/domain"
+	"order-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WebhookHandler serves partner webhook subscriptions and their delivery
+// logs. Customers see only their own endpoints.
+type WebhookHandler struct {
+	service *services.WebhookService
+}
+
+func NewWebhookHandler(s *services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{service: s}
+}
+
+func (h *WebhookHandler) RegisterRoutes(r *gin.Engine) {
+	r.POST("/webhooks", h.CreateEndpoint)
+	r.GET("/webhooks", h.ListEndpoints)
+	r.GET("/webhooks/:id", h.GetEndpoint)
+	r.PATCH("/webhooks/:id", h.UpdateEndpoint)
+	r.DELETE("/webhooks/:id", h.DeleteEndpoint)
+	r.GET("/webhooks/:id/deliveries", h.ListDeliveries)
+	r.GET("/webhooks/:id/deliveries/:deliveryId", h.GetDelivery)
+}
+
+func (r WebhookEndpointRequest) input() services.WebhookEndpointInput {
+	return services.WebhookEndpointInput{
+		URL:        r.URL,
+		EventTypes: r.EventTypes,
+		Secret:     r.Secret,
+		CustomerID: r.CustomerID,
+		Enabled:    r.Enabled,
+	}
+}
+
+// CreateEndpoint registers an endpoint. The response carries the signing
+// secret, which is not shown again.
+func (h *WebhookHandler) CreateEndpoint(c *gin.Context) {
+	var req WebhookEndpointRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	created, err := h.service.CreateEndpoint(c.Request.Context(), req.input())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+func (h *WebhookHandler) ListEndpoints(c *gin.Context) {
+	endpoints, err := h.service.ListEndpoints(c.Request.Context())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, endpoints)
+}
+
+func (h *WebhookHandler) GetEndpoint(c *gin.Context) {
+	id, ok := parseWebhookID(c, "id")
+	if !ok {
+		return
+	}
+	e, err := h.service.GetEndpoint(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, e)
+}
+
+// UpdateEndpoint changes the fields present in the body. Setting enabled
+// to true revives an endpoint that was disabled for failing.
+func (h *WebhookHandler) UpdateEndpoint(c *gin.Context) {
+	id, ok := parseWebhookID(c, "id")
+	if !ok {
+		return
+	}
+	var req WebhookEndpointRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	e, err := h.service.UpdateEndpoint(c.Request.Context(), id, req.input())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, e)
+}
+
+func (h *WebhookHandler) DeleteEndpoint(c *gin.Context) {
+	id, ok := parseWebhookID(c, "id")
+	if !ok {
+		return
+	}
+	if err := h.service.DeleteEndpoint(c.Request.Context(), id); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListDeliveries pages through an endpoint's deliveries, newest first.
+// ?before=<id> continues after the last delivery of the previous page and
+// ?status= filters by pending, delivered or failed.
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	id, ok := parseWebhookID(c, "id")
+	if !ok {
+		return
+	}
+	limit, ok := parseLimit(c)
+	if !ok {
+		return
+	}
+	var before uint64
+	if raw := c.Query("before"); raw != "" {
+		var err error
+		if before, err = strconv.ParseUint(raw, 10, 64); err != nil {
+			writeProblem(c, http.StatusBadRequest, CodeInvalidParameter, "before must be a delivery id")
+			return
+		}
+	}
+	status := domain.WebhookDeliveryStatus(c.Query("status"))
+	deliveries, err := h.service.ListDeliveries(c.Request.Context(), id, status, before, limit)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// GetDelivery returns a delivery with the log of its attempts.
+func (h *WebhookHandler) GetDelivery(c *gin.Context) {
+	id, ok := parseWebhookID(c, "id")
+	if !ok {
+		return
+	}
+	deliveryID, ok := parseWebhookID(c, "deliveryId")
+	if !ok {
+		return
+	}
+	d, err := h.service.GetDelivery(c.Request.Context(), id, deliveryID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, d)
+}
+
+func parseWebhookID(c *gin.Context, param string) (uint64, bool) {
+	id, err := strconv.ParseUint(c.Param(param), 10, 64)
+	if err != nil || id == 0 {
+		writeProblem(c, http.StatusBadRequest, CodeInvalidParameter, param+" must be a positive integer")
+		return 0, false
+	}
+	return id, true
+}
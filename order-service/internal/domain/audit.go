@@ -0,0 +1,18 @@
+package domain
+
+import "time"
+
+// AuditRecord is an append-only entry describing a privileged action.
+type AuditRecord struct {
+	ID         uint64    `json:"id" gorm:"primaryKey;autoIncrement;column:id"`
+	Actor      string    `json:"actor" gorm:"type:varchar(191);not null;index;column:actor"`
+	Action     string    `json:"action" gorm:"type:varchar(64);not null;index;column:action"`
+	Target     string    `json:"target,omitempty" gorm:"type:varchar(191);column:target"`
+	Detail     string    `json:"detail,omitempty" gorm:"type:text;column:detail"`
+	Outcome    string    `json:"outcome" gorm:"type:varchar(32);not null;column:outcome"`
+	OccurredAt time.Time `json:"occurredAt" gorm:"not null;index;column:occurred_at"`
+}
+
+func (AuditRecord) TableName() string {
+	return "audit_log"
+}
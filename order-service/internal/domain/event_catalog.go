@@ -0,0 +1,94 @@
+package domain
+
+import "time"
+
+// Event is an event this service publishes. EventType is both the routing
+// key and the NestJS pattern. SchemaVersion is bumped whenever the payload
+// changes in a way consumers could notice; the previous version keeps its
+// schema file under api/events until every consumer has moved on.
+type Event interface {
+	EventType() string
+	SchemaVersion() int
+}
+
+const (
+	EventOrderCreated   = "order.created"
+	EventOrderCancelled = "order.cancelled"
+	EventOrderRefunded  = "order.refunded"
+)
+
+// OrderCreatedEvent asks the product service to reserve stock for a new
+// order.
+type OrderCreatedEvent struct {
+	OrderID    uint64    `json:"orderId"`
+	ProductId  uint64    `json:"productId"`
+	TotalPrice int64     `json:"totalPrice"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+func (OrderCreatedEvent) EventType() string  { return EventOrderCreated }
+func (OrderCreatedEvent) SchemaVersion() int { return 1 }
+
+// OrderCancelledEvent announces that a pending order was cancelled. The
+// product service gives back the stock it reserved for the order, if any.
+type OrderCancelledEvent struct {
+	OrderID uint64 `json:"orderId"`
+}
+
+func (OrderCancelledEvent) EventType() string  { return EventOrderCancelled }
+func (OrderCancelledEvent) SchemaVersion() int { return 1 }
+
+// OrderRefundedEvent announces money given back for an order. Amount is
+// this refund; RefundedAmount is everything refunded so far, which equals
+// TotalPrice once the order is fully refunded.
+type OrderRefundedEvent struct {
+	OrderID        uint64      `json:"orderId"`
+	RefundID       uint64      `json:"refundId"`
+	Amount         int64       `json:"amount"`
+	RefundedAmount int64       `json:"refundedAmount"`
+	TotalPrice     int64       `json:"totalPrice"`
+	Status         OrderStatus `json:"status"`
+	Reason         string      `json:"reason,omitempty"`
+}
+
+func (OrderRefundedEvent) EventType() string  { return EventOrderRefunded }
+func (OrderRefundedEvent) SchemaVersion() int { return 1 }
+
+// EventEnvelope is the wire format of a published event: the NestJS
+// message shape ({pattern, data, id}) plus the metadata every consumer can
+// rely on. EventID equals ID; it is repeated so non-NestJS consumers need
+// not know about the NestJS field.
+type EventEnvelope[E Event] struct {
+	Pattern       string    `json:"pattern"`
+	ID            string    `json:"id"`
+	EventID       string    `json:"eventId"`
+	SchemaVersion int       `json:"schemaVersion"`
+	OccurredAt    time.Time `json:"occurredAt"`
+	Data          E         `json:"data"`
+}
+
+// CatalogEntry describes one published event version.
+type CatalogEntry struct {
+	Type    string
+	Version int
+	// Envelope is a zero EventEnvelope of the event type, for schema
+	// generation.
+	Envelope any
+}
+
+// EventCatalog lists every event this service publishes, including the
+// commands sent by the fulfilment saga. Schemas in api/events are generated
+// from it.
+var EventCatalog = []CatalogEntry{
+	catalogEntry[OrderCreatedEvent](),
+	catalogEntry[OrderCancelledEvent](),
+	catalogEntry[OrderRefundedEvent](),
+	catalogEntry[StockReleaseCommand](),
+	catalogEntry[PaymentChargeCommand](),
+	catalogEntry[PaymentRefundCommand](),
+}
+
+func catalogEntry[E Event]() CatalogEntry {
+	var e E
+	return CatalogEntry{Type: e.EventType(), Version: e.SchemaVersion(), Envelope: EventEnvelope[E]{}}
+}
@@ -5,17 +5,45 @@ import "time"
 type OrderStatus string
 
 const (
-    StatusPending   OrderStatus = "pending"
-    StatusConfirmed OrderStatus = "confirmed"
-    StatusFailed    OrderStatus = "failed"
+    StatusPending           OrderStatus = "pending"
+    StatusConfirmed         OrderStatus = "confirmed"
+    StatusFailed            OrderStatus = "failed"
+    StatusCancelled         OrderStatus = "cancelled"
+    StatusPaid              OrderStatus = "paid"               // stock reserved and payment captured
+    StatusPartiallyRefunded OrderStatus = "partially_refunded" // part of the payment given back
+    StatusRefunded          OrderStatus = "refunded"           // payment given back in full
 )
 
+// IsFinal reports whether no further status transitions are expected.
+// Refunds may still follow a final status, but only on request.
+func (s OrderStatus) IsFinal() bool {
+    switch s {
+    case StatusConfirmed, StatusFailed, StatusCancelled, StatusPaid, StatusRefunded, StatusPartiallyRefunded:
+        return true
+    }
+    return false
+}
+
+// IsValid reports whether s is one of the statuses above.
+func (s OrderStatus) IsValid() bool {
+    switch s {
+    case StatusPending, StatusConfirmed, StatusFailed, StatusCancelled, StatusPaid, StatusRefunded, StatusPartiallyRefunded:
+        return true
+    }
+    return false
+}
+
 type Order struct {
     ID         uint64      `json:"id" gorm:"primaryKey;autoIncrement;column:id"`
     ProductId  uint64      `json:"productId" gorm:"not null;index;column:product_id"`  // Fixed naming
     TotalPrice int64       `json:"totalPrice" gorm:"not null;column:total_price"`      // Fixed naming
     Status     OrderStatus `json:"status" gorm:"type:varchar(20);default:'pending';column:status"` // Fixed enum
-    CreatedAt  time.Time   `json:"createdAt" gorm:"autoCreateTime;column:created_at"`   // Fixed naming
+    CreatedAt  time.Time   `json:"createdAt" gorm:"autoCreateTime;index:idx_orders_customer_created,priority:2;column:created_at"` // Fixed naming
+    CustomerID string      `json:"customerId,omitempty" gorm:"type:varchar(191);index:idx_orders_customer_created,priority:1;column:customer_id"`
+    CreatedBy  string      `json:"createdBy,omitempty" gorm:"type:varchar(191);column:created_by"` // principal id, empty for anonymous
+    Version    uint64      `json:"version" gorm:"not null;default:1;column:version"` // bumped by every update, see repository.VersionConflictError
+    PaymentID  string      `json:"paymentId,omitempty" gorm:"type:varchar(191);column:payment_id"` // authorization id, empty when nothing was charged
+    RefundedAmount int64   `json:"refundedAmount,omitempty" gorm:"not null;default:0;column:refunded_amount"` // sum of pending and succeeded refunds
 }
 
 func (Order) TableName() string {
@@ -2,9 +2,75 @@ package domain
 
 import "time"
 
-type OrderCreatedEvent struct {
-	OrderID    uint64    `json:"orderId"`
-	ProductId  uint64    `json:"productId"`
-	TotalPrice int64     `json:"totalPrice"`
-	CreatedAt  time.Time `json:"createdAt"`
-}
\ No newline at end of file
+// Replies consumed by the order service.
+const (
+	EventStockConfirmed = "order.qty_confirmed"
+	EventStockFailed    = "order.qty_failed"
+	EventPaymentCharged = "payment.charged"
+	EventPaymentFailed  = "payment.failed"
+)
+
+// StockConfirmedEvent is published by the product service as
+// order.qty_confirmed once stock has been reserved.
+type StockConfirmedEvent struct {
+	OrderID uint64 `json:"orderId"`
+}
+
+// StockFailedEvent is published by the product service as order.qty_failed
+// when the product is missing or out of stock.
+type StockFailedEvent struct {
+	OrderID uint64 `json:"orderId"`
+	Reason  string `json:"reason"`
+}
+
+// PaymentChargedEvent is published by the payment service as
+// payment.charged once an order has been paid for.
+type PaymentChargedEvent struct {
+	OrderID uint64 `json:"orderId"`
+}
+
+// PaymentFailedEvent is published by the payment service as payment.failed
+// when the charge was declined.
+type PaymentFailedEvent struct {
+	OrderID uint64 `json:"orderId"`
+	Reason  string `json:"reason"`
+}
+
+// OrderStatusChange describes a single status transition of an order.
+type OrderStatusChange struct {
+	OrderID    uint64      `json:"orderId"`
+	From       OrderStatus `json:"from"`
+	To         OrderStatus `json:"to"`
+	Reason     string      `json:"reason,omitempty"`
+	OccurredAt time.Time   `json:"occurredAt"`
+}
+
+// OrderStatusHistory is one row of order_status_history, written in the
+// same transaction as the status change it records.
+type OrderStatusHistory struct {
+	ID            uint64      `json:"id" gorm:"primaryKey;autoIncrement;column:id"`
+	OrderID       uint64      `json:"orderId" gorm:"not null;index;column:order_id"`
+	FromStatus    OrderStatus `json:"from" gorm:"type:varchar(20);not null;column:from_status"`
+	ToStatus      OrderStatus `json:"to" gorm:"type:varchar(20);not null;column:to_status"`
+	Reason        string      `json:"reason,omitempty" gorm:"type:varchar(255);column:reason"`
+	SourceEventID string      `json:"sourceEventId,omitempty" gorm:"type:varchar(191);column:source_event_id"`
+	Actor         string      `json:"actor" gorm:"type:varchar(191);not null;column:actor"`
+	CreatedAt     time.Time   `json:"createdAt" gorm:"not null;column:created_at"`
+}
+
+func (OrderStatusHistory) TableName() string {
+	return "order_status_history"
+}
+
+// InboxMessage marks a consumed broker message as applied. It is written in
+// the same transaction as the order update the message caused, so a
+// redelivery finds it and is skipped.
+type InboxMessage struct {
+	MessageID   string    `gorm:"primaryKey;type:varchar(191);column:message_id"`
+	Pattern     string    `gorm:"type:varchar(100);not null;column:pattern"`
+	ProcessedAt time.Time `gorm:"not null;index;column:processed_at"`
+}
+
+func (InboxMessage) TableName() string {
+	return "inbox_messages"
+}
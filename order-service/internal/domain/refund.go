@@ -0,0 +1,30 @@
+package domain
+
+import "time"
+
+type RefundStatus string
+
+const (
+	RefundPending   RefundStatus = "pending"   // amount reserved on the order, provider not done yet
+	RefundSucceeded RefundStatus = "succeeded" // money given back
+	RefundFailed    RefundStatus = "failed"    // provider refused; the amount was released again
+)
+
+// Refund is one row of refunds: money given back for a captured order.
+// Its amount is added to the order's RefundedAmount while it is pending, so
+// concurrent refunds can never add up to more than the order's total.
+type Refund struct {
+	ID            uint64       `json:"id" gorm:"primaryKey;autoIncrement;column:id"`
+	OrderID       uint64       `json:"orderId" gorm:"not null;index;column:order_id"`
+	Amount        int64        `json:"amount" gorm:"not null;column:amount"`
+	Reason        string       `json:"reason,omitempty" gorm:"type:varchar(255);column:reason"`
+	Status        RefundStatus `json:"status" gorm:"type:varchar(20);not null;column:status"`
+	FailureReason string       `json:"failureReason,omitempty" gorm:"type:varchar(255);column:failure_reason"`
+	Actor         string       `json:"actor" gorm:"type:varchar(191);not null;column:actor"`
+	CreatedAt     time.Time    `json:"createdAt" gorm:"not null;autoCreateTime:false;column:created_at"`
+	UpdatedAt     time.Time    `json:"updatedAt" gorm:"not null;autoUpdateTime:false;column:updated_at"`
+}
+
+func (Refund) TableName() string {
+	return "refunds"
+}
@@ -0,0 +1,92 @@
+package domain
+
+import "time"
+
+// Commands sent by the fulfilment saga.
+const (
+	CommandStockRelease  = "stock.release"
+	CommandPaymentCharge = "payment.charge"
+	CommandPaymentRefund = "payment.refund"
+)
+
+// StockReleaseCommand asks the product service to give back the stock it
+// reserved for an order whose saga failed later on. It must be safe to
+// receive for an order nothing was reserved for.
+type StockReleaseCommand struct {
+	OrderID   uint64 `json:"orderId"`
+	ProductId uint64 `json:"productId"`
+}
+
+func (StockReleaseCommand) EventType() string  { return CommandStockRelease }
+func (StockReleaseCommand) SchemaVersion() int { return 1 }
+
+// PaymentChargeCommand asks the payment service to charge for an order. It
+// answers with payment.charged or payment.failed.
+type PaymentChargeCommand struct {
+	OrderID uint64 `json:"orderId"`
+	Amount  int64  `json:"amount"`
+}
+
+func (PaymentChargeCommand) EventType() string  { return CommandPaymentCharge }
+func (PaymentChargeCommand) SchemaVersion() int { return 1 }
+
+// PaymentRefundCommand asks the payment service to refund the charge of an
+// order. Like every compensation it may arrive for an order that was never
+// charged.
+type PaymentRefundCommand struct {
+	OrderID uint64 `json:"orderId"`
+	Amount  int64  `json:"amount"`
+}
+
+func (PaymentRefundCommand) EventType() string  { return CommandPaymentRefund }
+func (PaymentRefundCommand) SchemaVersion() int { return 1 }
+
+type SagaState string
+
+const (
+	SagaRunning      SagaState = "running"
+	SagaCompensating SagaState = "compensating"
+	SagaCompleted    SagaState = "completed"
+	SagaAborted      SagaState = "aborted"
+)
+
+// IsFinal reports whether the saga has nothing left to do.
+func (s SagaState) IsFinal() bool {
+	return s == SagaCompleted || s == SagaAborted
+}
+
+// Saga is the persisted progress of an order's fulfilment saga. While
+// running, Step is the step waiting for its reply; while compensating, it
+// is the next step to undo. Updates are compare-and-swap on Version.
+type Saga struct {
+	ID            uint64    `json:"id" gorm:"primaryKey;autoIncrement;column:id"`
+	OrderID       uint64    `json:"orderId" gorm:"not null;uniqueIndex;column:order_id"`
+	State         SagaState `json:"state" gorm:"type:varchar(20);not null;index:idx_sagas_state_updated,priority:1;column:state"`
+	Step          int       `json:"step" gorm:"not null;column:step"`
+	StepName      string    `json:"stepName,omitempty" gorm:"type:varchar(50);column:step_name"`
+	Attempts      int       `json:"attempts" gorm:"not null;column:attempts"` // commands sent for the current step
+	FailureReason string    `json:"failureReason,omitempty" gorm:"type:varchar(255);column:failure_reason"`
+	Version       uint64    `json:"version" gorm:"not null;default:1;column:version"`
+	CreatedAt     time.Time `json:"createdAt" gorm:"autoCreateTime:false;not null;column:created_at"`
+	UpdatedAt     time.Time `json:"updatedAt" gorm:"autoUpdateTime:false;not null;index:idx_sagas_state_updated,priority:2;column:updated_at"`
+}
+
+func (Saga) TableName() string {
+	return "sagas"
+}
+
+// SagaLogEntry is one row of saga_log, written with the saga update it
+// describes: a command sent, a reply applied, a compensation or the end of
+// the saga.
+type SagaLogEntry struct {
+	ID        uint64    `json:"id" gorm:"primaryKey;autoIncrement;column:id"`
+	SagaID    uint64    `json:"sagaId" gorm:"not null;index;column:saga_id"`
+	Step      string    `json:"step,omitempty" gorm:"type:varchar(50);column:step"`
+	Action    string    `json:"action" gorm:"type:varchar(20);not null;column:action"`
+	Detail    string    `json:"detail,omitempty" gorm:"type:varchar(255);column:detail"`
+	CreatedAt time.Time `json:"createdAt" gorm:"not null;column:created_at"`
+}
+
+func (SagaLogEntry) TableName() string {
+	return "saga_log"
+}
@@ -0,0 +1,141 @@
+package domain
+
+import "time"
+
+// WebhookEventPrefix names webhook events after the status an order moved
+// to, e.g. order.confirmed or order.failed.
+const WebhookEventPrefix = "order."
+
+// WebhookEventAll subscribes an endpoint to every webhook event.
+const WebhookEventAll = "*"
+
+// WebhookEventType is the webhook event sent when an order moves to s.
+func WebhookEventType(s OrderStatus) string {
+	return WebhookEventPrefix + string(s)
+}
+
+// WebhookEndpoint is a partner URL that receives signed order status
+// changes. An endpoint with a CustomerID only hears about that customer's
+// orders; one without hears about every order.
+type WebhookEndpoint struct {
+	ID         uint64   `json:"id" gorm:"primaryKey;autoIncrement;column:id"`
+	URL        string   `json:"url" gorm:"type:varchar(2048);not null;column:url"`
+	EventTypes []string `json:"eventTypes" gorm:"type:varchar(1024);serializer:json;not null;column:event_types"`
+	Secret     string   `json:"-" gorm:"type:varchar(255);not null;column:secret"`
+	CustomerID string   `json:"customerId,omitempty" gorm:"type:varchar(191);index;column:customer_id"`
+	CreatedBy  string   `json:"createdBy,omitempty" gorm:"type:varchar(191);column:created_by"`
+	Enabled    bool     `json:"enabled" gorm:"not null;column:enabled"`
+	// ConsecutiveFailures counts failed attempts since the last success;
+	// the endpoint is disabled when it reaches the configured limit.
+	ConsecutiveFailures int        `json:"consecutiveFailures" gorm:"not null;default:0;column:consecutive_failures"`
+	DisabledAt          *time.Time `json:"disabledAt,omitempty" gorm:"column:disabled_at"`
+	DisabledReason      string     `json:"disabledReason,omitempty" gorm:"type:varchar(255);column:disabled_reason"`
+	CreatedAt           time.Time  `json:"createdAt" gorm:"autoCreateTime:false;not null;column:created_at"`
+	UpdatedAt           time.Time  `json:"updatedAt" gorm:"autoUpdateTime:false;not null;column:updated_at"`
+}
+
+func (WebhookEndpoint) TableName() string {
+	return "webhook_endpoints"
+}
+
+// Subscribes reports whether e wants events of the given type.
+func (e *WebhookEndpoint) Subscribes(eventType string) bool {
+	for _, t := range e.EventTypes {
+		if t == WebhookEventAll || t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookPending   WebhookDeliveryStatus = "pending"   // waiting for its next attempt
+	WebhookDelivered WebhookDeliveryStatus = "delivered" // the endpoint answered 2xx
+	WebhookFailed    WebhookDeliveryStatus = "failed"    // attempts used up
+)
+
+// WebhookDelivery is one event queued for one endpoint. Pending deliveries
+// are picked up once NextAttemptAt has passed.
+type WebhookDelivery struct {
+	ID             uint64                `json:"id" gorm:"primaryKey;autoIncrement;column:id"`
+	EndpointID     uint64                `json:"endpointId" gorm:"not null;index;column:endpoint_id"`
+	EventID        string                `json:"eventId" gorm:"type:varchar(64);not null;column:event_id"`
+	EventType      string                `json:"eventType" gorm:"type:varchar(64);not null;column:event_type"`
+	OrderID        uint64                `json:"orderId" gorm:"not null;column:order_id"`
+	Payload        string                `json:"payload" gorm:"type:text;not null;column:payload"`
+	Status         WebhookDeliveryStatus `json:"status" gorm:"type:varchar(20);not null;index:idx_webhook_deliveries_due,priority:1;column:status"`
+	Attempts       int                   `json:"attempts" gorm:"not null;default:0;column:attempts"`
+	NextAttemptAt  time.Time             `json:"nextAttemptAt" gorm:"not null;index:idx_webhook_deliveries_due,priority:2;column:next_attempt_at"`
+	LastStatusCode int                   `json:"lastStatusCode,omitempty" gorm:"column:last_status_code"`
+	LastError      string                `json:"lastError,omitempty" gorm:"type:varchar(255);column:last_error"`
+	DeliveredAt    *time.Time            `json:"deliveredAt,omitempty" gorm:"column:delivered_at"`
+	CreatedAt      time.Time             `json:"createdAt" gorm:"autoCreateTime:false;not null;column:created_at"`
+	UpdatedAt      time.Time             `json:"updatedAt" gorm:"autoUpdateTime:false;not null;column:updated_at"`
+}
+
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
+
+// WebhookAttempt is one row of the delivery log: a single POST of a
+// delivery and what came back.
+type WebhookAttempt struct {
+	ID         uint64    `json:"id" gorm:"primaryKey;autoIncrement;column:id"`
+	DeliveryID uint64    `json:"deliveryId" gorm:"not null;index;column:delivery_id"`
+	Attempt    int       `json:"attempt" gorm:"not null;column:attempt"`
+	StatusCode int       `json:"statusCode,omitempty" gorm:"column:status_code"`
+	Error      string    `json:"error,omitempty" gorm:"type:varchar(255);column:error"`
+	DurationMs int64     `json:"durationMs" gorm:"not null;column:duration_ms"`
+	CreatedAt  time.Time `json:"createdAt" gorm:"autoCreateTime:false;not null;column:created_at"`
+}
+
+func (WebhookAttempt) TableName() string {
+	return "webhook_attempts"
+}
+
+// WebhookPayload is the JSON body POSTed to an endpoint. ID is the same
+// for every attempt, so receivers can drop duplicates.
+type WebhookPayload struct {
+	ID         string            `json:"id"`
+	Type       string            `json:"type"`
+	OccurredAt time.Time         `json:"occurredAt"`
+	Data       WebhookStatusData `json:"data"`
+}
+
+// WebhookStatusData is the status change a webhook reports, with the order
+// as it was right after the change.
+type WebhookStatusData struct {
+	Order  WebhookOrder `json:"order"`
+	From   OrderStatus  `json:"from"`
+	Reason string       `json:"reason,omitempty"`
+}
+
+// WebhookOrder is the part of an order sent to partners. It leaves out
+// internal fields such as the payment authorization and the principal that
+// created the order.
+type WebhookOrder struct {
+	ID             uint64      `json:"id"`
+	ProductId      uint64      `json:"productId"`
+	CustomerID     string      `json:"customerId,omitempty"`
+	TotalPrice     int64       `json:"totalPrice"`
+	RefundedAmount int64       `json:"refundedAmount,omitempty"`
+	Status         OrderStatus `json:"status"`
+	Version        uint64      `json:"version"`
+	CreatedAt      time.Time   `json:"createdAt"`
+}
+
+// NewWebhookOrder returns the webhook view of o.
+func NewWebhookOrder(o *Order) WebhookOrder {
+	return WebhookOrder{
+		ID:             o.ID,
+		ProductId:      o.ProductId,
+		CustomerID:     o.CustomerID,
+		TotalPrice:     o.TotalPrice,
+		RefundedAmount: o.RefundedAmount,
+		Status:         o.Status,
+		Version:        o.Version,
+		CreatedAt:      o.CreatedAt,
+	}
+}
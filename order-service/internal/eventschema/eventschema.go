@@ -0,0 +1,47 @@
+// Package eventschema generates JSON Schema documents for the published
+// event catalog.
+package eventschema
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"order-service/internal/domain"
+
+	"github.com/invopop/jsonschema"
+)
+
+// FileName is the schema file for an event version, e.g.
+// "order.created.v1.schema.json".
+func FileName(e domain.CatalogEntry) string {
+	return fmt.Sprintf("%s.v%d.schema.json", e.Type, e.Version)
+}
+
+// Generate returns the indented JSON Schema of e's envelope, with pattern
+// and schemaVersion pinned to the entry's values.
+func Generate(e domain.CatalogEntry) ([]byte, error) {
+	r := &jsonschema.Reflector{
+		ExpandedStruct: true,
+		DoNotReference: true,
+	}
+	s := r.Reflect(e.Envelope)
+	s.ID = jsonschema.ID(FileName(e))
+	s.Title = fmt.Sprintf("%s v%d", e.Type, e.Version)
+
+	pattern, ok := s.Properties.Get("pattern")
+	if !ok {
+		return nil, fmt.Errorf("%s: envelope has no pattern", e.Type)
+	}
+	pattern.Const = e.Type
+	version, ok := s.Properties.Get("schemaVersion")
+	if !ok {
+		return nil, fmt.Errorf("%s: envelope has no schemaVersion", e.Type)
+	}
+	version.Const = e.Version
+
+	out, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
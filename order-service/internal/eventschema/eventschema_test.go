@@ -0,0 +1,31 @@
+package eventschema
+
+import (
+	"testing"
+
+	"order-service/api/events"
+	"order-service/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaFilesAreUpToDate(t *testing.T) {
+	for _, e := range domain.EventCatalog {
+		want, err := Generate(e)
+		require.NoError(t, err)
+
+		got, err := events.Schemas.ReadFile(FileName(e))
+		require.NoError(t, err, "missing schema; run go generate ./api/events")
+		assert.Equal(t, string(want), string(got), "%s is stale; run go generate ./api/events", FileName(e))
+	}
+}
+
+func TestCatalogEntriesAreUnique(t *testing.T) {
+	seen := map[string]bool{}
+	for _, e := range domain.EventCatalog {
+		name := FileName(e)
+		assert.False(t, seen[name], "duplicate catalog entry %s", name)
+		seen[name] = true
+	}
+}
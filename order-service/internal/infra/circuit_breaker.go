@@ -0,0 +1,193 @@
+package infra
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("product service circuit open")
+
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
+type BreakerConfig struct {
+	WindowSize        int           // number of recent calls considered
+	MinRequests       int           // calls required before the breaker may trip
+	FailureRate       float64       // 0..1, trips when exceeded
+	SlowCallThreshold time.Duration // calls slower than this count as slow
+	SlowCallRate      float64       // 0..1, trips when exceeded
+	OpenTimeout       time.Duration // time spent open before probing
+	HalfOpenProbes    int           // successful probes needed to close again
+}
+
+// BreakerCall is returned by Allow and passed back to Record. It ties a
+// result to the breaker state the call was admitted in.
+type BreakerCall struct {
+	generation uint64
+	probe      bool
+}
+
+type callOutcome struct {
+	failed bool
+	slow   bool
+}
+
+// CircuitBreaker tracks the outcome of the last WindowSize calls and opens
+// when either the failure rate or the slow-call rate crosses its threshold.
+type CircuitBreaker struct {
+	cfg   BreakerConfig
+	clock Clock
+
+	mu             sync.Mutex
+	state          BreakerState
+	generation     uint64 // bumped on every transition
+	window         []callOutcome
+	next           int
+	filled         int
+	openedAt       time.Time
+	probesInFlight int
+	probeSuccesses int
+	onStateChange  func(from, to BreakerState)
+}
+
+func NewCircuitBreaker(cfg BreakerConfig, clock Clock) *CircuitBreaker {
+	if clock == nil {
+		clock = RealClock()
+	}
+	if cfg.WindowSize <= 0 {
+		cfg.WindowSize = 1
+	}
+	if cfg.HalfOpenProbes <= 0 {
+		cfg.HalfOpenProbes = 1
+	}
+	return &CircuitBreaker{
+		cfg:    cfg,
+		clock:  clock,
+		window: make([]callOutcome, cfg.WindowSize),
+	}
+}
+
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.maybeHalfOpen()
+	return b.state
+}
+
+// Allow reports whether a call may proceed. Callers that get true must
+// report the result through Record with the returned BreakerCall.
+func (b *CircuitBreaker) Allow() (BreakerCall, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.maybeHalfOpen()
+	call := BreakerCall{generation: b.generation}
+	switch b.state {
+	case BreakerOpen:
+		return call, false
+	case BreakerHalfOpen:
+		if b.probesInFlight >= b.cfg.HalfOpenProbes {
+			return call, false
+		}
+		b.probesInFlight++
+		call.probe = true
+		return call, true
+	default:
+		return call, true
+	}
+}
+
+// Record reports the result of a call admitted by Allow. Results of calls
+// admitted before the last state change are ignored, so a slow call from
+// the closed state cannot count as a probe.
+func (b *CircuitBreaker) Record(call BreakerCall, failed bool, latency time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if call.generation != b.generation {
+		return
+	}
+	slow := b.cfg.SlowCallThreshold > 0 && latency >= b.cfg.SlowCallThreshold
+
+	if call.probe {
+		b.probesInFlight--
+		if failed || slow {
+			b.transition(BreakerOpen)
+			return
+		}
+		b.probeSuccesses++
+		if b.probeSuccesses >= b.cfg.HalfOpenProbes {
+			b.transition(BreakerClosed)
+		}
+		return
+	}
+
+	b.window[b.next] = callOutcome{failed: failed, slow: slow}
+	b.next = (b.next + 1) % len(b.window)
+	if b.filled < len(b.window) {
+		b.filled++
+	}
+	if b.filled < b.cfg.MinRequests {
+		return
+	}
+
+	var failures, slowCalls int
+	for i := 0; i < b.filled; i++ {
+		if b.window[i].failed {
+			failures++
+		}
+		if b.window[i].slow {
+			slowCalls++
+		}
+	}
+	total := float64(b.filled)
+	if (b.cfg.FailureRate > 0 && float64(failures)/total >= b.cfg.FailureRate) ||
+		(b.cfg.SlowCallRate > 0 && float64(slowCalls)/total >= b.cfg.SlowCallRate) {
+		b.transition(BreakerOpen)
+	}
+}
+
+// maybeHalfOpen must be called with mu held.
+func (b *CircuitBreaker) maybeHalfOpen() {
+	if b.state == BreakerOpen && b.clock.Now().Sub(b.openedAt) >= b.cfg.OpenTimeout {
+		b.transition(BreakerHalfOpen)
+	}
+}
+
+// transition must be called with mu held.
+func (b *CircuitBreaker) transition(to BreakerState) {
+	from := b.state
+	if from == to {
+		return
+	}
+	b.state = to
+	b.generation++
+	b.probesInFlight = 0
+	b.probeSuccesses = 0
+	switch to {
+	case BreakerOpen:
+		b.openedAt = b.clock.Now()
+	case BreakerClosed:
+		b.next, b.filled = 0, 0
+	}
+	if b.onStateChange != nil {
+		b.onStateChange(from, to)
+	}
+}
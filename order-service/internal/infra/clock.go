@@ -0,0 +1,20 @@
+package infra
+
+import "time"
+
+// Clock abstracts time so retry backoff, breaker timeouts and hedge delays
+// can be driven by a fake clock in tests.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// RealClock returns a Clock backed by the time package.
+func RealClock() Clock {
+	return realClock{}
+}
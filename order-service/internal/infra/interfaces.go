@@ -4,6 +4,35 @@ import "context"
 
 type ProductClientInterface interface {
 	GetProductById(ctx context.Context, id uint64) (*ProductInfo, error)
+	// GetProductsByIds returns the products that exist, keyed by id.
+	// Unknown ids are simply absent from the map.
+	GetProductsByIds(ctx context.Context, ids []uint64) (map[uint64]*ProductInfo, error)
 }
 
-var _ ProductClientInterface = (*ProductClient)(nil)
\ No newline at end of file
+type RateLimiter interface {
+	// Allow takes one token from key's bucket if available.
+	Allow(ctx context.Context, key string, rl RateLimit) (RateLimitResult, error)
+}
+
+// PaymentProvider moves money for orders. Authorize holds an amount on the
+// customer's payment method and returns the authorization id; Capture
+// takes the held amount, Void releases it and Refund gives back part or all
+// of a capture. Calls are safe to repeat: capturing a captured
+// authorization or voiding a voided one succeeds, and a refund whose
+// reference was seen before is not applied again.
+type PaymentProvider interface {
+	Authorize(ctx context.Context, amount int64) (string, error)
+	Capture(ctx context.Context, authID string) error
+	Void(ctx context.Context, authID string) error
+	Refund(ctx context.Context, authID string, amount int64, reference string) error
+}
+
+var _ ProductClientInterface = (*ProductClient)(nil)
+
+var _ PaymentProvider = (*FakePaymentProvider)(nil)
+
+var (
+	_ RateLimiter = (*RedisRateLimiter)(nil)
+	_ RateLimiter = (*MemoryRateLimiter)(nil)
+	_ RateLimiter = (*FallbackRateLimiter)(nil)
+)
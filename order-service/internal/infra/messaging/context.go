@@ -0,0 +1,36 @@
+package messaging
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+)
+
+// NewID returns a random (version 4) UUID for use as a message id.
+func NewID() string {
+	var b [16]byte
+	rand.Read(b[:]) // cannot fail since Go 1.24
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// MessageInfo describes the broker message a handler is processing.
+type MessageInfo struct {
+	ID    string
+	Topic string
+}
+
+type messageKey struct{}
+
+// WithMessage attaches the message being handled to ctx.
+func WithMessage(ctx context.Context, m MessageInfo) context.Context {
+	return context.WithValue(ctx, messageKey{}, m)
+}
+
+// MessageFromContext returns the message attached by the broker, if the
+// caller is running inside a handler.
+func MessageFromContext(ctx context.Context) (MessageInfo, bool) {
+	m, ok := ctx.Value(messageKey{}).(MessageInfo)
+	return m, ok
+}
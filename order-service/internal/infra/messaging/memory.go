@@ -0,0 +1,212 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrBrokerClosed = errors.New("broker closed")
+
+type MemoryBrokerConfig struct {
+	// MaxRedeliveries bounds how often a requeued message is handed to
+	// the same subscription again before it is dead-lettered.
+	MaxRedeliveries int
+}
+
+func DefaultMemoryBrokerConfig() MemoryBrokerConfig {
+	return MemoryBrokerConfig{MaxRedeliveries: 5}
+}
+
+// DeadLetter is a message a subscription gave up on.
+type DeadLetter struct {
+	Message  Message
+	Pattern  string // of the subscription that gave up
+	Attempts int
+	Reason   string
+}
+
+// MemoryBroker is an in-process Broker. Every subscription behaves like
+// its own queue bound to the pattern: it sees each matching message once,
+// in publish order, and handles them one at a time on its own goroutine.
+// Requeued messages go to the back of that queue without delay.
+type MemoryBroker struct {
+	cfg    MemoryBrokerConfig
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	subs    []*memorySubscription
+	pending int // queued or being handled, across subscriptions
+	dead    []DeadLetter
+	closed  bool
+	wg      sync.WaitGroup
+
+	published    atomic.Int64
+	delivered    atomic.Int64
+	redelivered  atomic.Int64
+	deadLettered atomic.Int64
+}
+
+type memorySubscription struct {
+	pattern string
+	handler Handler
+	queue   []queuedMessage
+}
+
+type queuedMessage struct {
+	msg     Message
+	attempt int
+}
+
+var _ Broker = (*MemoryBroker)(nil)
+
+func NewMemoryBroker(cfg MemoryBrokerConfig) *MemoryBroker {
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &MemoryBroker{cfg: cfg, ctx: ctx, cancel: cancel}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// Publish queues data for every matching subscription. Messages that match
+// none are dropped, as with an exchange without bindings.
+func (b *MemoryBroker) Publish(ctx context.Context, topic string, data any) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %v", err)
+	}
+	msg := Message{ID: NewID(), Topic: topic, Data: body}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return ErrBrokerClosed
+	}
+	for _, s := range b.subs {
+		if MatchTopic(s.pattern, topic) {
+			s.queue = append(s.queue, queuedMessage{msg: msg, attempt: 1})
+			b.pending++
+		}
+	}
+	b.published.Add(1)
+	b.cond.Broadcast()
+	return nil
+}
+
+// Subscribe starts delivering messages published from now on. Subscribing
+// after Close is a no-op.
+func (b *MemoryBroker) Subscribe(pattern string, h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	s := &memorySubscription{pattern: pattern, handler: h}
+	b.subs = append(b.subs, s)
+	b.wg.Add(1)
+	go b.run(s)
+}
+
+func (b *MemoryBroker) run(s *memorySubscription) {
+	defer b.wg.Done()
+	for {
+		b.mu.Lock()
+		for len(s.queue) == 0 && !b.closed {
+			b.cond.Wait()
+		}
+		if b.closed {
+			b.mu.Unlock()
+			return
+		}
+		next := s.queue[0]
+		s.queue = s.queue[1:]
+		b.mu.Unlock()
+
+		b.deliver(s, next)
+	}
+}
+
+func (b *MemoryBroker) deliver(s *memorySubscription, q queuedMessage) {
+	d := NewSettlement(q.msg)
+	ctx := WithMessage(b.ctx, MessageInfo{ID: q.msg.ID, Topic: q.msg.Topic})
+	s.handler(ctx, d)
+	b.delivered.Add(1)
+
+	result := d.Result()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case result == nil, b.closed:
+	case !IsPermanent(result) && q.attempt <= b.cfg.MaxRedeliveries:
+		q.attempt++
+		s.queue = append(s.queue, q)
+		b.pending++
+		b.redelivered.Add(1)
+	default:
+		log.Printf("Dead-lettering '%s' message %s for '%s' after %d attempts: %v", q.msg.Topic, q.msg.ID, s.pattern, q.attempt, result)
+		b.dead = append(b.dead, DeadLetter{Message: q.msg, Pattern: s.pattern, Attempts: q.attempt, Reason: result.Error()})
+		b.deadLettered.Add(1)
+	}
+	b.pending--
+	b.cond.Broadcast()
+}
+
+// WaitIdle blocks until every published message has been settled by all
+// subscriptions, including messages published by handlers along the way.
+func (b *MemoryBroker) WaitIdle(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		idle := b.pending == 0
+		b.mu.Unlock()
+		if idle {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+// DeadLetters returns the messages subscriptions gave up on, oldest first.
+func (b *MemoryBroker) DeadLetters() []DeadLetter {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]DeadLetter(nil), b.dead...)
+}
+
+func (b *MemoryBroker) GetStats() map[string]interface{} {
+	b.mu.Lock()
+	pending, subs := b.pending, len(b.subs)
+	b.mu.Unlock()
+	return map[string]interface{}{
+		"subscriptions": subs,
+		"pending":       pending,
+		"published":     b.published.Load(),
+		"delivered":     b.delivered.Load(),
+		"redelivered":   b.redelivered.Load(),
+		"dead_lettered": b.deadLettered.Load(),
+	}
+}
+
+// Close stops delivery and waits for running handlers to return. Queued
+// messages are discarded.
+func (b *MemoryBroker) Close() {
+	b.mu.Lock()
+	b.closed = true
+	for _, s := range b.subs {
+		b.pending -= len(s.queue)
+		s.queue = nil
+	}
+	b.cond.Broadcast()
+	b.mu.Unlock()
+	b.cancel()
+	b.wg.Wait()
+}
@@ -0,0 +1,150 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func waitIdle(t *testing.T, b *MemoryBroker) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	require.NoError(t, b.WaitIdle(ctx))
+}
+
+func TestMatchTopic(t *testing.T) {
+	tests := []struct {
+		pattern, topic string
+		want           bool
+	}{
+		{"order.created", "order.created", true},
+		{"order.created", "order.cancelled", false},
+		{"order.*", "order.created", true},
+		{"order.*", "order", false},
+		{"order.*", "order.item.added", false},
+		{"*.created", "order.created", true},
+		{"order.#", "order", true},
+		{"order.#", "order.item.added", true},
+		{"#", "anything.at.all", true},
+		{"#.added", "order.item.added", true},
+		{"order.#.added", "order.added", true},
+		{"order.#.added", "order.item.removed", false},
+		{"order.*.#", "order", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, MatchTopic(tt.pattern, tt.topic), "%s vs %s", tt.pattern, tt.topic)
+	}
+}
+
+func TestMemoryBroker_FansOutToMatchingSubscriptions(t *testing.T) {
+	b := NewMemoryBroker(DefaultMemoryBrokerConfig())
+	defer b.Close()
+
+	var mu sync.Mutex
+	got := map[string][]string{}
+	record := func(name string) Handler {
+		return func(ctx context.Context, d Delivery) {
+			info, ok := MessageFromContext(ctx)
+			require.True(t, ok)
+			assert.Equal(t, d.Message().ID, info.ID)
+			mu.Lock()
+			got[name] = append(got[name], d.Message().Topic+" "+string(d.Message().Data))
+			mu.Unlock()
+			d.Ack()
+		}
+	}
+	b.Subscribe("order.created", record("exact"))
+	b.Subscribe("order.*", record("star"))
+	b.Subscribe("payment.#", record("other"))
+
+	require.NoError(t, b.Publish(context.Background(), "order.created", map[string]int{"orderId": 1}))
+	require.NoError(t, b.Publish(context.Background(), "order.cancelled", map[string]int{"orderId": 2}))
+	require.NoError(t, b.Publish(context.Background(), "stock.reserved", nil))
+	waitIdle(t, b)
+
+	assert.Equal(t, []string{`order.created {"orderId":1}`}, got["exact"])
+	assert.Equal(t, []string{`order.created {"orderId":1}`, `order.cancelled {"orderId":2}`}, got["star"])
+	assert.Empty(t, got["other"])
+
+	stats := b.GetStats()
+	assert.Equal(t, int64(3), stats["published"])
+	assert.Equal(t, int64(3), stats["delivered"])
+}
+
+func TestMemoryBroker_RequeueThenDeadLetter(t *testing.T) {
+	b := NewMemoryBroker(MemoryBrokerConfig{MaxRedeliveries: 2})
+	defer b.Close()
+
+	attempts := 0
+	b.Subscribe("order.qty_confirmed", func(_ context.Context, d Delivery) {
+		attempts++
+		d.Nack(true, errors.New("db down"))
+	})
+	require.NoError(t, b.Publish(context.Background(), "order.qty_confirmed", map[string]int{"orderId": 1}))
+	waitIdle(t, b)
+
+	assert.Equal(t, 3, attempts)
+	dead := b.DeadLetters()
+	require.Len(t, dead, 1)
+	assert.Equal(t, 3, dead[0].Attempts)
+	assert.Equal(t, "order.qty_confirmed", dead[0].Pattern)
+	assert.Equal(t, "message nacked: db down", dead[0].Reason)
+	assert.Equal(t, int64(2), b.GetStats()["redelivered"])
+}
+
+func TestMemoryBroker_NackWithoutRequeueAndUnsettled(t *testing.T) {
+	b := NewMemoryBroker(MemoryBrokerConfig{MaxRedeliveries: 1})
+	defer b.Close()
+
+	var mu sync.Mutex
+	calls := map[string]int{}
+	b.Subscribe("#", func(_ context.Context, d Delivery) {
+		mu.Lock()
+		calls[d.Message().Topic]++
+		mu.Unlock()
+		switch d.Message().Topic {
+		case "poison":
+			d.Nack(false, errors.New("bad payload"))
+			assert.ErrorIs(t, d.Ack(), ErrAlreadySettled)
+		case "forgotten":
+			// Returning without settling counts as a requeue
+		}
+	})
+	require.NoError(t, b.Publish(context.Background(), "poison", 1))
+	require.NoError(t, b.Publish(context.Background(), "forgotten", 2))
+	waitIdle(t, b)
+
+	assert.Equal(t, map[string]int{"poison": 1, "forgotten": 2}, calls)
+	dead := b.DeadLetters()
+	require.Len(t, dead, 2)
+	assert.Equal(t, "message nacked: bad payload", dead[0].Reason)
+	assert.Equal(t, ErrUnsettled.Error(), dead[1].Reason)
+}
+
+func TestAckOnSuccess(t *testing.T) {
+	ok := NewSettlement(Message{Data: []byte(`{}`)})
+	AckOnSuccess(func(context.Context, json.RawMessage) error { return nil })(context.Background(), ok)
+	assert.NoError(t, ok.Result())
+
+	retry := NewSettlement(Message{})
+	AckOnSuccess(func(context.Context, json.RawMessage) error { return errors.New("timeout") })(context.Background(), retry)
+	assert.ErrorIs(t, retry.Result(), ErrNacked)
+	assert.False(t, IsPermanent(retry.Result()))
+
+	poison := NewSettlement(Message{})
+	AckOnSuccess(func(context.Context, json.RawMessage) error { return Permanent(errors.New("bad json")) })(context.Background(), poison)
+	assert.True(t, IsPermanent(poison.Result()))
+}
+
+func TestMemoryBroker_PublishAfterClose(t *testing.T) {
+	b := NewMemoryBroker(DefaultMemoryBrokerConfig())
+	b.Close()
+	assert.ErrorIs(t, b.Publish(context.Background(), "order.created", 1), ErrBrokerClosed)
+}
@@ -0,0 +1,150 @@
+// Package messaging is the broker-independent view of publish/subscribe
+// used by the services. RabbitMQ implements it for production; the
+// in-memory broker implements it for tests and local development.
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Publisher sends data, encoded as JSON, to every subscription whose
+// pattern matches topic.
+type Publisher interface {
+	Publish(ctx context.Context, topic string, data any) error
+}
+
+// Subscriber delivers messages whose topic matches pattern to h. Patterns
+// use AMQP topic syntax: words are separated by dots, "*" matches exactly
+// one word and "#" matches zero or more.
+type Subscriber interface {
+	Subscribe(pattern string, h Handler)
+}
+
+// Backpressure is implemented by publishers that buffer messages and send
+// them in the background. Saturated reports that the buffer is nearly
+// full, so callers should stop producing work that needs publishing until
+// it drains; Publish fails with ErrQueueFull once it is full.
+type Backpressure interface {
+	Saturated() bool
+}
+
+// Broker is a Publisher and Subscriber in one.
+type Broker interface {
+	Publisher
+	Subscriber
+}
+
+// Message is a message as seen by a handler.
+type Message struct {
+	ID    string
+	Topic string
+	Data  json.RawMessage
+}
+
+// Delivery is one attempt at handling a message. The handler must settle
+// it exactly once: Ack when done, or Nack with the cause. A nacked message
+// with requeue is redelivered later; without requeue it is dead-lettered.
+type Delivery interface {
+	Message() Message
+	Ack() error
+	Nack(requeue bool, cause error) error
+}
+
+// Handler processes a delivery. The context carries the MessageInfo of the
+// delivery.
+type Handler func(ctx context.Context, d Delivery)
+
+var (
+	ErrQueueFull      = errors.New("publish queue full")
+	ErrAlreadySettled = errors.New("delivery already settled")
+	ErrNacked         = errors.New("message nacked")
+	ErrUnsettled      = errors.New("handler returned without settling the delivery")
+)
+
+// AckOnSuccess adapts a function that reports failure by returning an
+// error: nil acks, a Permanent error nacks without requeue and any other
+// error nacks with requeue.
+func AckOnSuccess(fn func(ctx context.Context, data json.RawMessage) error) Handler {
+	return func(ctx context.Context, d Delivery) {
+		err := fn(ctx, d.Message().Data)
+		if err == nil {
+			d.Ack()
+			return
+		}
+		d.Nack(!IsPermanent(err), err)
+	}
+}
+
+// Settlement is a Delivery that records how the handler settled it, for
+// brokers that act on the outcome once the handler has returned.
+type Settlement struct {
+	msg Message
+
+	mu      sync.Mutex
+	settled bool
+	result  error
+}
+
+func NewSettlement(msg Message) *Settlement {
+	return &Settlement{msg: msg}
+}
+
+func (s *Settlement) Message() Message { return s.msg }
+
+func (s *Settlement) Ack() error {
+	return s.settle(nil)
+}
+
+func (s *Settlement) Nack(requeue bool, cause error) error {
+	err := ErrNacked
+	if cause != nil {
+		err = fmt.Errorf("%w: %w", ErrNacked, cause)
+	}
+	if !requeue {
+		err = Permanent(err)
+	}
+	return s.settle(err)
+}
+
+func (s *Settlement) settle(result error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.settled {
+		return ErrAlreadySettled
+	}
+	s.settled, s.result = true, result
+	return nil
+}
+
+// Result is nil for an acked delivery and an error wrapping ErrNacked for a
+// nacked one, Permanent unless it asked for requeue. A delivery that was
+// never settled yields ErrUnsettled, which callers treat like a requeue.
+func (s *Settlement) Result() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.settled {
+		return ErrUnsettled
+	}
+	return s.result
+}
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks a handler error as one that retrying cannot fix, such
+// as a payload that does not decode. The message is dead-lettered without
+// further attempts.
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+func IsPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
@@ -0,0 +1,51 @@
+package messaging
+
+import "strings"
+
+// MatchTopic reports whether topic matches pattern under AMQP topic
+// exchange rules, e.g. "order.*" matches "order.created" but not
+// "order.item.added", while "order.#" matches both as well as "order".
+func MatchTopic(pattern, topic string) bool {
+	return matchWords(strings.Split(pattern, "."), strings.Split(topic, "."))
+}
+
+// IsWildcard reports whether pattern contains "*" or "#" words.
+func IsWildcard(pattern string) bool {
+	for _, w := range strings.Split(pattern, ".") {
+		if w == "*" || w == "#" {
+			return true
+		}
+	}
+	return false
+}
+
+func matchWords(pattern, topic []string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case "#":
+			// Collapse runs of "#" and try every possible split
+			for len(pattern) > 0 && pattern[0] == "#" {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(topic); i++ {
+				if matchWords(pattern, topic[i:]) {
+					return true
+				}
+			}
+			return false
+		case "*":
+			if len(topic) == 0 {
+				return false
+			}
+		default:
+			if len(topic) == 0 || pattern[0] != topic[0] {
+				return false
+			}
+		}
+		pattern, topic = pattern[1:], topic[1:]
+	}
+	return len(topic) == 0
+}
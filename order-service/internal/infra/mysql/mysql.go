@@ -27,7 +27,8 @@ func NewMySQLFromEnv() (*gorm.DB, error) {
 		return nil, err
 	}
 
-	if err := db.AutoMigrate(&domain.Order{}); err != nil {
+	if err := db.AutoMigrate(&domain.Order{}, &domain.OrderStatusHistory{}, &domain.InboxMessage{}, &domain.AuditRecord{}, &domain.Saga{}, &domain.SagaLogEntry{}, &domain.Refund{},
+		&domain.WebhookEndpoint{}, &domain.WebhookDelivery{}, &domain.WebhookAttempt{}); err != nil {
 		return nil, err
 	}
 
@@ -0,0 +1,226 @@
+package infra
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	// ErrPaymentDeclined means the provider refused the operation; trying
+	// again will not change that.
+	ErrPaymentDeclined = errors.New("payment declined")
+	// ErrPaymentUnavailable means the provider could not be reached and
+	// the call may be retried.
+	ErrPaymentUnavailable   = errors.New("payment provider unavailable")
+	ErrPaymentCaptured      = errors.New("authorization already captured")
+	ErrPaymentVoided        = errors.New("authorization voided")
+	ErrUnknownAuthorization = errors.New("unknown authorization")
+	ErrRefundExceedsCapture = errors.New("refund exceeds captured amount")
+)
+
+type PaymentOp string
+
+const (
+	PaymentAuthorize PaymentOp = "authorize"
+	PaymentCapture   PaymentOp = "capture"
+	PaymentVoid      PaymentOp = "void"
+	PaymentRefund    PaymentOp = "refund"
+)
+
+// PaymentFailure scripts a failure of FakePaymentProvider.
+type PaymentFailure struct {
+	Op PaymentOp
+	// MinAmount limits the failure to calls for at least this amount; for
+	// capture and void that is the authorized amount. Zero matches every
+	// call.
+	MinAmount int64
+	Err       error
+	// Times is how many matching calls fail before the failure is used
+	// up. Zero fails every matching call.
+	Times int
+}
+
+// FakeAuthorization is the fake provider's record of an authorization.
+type FakeAuthorization struct {
+	ID       string
+	Amount   int64
+	Captured bool
+	Voided   bool
+	Refunded int64
+}
+
+type scriptedFailure struct {
+	PaymentFailure
+	left int
+}
+
+type fakeAuthorization struct {
+	FakeAuthorization
+	refunds map[string]bool
+}
+
+// FakePaymentProvider is an in-process PaymentProvider for tests and local
+// runs. It keeps authorizations in memory, numbers them auth-1, auth-2, ...
+// and fails only as scripted by its PaymentFailures, so a test gets the same
+// outcome on every run.
+type FakePaymentProvider struct {
+	mu       sync.Mutex
+	nextID   int
+	auths    map[string]*fakeAuthorization
+	failures []*scriptedFailure
+	calls    map[PaymentOp]int
+	failed   map[PaymentOp]int
+}
+
+func NewFakePaymentProvider(failures ...PaymentFailure) *FakePaymentProvider {
+	p := &FakePaymentProvider{
+		auths:  map[string]*fakeAuthorization{},
+		calls:  map[PaymentOp]int{},
+		failed: map[PaymentOp]int{},
+	}
+	for _, f := range failures {
+		p.Fail(f)
+	}
+	return p
+}
+
+// Fail adds a scripted failure.
+func (p *FakePaymentProvider) Fail(f PaymentFailure) {
+	p.mu.Lock()
+	p.failures = append(p.failures, &scriptedFailure{PaymentFailure: f, left: f.Times})
+	p.mu.Unlock()
+}
+
+func (p *FakePaymentProvider) Authorize(ctx context.Context, amount int64) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.begin(ctx, PaymentAuthorize, amount); err != nil {
+		return "", err
+	}
+	if amount <= 0 {
+		return "", fmt.Errorf("%w: amount must be positive", ErrPaymentDeclined)
+	}
+	p.nextID++
+	id := fmt.Sprintf("auth-%d", p.nextID)
+	p.auths[id] = &fakeAuthorization{FakeAuthorization: FakeAuthorization{ID: id, Amount: amount}, refunds: map[string]bool{}}
+	return id, nil
+}
+
+func (p *FakePaymentProvider) Capture(ctx context.Context, authID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	a, err := p.authorization(ctx, PaymentCapture, authID)
+	if err != nil {
+		return err
+	}
+	if a.Voided {
+		return ErrPaymentVoided
+	}
+	a.Captured = true
+	return nil
+}
+
+func (p *FakePaymentProvider) Void(ctx context.Context, authID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	a, err := p.authorization(ctx, PaymentVoid, authID)
+	if err != nil {
+		return err
+	}
+	if a.Captured {
+		return ErrPaymentCaptured
+	}
+	a.Voided = true
+	return nil
+}
+
+func (p *FakePaymentProvider) Refund(ctx context.Context, authID string, amount int64, reference string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	a, err := p.authorization(ctx, PaymentRefund, authID)
+	if err != nil {
+		return err
+	}
+	if a.refunds[reference] {
+		return nil
+	}
+	if !a.Captured {
+		return fmt.Errorf("%w: authorization %s was not captured", ErrPaymentDeclined, authID)
+	}
+	if amount <= 0 || a.Refunded+amount > a.Amount {
+		return fmt.Errorf("%w: %d of %d already refunded", ErrRefundExceedsCapture, a.Refunded, a.Amount)
+	}
+	a.Refunded += amount
+	a.refunds[reference] = true
+	return nil
+}
+
+// authorization looks up authID after applying any scripted failure.
+func (p *FakePaymentProvider) authorization(ctx context.Context, op PaymentOp, authID string) (*fakeAuthorization, error) {
+	a, ok := p.auths[authID]
+	var amount int64
+	if ok {
+		amount = a.Amount
+	}
+	if err := p.begin(ctx, op, amount); err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAuthorization, authID)
+	}
+	return a, nil
+}
+
+// begin counts the call and returns the first scripted failure matching
+// it, if any. The caller holds p.mu.
+func (p *FakePaymentProvider) begin(ctx context.Context, op PaymentOp, amount int64) error {
+	p.calls[op]++
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	for _, f := range p.failures {
+		if f.Op != op || amount < f.MinAmount {
+			continue
+		}
+		if f.Times > 0 {
+			if f.left == 0 {
+				continue
+			}
+			f.left--
+		}
+		p.failed[op]++
+		return f.Err
+	}
+	return nil
+}
+
+// Authorization returns a copy of the authorization with the given id.
+func (p *FakePaymentProvider) Authorization(id string) (FakeAuthorization, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	a, ok := p.auths[id]
+	if !ok {
+		return FakeAuthorization{}, false
+	}
+	return a.FakeAuthorization, true
+}
+
+// Calls returns how often op was called, including failed calls.
+func (p *FakePaymentProvider) Calls(op PaymentOp) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls[op]
+}
+
+func (p *FakePaymentProvider) GetStats() map[string]interface{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := map[string]interface{}{"provider": "fake", "authorizations": len(p.auths)}
+	for _, op := range []PaymentOp{PaymentAuthorize, PaymentCapture, PaymentVoid, PaymentRefund} {
+		stats[string(op)] = p.calls[op]
+		stats[string(op)+"_failed"] = p.failed[op]
+	}
+	return stats
+}
@@ -0,0 +1,65 @@
+package infra
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakePaymentProvider_Lifecycle(t *testing.T) {
+	ctx := context.Background()
+	p := NewFakePaymentProvider()
+
+	id, err := p.Authorize(ctx, 1000)
+	require.NoError(t, err)
+	assert.Equal(t, "auth-1", id)
+
+	require.NoError(t, p.Capture(ctx, id))
+	require.NoError(t, p.Capture(ctx, id), "capturing twice is harmless")
+	assert.ErrorIs(t, p.Void(ctx, id), ErrPaymentCaptured)
+
+	require.NoError(t, p.Refund(ctx, id, 400, "r1"))
+	require.NoError(t, p.Refund(ctx, id, 400, "r1"), "a repeated reference is not refunded again")
+	assert.ErrorIs(t, p.Refund(ctx, id, 700, "r2"), ErrRefundExceedsCapture)
+	require.NoError(t, p.Refund(ctx, id, 600, "r3"))
+
+	a, ok := p.Authorization(id)
+	require.True(t, ok)
+	assert.Equal(t, FakeAuthorization{ID: id, Amount: 1000, Captured: true, Refunded: 1000}, a)
+
+	other, err := p.Authorize(ctx, 50)
+	require.NoError(t, err)
+	assert.Equal(t, "auth-2", other)
+	require.NoError(t, p.Void(ctx, other))
+	require.NoError(t, p.Void(ctx, other))
+	assert.ErrorIs(t, p.Capture(ctx, other), ErrPaymentVoided)
+	assert.ErrorIs(t, p.Refund(ctx, other, 10, "r"), ErrPaymentDeclined)
+	assert.ErrorIs(t, p.Capture(ctx, "auth-9"), ErrUnknownAuthorization)
+}
+
+func TestFakePaymentProvider_ScriptedFailures(t *testing.T) {
+	ctx := context.Background()
+	p := NewFakePaymentProvider(
+		PaymentFailure{Op: PaymentAuthorize, MinAmount: 5000, Err: ErrPaymentDeclined},
+		PaymentFailure{Op: PaymentCapture, Err: ErrPaymentUnavailable, Times: 2},
+	)
+
+	_, err := p.Authorize(ctx, 5000)
+	assert.ErrorIs(t, err, ErrPaymentDeclined)
+	_, err = p.Authorize(ctx, 5000)
+	assert.ErrorIs(t, err, ErrPaymentDeclined, "failures without Times never run out")
+
+	id, err := p.Authorize(ctx, 4999)
+	require.NoError(t, err)
+	assert.ErrorIs(t, p.Capture(ctx, id), ErrPaymentUnavailable)
+	assert.ErrorIs(t, p.Capture(ctx, id), ErrPaymentUnavailable)
+	require.NoError(t, p.Capture(ctx, id))
+
+	assert.Equal(t, 3, p.Calls(PaymentCapture))
+	stats := p.GetStats()
+	assert.Equal(t, 2, stats["capture_failed"])
+	assert.Equal(t, 2, stats["authorize_failed"])
+	assert.Equal(t, 1, stats["authorizations"])
+}
@@ -0,0 +1,155 @@
+package infra
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type BatcherConfig struct {
+	Window   time.Duration // how long to wait for more lookups before flushing
+	MaxBatch int           // flush immediately once this many ids are pending
+	Timeout  time.Duration // deadline for the batched call itself
+}
+
+func DefaultBatcherConfig() BatcherConfig {
+	return BatcherConfig{
+		Window:   2 * time.Millisecond,
+		MaxBatch: 100,
+		Timeout:  500 * time.Millisecond,
+	}
+}
+
+type batchResult struct {
+	product *ProductInfo
+	err     error
+}
+
+// ProductBatcher coalesces concurrent GetProductById calls that arrive
+// within a short window into a single GetProductsByIds call.
+type ProductBatcher struct {
+	next  ProductClientInterface
+	cfg   BatcherConfig
+	clock Clock
+
+	mu      sync.Mutex
+	pending map[uint64][]chan batchResult
+	gen     uint64
+	armed   bool
+
+	batches int64
+	lookups int64
+}
+
+var _ ProductClientInterface = (*ProductBatcher)(nil)
+
+func NewProductBatcher(next ProductClientInterface, cfg BatcherConfig, clock Clock) *ProductBatcher {
+	if clock == nil {
+		clock = RealClock()
+	}
+	if cfg.MaxBatch <= 0 {
+		cfg.MaxBatch = 1
+	}
+	return &ProductBatcher{
+		next:    next,
+		cfg:     cfg,
+		clock:   clock,
+		pending: make(map[uint64][]chan batchResult),
+	}
+}
+
+func (b *ProductBatcher) GetProductById(ctx context.Context, id uint64) (*ProductInfo, error) {
+	atomic.AddInt64(&b.lookups, 1)
+	ch := make(chan batchResult, 1)
+
+	b.mu.Lock()
+	b.pending[id] = append(b.pending[id], ch)
+	if len(b.pending) >= b.cfg.MaxBatch {
+		batch := b.takeLocked()
+		b.mu.Unlock()
+		go b.flush(batch)
+	} else {
+		if !b.armed {
+			b.armed = true
+			go b.flushAfterWindow(b.gen)
+		}
+		b.mu.Unlock()
+	}
+
+	select {
+	case r := <-ch:
+		return r.product, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// GetProductsByIds is passed straight through; callers asking for many ids
+// are already batching.
+func (b *ProductBatcher) GetProductsByIds(ctx context.Context, ids []uint64) (map[uint64]*ProductInfo, error) {
+	return b.next.GetProductsByIds(ctx, ids)
+}
+
+func (b *ProductBatcher) flushAfterWindow(gen uint64) {
+	<-b.clock.After(b.cfg.Window)
+
+	b.mu.Lock()
+	if b.gen != gen {
+		// Already flushed because the batch filled up.
+		b.mu.Unlock()
+		return
+	}
+	batch := b.takeLocked()
+	b.mu.Unlock()
+	b.flush(batch)
+}
+
+// takeLocked must be called with mu held.
+func (b *ProductBatcher) takeLocked() map[uint64][]chan batchResult {
+	batch := b.pending
+	b.pending = make(map[uint64][]chan batchResult)
+	b.gen++
+	b.armed = false
+	return batch
+}
+
+func (b *ProductBatcher) flush(batch map[uint64][]chan batchResult) {
+	if len(batch) == 0 {
+		return
+	}
+	atomic.AddInt64(&b.batches, 1)
+
+	ids := make([]uint64, 0, len(batch))
+	for id := range batch {
+		ids = append(ids, id)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), b.cfg.Timeout)
+	defer cancel()
+	products, err := b.next.GetProductsByIds(ctx, ids)
+
+	for id, waiters := range batch {
+		r := batchResult{err: err}
+		if err == nil {
+			r.product = products[id]
+		}
+		for _, ch := range waiters {
+			ch <- r
+		}
+	}
+}
+
+func (b *ProductBatcher) GetStats() map[string]interface{} {
+	batches := atomic.LoadInt64(&b.batches)
+	lookups := atomic.LoadInt64(&b.lookups)
+	avg := float64(0)
+	if batches > 0 {
+		avg = float64(lookups) / float64(batches)
+	}
+	return map[string]interface{}{
+		"lookups":        lookups,
+		"batches":        batches,
+		"avg_batch_size": avg,
+	}
+}
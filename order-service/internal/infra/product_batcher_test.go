@@ -0,0 +1,102 @@
+package infra
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingBatchClient struct {
+	mu    sync.Mutex
+	calls [][]uint64
+}
+
+func (r *recordingBatchClient) GetProductById(ctx context.Context, id uint64) (*ProductInfo, error) {
+	panic("batcher must not issue single lookups")
+}
+
+func (r *recordingBatchClient) GetProductsByIds(ctx context.Context, ids []uint64) (map[uint64]*ProductInfo, error) {
+	r.mu.Lock()
+	r.calls = append(r.calls, ids)
+	r.mu.Unlock()
+
+	out := make(map[uint64]*ProductInfo)
+	for _, id := range ids {
+		if id != 404 {
+			out[id] = &ProductInfo{ID: id}
+		}
+	}
+	return out, nil
+}
+
+func (b *ProductBatcher) pendingCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := 0
+	for _, waiters := range b.pending {
+		n += len(waiters)
+	}
+	return n
+}
+
+func TestProductBatcher_CoalescesConcurrentLookups(t *testing.T) {
+	clock := newFakeClock(false)
+	next := &recordingBatchClient{}
+	b := NewProductBatcher(next, BatcherConfig{Window: 2 * time.Millisecond, MaxBatch: 10, Timeout: time.Second}, clock)
+
+	ids := []uint64{1, 2, 2, 3, 404}
+	results := make([]*ProductInfo, len(ids))
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		wg.Add(1)
+		go func(i int, id uint64) {
+			defer wg.Done()
+			p, err := b.GetProductById(context.Background(), id)
+			assert.NoError(t, err)
+			results[i] = p
+		}(i, id)
+	}
+
+	for b.pendingCount() < len(ids) {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(2 * time.Millisecond)
+	wg.Wait()
+
+	assert.Len(t, next.calls, 1)
+	assert.ElementsMatch(t, []uint64{1, 2, 3, 404}, next.calls[0])
+	assert.Equal(t, uint64(2), results[1].ID)
+	assert.Equal(t, uint64(2), results[2].ID)
+	assert.Nil(t, results[4], "unknown ids resolve to nil like a 404")
+}
+
+func TestProductBatcher_FlushesWhenFull(t *testing.T) {
+	clock := newFakeClock(false)
+	next := &recordingBatchClient{}
+	b := NewProductBatcher(next, BatcherConfig{Window: time.Hour, MaxBatch: 2, Timeout: time.Second}, clock)
+
+	var wg sync.WaitGroup
+	for _, id := range []uint64{1, 2} {
+		wg.Add(1)
+		go func(id uint64) {
+			defer wg.Done()
+			_, err := b.GetProductById(context.Background(), id)
+			assert.NoError(t, err)
+		}(id)
+	}
+	wg.Wait()
+
+	assert.Len(t, next.calls, 1)
+}
+
+func TestProductBatcher_CallerCancellation(t *testing.T) {
+	b := NewProductBatcher(&recordingBatchClient{}, BatcherConfig{Window: time.Hour, MaxBatch: 10}, newFakeClock(false))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := b.GetProductById(ctx, 1)
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -15,6 +17,16 @@ type ProductInfo struct {
 	Qty   int64  `json:"qty"`
 }
 
+// ProductServiceError is returned when the product service answers with an
+// unexpected HTTP status.
+type ProductServiceError struct {
+	StatusCode int
+}
+
+func (e *ProductServiceError) Error() string {
+	return fmt.Sprintf("product service returned status %d", e.StatusCode)
+}
+
 type ProductClient struct {
 	baseURL    string
 	httpClient *http.Client
@@ -28,7 +40,10 @@ func NewProductClient(baseURL string, timeout time.Duration) *ProductClient {
 }
 
 func (c *ProductClient) GetProductById(ctx context.Context, id uint64) (*ProductInfo, error) {
-	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/products/%d", c.baseURL, id), nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/products/%d", c.baseURL, id), nil)
+	if err != nil {
+		return nil, fmt.Errorf("build product request: %w", err)
+	}
 	resp, err := c.httpClient.Do(req)
 
 	if err != nil {
@@ -41,7 +56,7 @@ func (c *ProductClient) GetProductById(ctx context.Context, id uint64) (*Product
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("product service returned status %d", resp.StatusCode)
+		return nil, &ProductServiceError{StatusCode: resp.StatusCode}
 	}
 	var p ProductInfo
 	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
@@ -49,4 +64,40 @@ func (c *ProductClient) GetProductById(ctx context.Context, id uint64) (*Product
 	}
 
 	return &p, nil
-}
\ No newline at end of file
+}
+
+// GetProductsByIds calls the batch endpoint GET /products?ids=1,2,3, which
+// returns a JSON array of the products found.
+func (c *ProductClient) GetProductsByIds(ctx context.Context, ids []uint64) (map[uint64]*ProductInfo, error) {
+	out := make(map[uint64]*ProductInfo, len(ids))
+	if len(ids) == 0 {
+		return out, nil
+	}
+
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.FormatUint(id, 10)
+	}
+	url := fmt.Sprintf("%s/products?ids=%s", c.baseURL, strings.Join(parts, ","))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("build product batch request: %w", err)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &ProductServiceError{StatusCode: resp.StatusCode}
+	}
+	var products []ProductInfo
+	if err := json.NewDecoder(resp.Body).Decode(&products); err != nil {
+		return nil, err
+	}
+	for i := range products {
+		out[products[i].ID] = &products[i]
+	}
+	return out, nil
+}
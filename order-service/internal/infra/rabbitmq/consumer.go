@@ -0,0 +1,328 @@
+package rabbitmq
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"order-service/internal/infra/messaging"
+
+	"github.com/streadway/amqp"
+)
+
+// HandlerFunc processes the data part of a NestJS message.
+type HandlerFunc func(ctx context.Context, data json.RawMessage) error
+
+// Headers set on retried and dead-lettered messages.
+const (
+	HeaderRetryCount    = "x-retry-count"
+	HeaderFailureReason = "x-failure-reason"
+	HeaderFailedAt      = "x-failed-at"
+	HeaderOriginalQueue = "x-original-queue"
+)
+
+// RetryPolicy bounds redelivery of messages whose handler failed. Attempt
+// n (1-based) waits BaseDelay*2^(n-1), capped at MaxDelay, in a delay
+// queue before going back to the main queue. After MaxRetries the message
+// is moved to the dead-letter queue.
+type RetryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxRetries: 5, BaseDelay: time.Second, MaxDelay: time.Minute}
+}
+
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d
+}
+
+// channelPublisher is the part of *amqp.Channel dispatch needs to reroute
+// failed messages.
+type channelPublisher interface {
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+}
+
+type Consumer struct {
+	conn     *amqp.Connection
+	channel  *amqp.Channel
+	queue    string
+	retry    RetryPolicy
+	mu       sync.RWMutex
+	handlers map[string]HandlerFunc
+	wildcard []string // patterns in handlers with "*" or "#", in registration order
+
+	retried      atomic.Int64
+	deadLettered atomic.Int64
+}
+
+type incomingMessage struct {
+	Pattern string          `json:"pattern"`
+	Data    json.RawMessage `json:"data"`
+	ID      string          `json:"id,omitempty"`
+}
+
+// DeadLetterExchange, DeadLetterQueue and RetryQueue name the queues
+// declared next to queue.
+func DeadLetterExchange(queue string) string { return queue + ".dlx" }
+func DeadLetterQueue(queue string) string    { return queue + ".dlq" }
+func RetryQueue(queue string, attempt int) string {
+	return fmt.Sprintf("%s.retry.%d", queue, attempt)
+}
+
+// NewConsumer connects to RabbitMQ and declares queue with the given
+// durability. The declaration must match the one used by the producer, so
+// the main queue gets no extra arguments; the retry and dead-letter queues
+// around it are owned by this service.
+func NewConsumer(amqpURL, queue string, durable bool, prefetch int, retry RetryPolicy) (*Consumer, error) {
+	conn, err := amqp.Dial(amqpURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to RabbitMQ: %v", err)
+	}
+
+	channel, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open channel: %v", err)
+	}
+
+	if _, err := channel.QueueDeclare(queue, durable, false, false, false, nil); err != nil {
+		channel.Close()
+		conn.Close()
+		return nil, fmt.Errorf("failed to declare queue: %v", err)
+	}
+
+	if err := declareDeadLetterTopology(channel, queue, durable, retry); err != nil {
+		channel.Close()
+		conn.Close()
+		return nil, err
+	}
+
+	if err := channel.Qos(prefetch, 0, false); err != nil {
+		channel.Close()
+		conn.Close()
+		return nil, fmt.Errorf("failed to set qos: %v", err)
+	}
+
+	return &Consumer{
+		conn:     conn,
+		channel:  channel,
+		queue:    queue,
+		retry:    retry,
+		handlers: make(map[string]HandlerFunc),
+	}, nil
+}
+
+// declareDeadLetterTopology declares the DLX with its DLQ and one delay
+// queue per retry attempt. Delay queues have no consumers; when a
+// message's TTL expires the broker dead-letters it back to the main queue
+// through the default exchange.
+func declareDeadLetterTopology(ch *amqp.Channel, queue string, durable bool, retry RetryPolicy) error {
+	dlx, dlq := DeadLetterExchange(queue), DeadLetterQueue(queue)
+	if err := ch.ExchangeDeclare(dlx, "direct", true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare dead-letter exchange: %v", err)
+	}
+	if _, err := ch.QueueDeclare(dlq, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare dead-letter queue: %v", err)
+	}
+	if err := ch.QueueBind(dlq, queue, dlx, false, nil); err != nil {
+		return fmt.Errorf("failed to bind dead-letter queue: %v", err)
+	}
+
+	for attempt := 1; attempt <= retry.MaxRetries; attempt++ {
+		args := amqp.Table{
+			"x-message-ttl":             retry.delay(attempt).Milliseconds(),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": queue,
+		}
+		if _, err := ch.QueueDeclare(RetryQueue(queue, attempt), durable, false, false, false, args); err != nil {
+			return fmt.Errorf("failed to declare retry queue %d: %v", attempt, err)
+		}
+	}
+	return nil
+}
+
+// Handle registers h for messages whose pattern matches. An exact pattern
+// wins over wildcards; among wildcards the first registered wins.
+func (c *Consumer) Handle(pattern string, h HandlerFunc) {
+	c.mu.Lock()
+	if _, ok := c.handlers[pattern]; !ok && messaging.IsWildcard(pattern) {
+		c.wildcard = append(c.wildcard, pattern)
+	}
+	c.handlers[pattern] = h
+	c.mu.Unlock()
+}
+
+// Subscribe registers a broker-independent handler. Its ack or nack is
+// applied once it returns: a requeue goes through the retry queues, a
+// nack without requeue straight to the dead-letter queue.
+func (c *Consumer) Subscribe(pattern string, h messaging.Handler) {
+	c.Handle(pattern, func(ctx context.Context, data json.RawMessage) error {
+		info, _ := messaging.MessageFromContext(ctx)
+		d := messaging.NewSettlement(messaging.Message{ID: info.ID, Topic: info.Topic, Data: data})
+		h(ctx, d)
+		return d.Result()
+	})
+}
+
+func (c *Consumer) handler(pattern string) (HandlerFunc, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if h, ok := c.handlers[pattern]; ok {
+		return h, true
+	}
+	for _, p := range c.wildcard {
+		if messaging.MatchTopic(p, pattern) {
+			return c.handlers[p], true
+		}
+	}
+	return nil, false
+}
+
+// Start consumes until ctx is done or the channel is closed. Messages whose
+// pattern has no handler are acknowledged and dropped.
+func (c *Consumer) Start(ctx context.Context) error {
+	deliveries, err := c.channel.Consume(c.queue, "", false, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start consuming: %v", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case d, ok := <-deliveries:
+			if !ok {
+				return fmt.Errorf("delivery channel closed")
+			}
+			c.dispatch(ctx, c.channel, d)
+		}
+	}
+}
+
+func (c *Consumer) dispatch(ctx context.Context, ch channelPublisher, d amqp.Delivery) {
+	msg, err := decodeDelivery(d)
+	if err != nil {
+		c.fail(ch, d, "", messaging.Permanent(fmt.Errorf("undecodable message: %w", err)))
+		return
+	}
+
+	h, ok := c.handler(msg.Pattern)
+	if !ok {
+		d.Ack(false)
+		return
+	}
+
+	id := msg.ID
+	if id == "" {
+		id = d.MessageId
+	}
+	if id == "" {
+		id = contentID(msg.Pattern, msg.Data)
+	}
+	if err := h(messaging.WithMessage(ctx, messaging.MessageInfo{ID: id, Topic: msg.Pattern}), msg.Data); err != nil {
+		c.fail(ch, d, msg.Pattern, err)
+		return
+	}
+	d.Ack(false)
+}
+
+// contentID stands in for the id of a message that has none, so the inbox
+// still recognises redeliveries. NestJS's client.emit sends neither an
+// envelope id nor a message-id, but a redelivery carries the same bytes.
+func contentID(pattern string, data json.RawMessage) string {
+	sum := sha256.New()
+	sum.Write([]byte(pattern))
+	sum.Write([]byte{0})
+	sum.Write(data)
+	return "sha256:" + hex.EncodeToString(sum.Sum(nil))
+}
+
+// fail schedules a retry of d or, once retries are exhausted or the error
+// is permanent, moves it to the dead-letter queue. The original delivery
+// is only acked once the copy has been published; if that fails it is
+// requeued instead so nothing is lost.
+func (c *Consumer) fail(ch channelPublisher, d amqp.Delivery, pattern string, cause error) {
+	attempts := retryCount(d.Headers)
+	headers := amqp.Table{}
+	for k, v := range d.Headers {
+		headers[k] = v
+	}
+	out := amqp.Publishing{
+		Headers:      headers,
+		ContentType:  d.ContentType,
+		MessageId:    d.MessageId,
+		DeliveryMode: d.DeliveryMode,
+		Body:         d.Body,
+	}
+
+	var err error
+	if attempts < c.retry.MaxRetries && !messaging.IsPermanent(cause) {
+		headers[HeaderRetryCount] = int32(attempts + 1)
+		log.Printf("Handler for '%s' failed (attempt %d/%d), retrying: %v", pattern, attempts+1, c.retry.MaxRetries+1, cause)
+		err = ch.Publish("", RetryQueue(c.queue, attempts+1), false, false, out)
+		if err == nil {
+			c.retried.Add(1)
+		}
+	} else {
+		headers[HeaderFailureReason] = cause.Error()
+		headers[HeaderFailedAt] = time.Now().UTC()
+		headers[HeaderOriginalQueue] = c.queue
+		log.Printf("Dead-lettering '%s' message %s after %d retries: %v", pattern, d.MessageId, attempts, cause)
+		err = ch.Publish(DeadLetterExchange(c.queue), c.queue, false, false, out)
+		if err == nil {
+			c.deadLettered.Add(1)
+		}
+	}
+
+	if err != nil {
+		log.Printf("Failed to reroute failed message, requeueing: %v", err)
+		d.Nack(false, true)
+		return
+	}
+	d.Ack(false)
+}
+
+func retryCount(h amqp.Table) int {
+	switch v := h[HeaderRetryCount].(type) {
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case int:
+		return v
+	}
+	return 0
+}
+
+func (c *Consumer) GetStats() map[string]interface{} {
+	return map[string]interface{}{
+		"queue":         c.queue,
+		"retried":       c.retried.Load(),
+		"dead_lettered": c.deadLettered.Load(),
+	}
+}
+
+func (c *Consumer) Close() {
+	if c.channel != nil {
+		c.channel.Close()
+	}
+	if c.conn != nil {
+		c.conn.Close()
+	}
+}
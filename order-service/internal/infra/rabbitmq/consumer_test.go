@@ -0,0 +1,223 @@
+package rabbitmq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"order-service/internal/infra/messaging"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAck records what the consumer did with a delivery.
+type fakeAck struct {
+	acked, nacked, requeued bool
+}
+
+func (a *fakeAck) Ack(uint64, bool) error { a.acked = true; return nil }
+func (a *fakeAck) Nack(_ uint64, _ bool, requeue bool) error {
+	a.nacked, a.requeued = true, requeue
+	return nil
+}
+func (a *fakeAck) Reject(_ uint64, requeue bool) error { return a.Nack(0, false, requeue) }
+
+type published struct {
+	exchange, key string
+	msg           amqp.Publishing
+}
+
+type fakeChannel struct {
+	out []published
+	err error
+}
+
+func (c *fakeChannel) Publish(exchange, key string, _, _ bool, msg amqp.Publishing) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.out = append(c.out, published{exchange, key, msg})
+	return nil
+}
+
+func newTestConsumer(retries int) *Consumer {
+	return &Consumer{
+		queue:    "product_queue",
+		retry:    RetryPolicy{MaxRetries: retries, BaseDelay: time.Second, MaxDelay: 4 * time.Second},
+		handlers: make(map[string]HandlerFunc),
+	}
+}
+
+func delivery(ack *fakeAck, body string, headers amqp.Table) amqp.Delivery {
+	return amqp.Delivery{Acknowledger: ack, Body: []byte(body), Headers: headers, MessageId: "m-1", ContentType: "application/json"}
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	p := RetryPolicy{MaxRetries: 5, BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	assert.Equal(t, time.Second, p.delay(1))
+	assert.Equal(t, 2*time.Second, p.delay(2))
+	assert.Equal(t, 4*time.Second, p.delay(3))
+	assert.Equal(t, 5*time.Second, p.delay(4))
+}
+
+func TestConsumer_DispatchSuccessPassesMessageInfo(t *testing.T) {
+	c := newTestConsumer(2)
+	var got messaging.MessageInfo
+	c.Handle("order.qty_confirmed", func(ctx context.Context, _ json.RawMessage) error {
+		got, _ = messaging.MessageFromContext(ctx)
+		return nil
+	})
+
+	ack, ch := &fakeAck{}, &fakeChannel{}
+	c.dispatch(context.Background(), ch, delivery(ack, `{"pattern":"order.qty_confirmed","data":{},"id":"env-1"}`, nil))
+
+	assert.True(t, ack.acked)
+	assert.Empty(t, ch.out)
+	assert.Equal(t, messaging.MessageInfo{ID: "env-1", Topic: "order.qty_confirmed"}, got)
+}
+
+func TestConsumer_DispatchDerivesIDForIDlessReplies(t *testing.T) {
+	c := newTestConsumer(2)
+	var ids []string
+	c.Handle("order.qty_confirmed", func(ctx context.Context, _ json.RawMessage) error {
+		info, _ := messaging.MessageFromContext(ctx)
+		ids = append(ids, info.ID)
+		return nil
+	})
+
+	// What NestJS's client.emit sends: no envelope id, no message-id
+	for _, body := range []string{
+		`{"pattern":"order.qty_confirmed","data":{"orderId":7}}`,
+		`{"pattern":"order.qty_confirmed","data":{"orderId":7}}`,
+		`{"pattern":"order.qty_confirmed","data":{"orderId":8}}`,
+	} {
+		ack := &fakeAck{}
+		c.dispatch(context.Background(), &fakeChannel{}, amqp.Delivery{Acknowledger: ack, Body: []byte(body)})
+		require.True(t, ack.acked)
+	}
+
+	require.Len(t, ids, 3)
+	assert.NotEmpty(t, ids[0])
+	assert.Equal(t, ids[0], ids[1], "a redelivery gets the same id")
+	assert.NotEqual(t, ids[0], ids[2])
+}
+
+func TestConsumer_FailedHandlerIsRetriedThenDeadLettered(t *testing.T) {
+	c := newTestConsumer(2)
+	c.Handle("order.qty_confirmed", func(context.Context, json.RawMessage) error {
+		return errors.New("db down")
+	})
+	body := `{"pattern":"order.qty_confirmed","data":{}}`
+
+	ack, ch := &fakeAck{}, &fakeChannel{}
+	c.dispatch(context.Background(), ch, delivery(ack, body, nil))
+	require.Len(t, ch.out, 1)
+	assert.True(t, ack.acked)
+	assert.Equal(t, "", ch.out[0].exchange)
+	assert.Equal(t, "product_queue.retry.1", ch.out[0].key)
+	assert.Equal(t, int32(1), ch.out[0].msg.Headers[HeaderRetryCount])
+
+	ack, ch = &fakeAck{}, &fakeChannel{}
+	c.dispatch(context.Background(), ch, delivery(ack, body, amqp.Table{HeaderRetryCount: int32(1)}))
+	require.Len(t, ch.out, 1)
+	assert.Equal(t, "product_queue.retry.2", ch.out[0].key)
+
+	ack, ch = &fakeAck{}, &fakeChannel{}
+	c.dispatch(context.Background(), ch, delivery(ack, body, amqp.Table{HeaderRetryCount: int32(2)}))
+	require.Len(t, ch.out, 1)
+	assert.True(t, ack.acked)
+	assert.Equal(t, "product_queue.dlx", ch.out[0].exchange)
+	assert.Equal(t, "db down", ch.out[0].msg.Headers[HeaderFailureReason])
+	assert.Equal(t, "product_queue", ch.out[0].msg.Headers[HeaderOriginalQueue])
+	assert.IsType(t, time.Time{}, ch.out[0].msg.Headers[HeaderFailedAt])
+	assert.Equal(t, "m-1", ch.out[0].msg.MessageId)
+
+	stats := c.GetStats()
+	assert.Equal(t, int64(2), stats["retried"])
+	assert.Equal(t, int64(1), stats["dead_lettered"])
+}
+
+func TestConsumer_PoisonMessagesSkipRetries(t *testing.T) {
+	c := newTestConsumer(5)
+	c.Handle("order.qty_failed", func(context.Context, json.RawMessage) error {
+		return messaging.Permanent(errors.New("decode order.qty_failed: bad json"))
+	})
+
+	for _, body := range []string{`not json`, `{"pattern":"order.qty_failed","data":"x"}`} {
+		ack, ch := &fakeAck{}, &fakeChannel{}
+		c.dispatch(context.Background(), ch, delivery(ack, body, nil))
+		require.Len(t, ch.out, 1, body)
+		assert.Equal(t, "product_queue.dlx", ch.out[0].exchange, body)
+		assert.True(t, ack.acked, body)
+	}
+}
+
+func TestConsumer_RequeuesWhenReroutingFails(t *testing.T) {
+	c := newTestConsumer(1)
+	c.Handle("order.qty_confirmed", func(context.Context, json.RawMessage) error {
+		return errors.New("db down")
+	})
+
+	ack := &fakeAck{}
+	c.dispatch(context.Background(), &fakeChannel{err: errors.New("channel closed")}, delivery(ack, `{"pattern":"order.qty_confirmed"}`, nil))
+	assert.False(t, ack.acked)
+	assert.True(t, ack.requeued)
+}
+
+func TestDeadLetterFrom(t *testing.T) {
+	failedAt := time.Date(2025, 9, 20, 10, 0, 0, 0, time.UTC)
+	dl := deadLetterFrom(amqp.Delivery{
+		Body: []byte(`{"pattern":"order.qty_failed","data":{"orderId":1},"id":"env-9"}`),
+		Headers: amqp.Table{
+			HeaderRetryCount:    int32(3),
+			HeaderFailureReason: "db down",
+			HeaderFailedAt:      failedAt,
+			HeaderOriginalQueue: "product_queue",
+		},
+	})
+	assert.Equal(t, "env-9", dl.MessageID)
+	assert.Equal(t, "order.qty_failed", dl.Pattern)
+	assert.Equal(t, 3, dl.Retries)
+	assert.Equal(t, failedAt, dl.FailedAt)
+
+	raw := deadLetterFrom(amqp.Delivery{Body: []byte("not json")})
+	assert.JSONEq(t, `"not json"`, string(raw.Body))
+}
+
+func TestConsumer_SubscribeAppliesSettlement(t *testing.T) {
+	c := newTestConsumer(2)
+	var got []messaging.Message
+	c.Subscribe("order.*", func(_ context.Context, d messaging.Delivery) {
+		got = append(got, d.Message())
+		if d.Message().Topic == "order.qty_failed" {
+			d.Nack(false, errors.New("unknown order"))
+			return
+		}
+		d.Ack()
+	})
+	c.Handle("order.qty_confirmed", func(context.Context, json.RawMessage) error { return nil })
+
+	// The exact pattern wins over the wildcard
+	ack, ch := &fakeAck{}, &fakeChannel{}
+	c.dispatch(context.Background(), ch, delivery(ack, `{"pattern":"order.qty_confirmed","data":{}}`, nil))
+	assert.True(t, ack.acked)
+	assert.Empty(t, got)
+
+	ack, ch = &fakeAck{}, &fakeChannel{}
+	c.dispatch(context.Background(), ch, delivery(ack, `{"pattern":"order.qty_failed","data":{"orderId":1},"id":"env-2"}`, nil))
+	require.Len(t, got, 1)
+	assert.Equal(t, messaging.Message{ID: "env-2", Topic: "order.qty_failed", Data: json.RawMessage(`{"orderId":1}`)}, got[0])
+	require.Len(t, ch.out, 1)
+	assert.Equal(t, "product_queue.dlx", ch.out[0].exchange)
+	assert.Equal(t, "message nacked: unknown order", ch.out[0].msg.Headers[HeaderFailureReason])
+
+	// No handler matches: acked and dropped
+	ack, ch = &fakeAck{}, &fakeChannel{}
+	c.dispatch(context.Background(), ch, delivery(ack, `{"pattern":"order.item.added","data":{}}`, nil))
+	assert.True(t, ack.acked)
+	assert.Len(t, got, 1)
+}
@@ -0,0 +1,124 @@
+package rabbitmq
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// DeadLetter is a message parked in a dead-letter queue.
+type DeadLetter struct {
+	MessageID     string          `json:"messageId,omitempty"`
+	Pattern       string          `json:"pattern,omitempty"`
+	Reason        string          `json:"reason,omitempty"`
+	FailedAt      time.Time       `json:"failedAt,omitempty"`
+	Retries       int             `json:"retries"`
+	OriginalQueue string          `json:"originalQueue,omitempty"`
+	Body          json.RawMessage `json:"body"`
+}
+
+func deadLetterFrom(d amqp.Delivery) DeadLetter {
+	dl := DeadLetter{
+		MessageID: d.MessageId,
+		Retries:   retryCount(d.Headers),
+	}
+	dl.Reason, _ = d.Headers[HeaderFailureReason].(string)
+	dl.FailedAt, _ = d.Headers[HeaderFailedAt].(time.Time)
+	dl.OriginalQueue, _ = d.Headers[HeaderOriginalQueue].(string)
+
+	if msg, err := decodeDelivery(d); err == nil {
+		dl.Pattern = msg.Pattern
+		if dl.MessageID == "" {
+			dl.MessageID = msg.ID
+		}
+	}
+	if json.Valid(d.Body) {
+		dl.Body = d.Body
+	} else {
+		// Keep undecodable payloads visible without breaking the JSON response
+		dl.Body, _ = json.Marshal(string(d.Body))
+	}
+	return dl
+}
+
+// DeadLetters returns up to limit messages from the dead-letter queue
+// without removing them. Messages are fetched unacknowledged and then
+// returned to the queue, so the order may change between calls.
+func (c *Consumer) DeadLetters(limit int) ([]DeadLetter, error) {
+	ch, err := c.conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open channel: %v", err)
+	}
+	// Closing the channel returns every unacked message to the queue
+	defer ch.Close()
+
+	out := []DeadLetter{}
+	for len(out) < limit {
+		d, ok, err := ch.Get(DeadLetterQueue(c.queue), false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read dead-letter queue: %v", err)
+		}
+		if !ok {
+			break
+		}
+		out = append(out, deadLetterFrom(d))
+	}
+	return out, nil
+}
+
+// Redrive moves up to limit dead-lettered messages back onto the main
+// queue with a fresh retry budget. A non-empty messageID restricts it to
+// that message; everything else is left in place. It returns the number
+// of messages moved.
+func (c *Consumer) Redrive(limit int, messageID string) (int, error) {
+	ch, err := c.conn.Channel()
+	if err != nil {
+		return 0, fmt.Errorf("failed to open channel: %v", err)
+	}
+	defer ch.Close()
+
+	moved := 0
+	// Skipped messages stay unacked until the channel closes, so each
+	// message is seen at most once per call
+	for moved < limit {
+		d, ok, err := ch.Get(DeadLetterQueue(c.queue), false)
+		if err != nil {
+			return moved, fmt.Errorf("failed to read dead-letter queue: %v", err)
+		}
+		if !ok {
+			break
+		}
+		if messageID != "" && deadLetterFrom(d).MessageID != messageID {
+			continue
+		}
+
+		headers := amqp.Table{}
+		for k, v := range d.Headers {
+			switch k {
+			case HeaderRetryCount, HeaderFailureReason, HeaderFailedAt, HeaderOriginalQueue:
+			default:
+				headers[k] = v
+			}
+		}
+		err = ch.Publish("", c.queue, false, false, amqp.Publishing{
+			Headers:      headers,
+			ContentType:  d.ContentType,
+			MessageId:    d.MessageId,
+			DeliveryMode: d.DeliveryMode,
+			Body:         d.Body,
+		})
+		if err != nil {
+			return moved, fmt.Errorf("failed to republish dead letter: %v", err)
+		}
+		if err := d.Ack(false); err != nil {
+			return moved, fmt.Errorf("failed to remove dead letter: %v", err)
+		}
+		moved++
+		if messageID != "" {
+			break
+		}
+	}
+	return moved, nil
+}
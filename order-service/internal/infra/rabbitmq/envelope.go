@@ -0,0 +1,176 @@
+package rabbitmq
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// Encoder turns an outgoing message into an AMQP publishing. The message
+// is always built as a NestJSMessage, which carries all the metadata any
+// envelope needs; encoders decide how it goes on the wire.
+type Encoder interface {
+	Encode(msg NestJSMessage) (amqp.Publishing, error)
+}
+
+// NestJSEncoder produces the {pattern, data, id, ...} JSON body NestJS
+// microservices consume.
+type NestJSEncoder struct{}
+
+func (NestJSEncoder) Encode(msg NestJSMessage) (amqp.Publishing, error) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return amqp.Publishing{}, fmt.Errorf("failed to marshal message: %v", err)
+	}
+	return amqp.Publishing{
+		ContentType: "application/json",
+		MessageId:   msg.ID,
+		Timestamp:   msg.OccurredAt,
+		Body:        body,
+	}, nil
+}
+
+const (
+	CloudEventsSpecVersion = "1.0"
+	cloudEventsContentType = "application/cloudevents+json"
+)
+
+// CloudEventsEncoder produces CloudEvents 1.0. In structured mode the
+// whole event is the JSON body; in binary mode the body is just the data
+// and the attributes travel as ce-* AMQP headers. The NestJS pattern
+// becomes the event type and the schema version the "schemaversion"
+// extension attribute.
+type CloudEventsEncoder struct {
+	Source string // e.g. "/order-service"
+	Binary bool
+}
+
+type cloudEvent struct {
+	SpecVersion     string      `json:"specversion"`
+	ID              string      `json:"id"`
+	Source          string      `json:"source"`
+	Type            string      `json:"type"`
+	Time            time.Time   `json:"time"`
+	DataContentType string      `json:"datacontenttype,omitempty"`
+	SchemaVersion   int         `json:"schemaversion,omitempty"`
+	Data            interface{} `json:"data,omitempty"`
+}
+
+func (e CloudEventsEncoder) Encode(msg NestJSMessage) (amqp.Publishing, error) {
+	ce := cloudEvent{
+		SpecVersion:     CloudEventsSpecVersion,
+		ID:              msg.ID,
+		Source:          e.Source,
+		Type:            msg.Pattern,
+		Time:            msg.OccurredAt,
+		DataContentType: "application/json",
+		SchemaVersion:   msg.SchemaVersion,
+		Data:            msg.Data,
+	}
+
+	if !e.Binary {
+		body, err := json.Marshal(ce)
+		if err != nil {
+			return amqp.Publishing{}, fmt.Errorf("failed to marshal cloud event: %v", err)
+		}
+		return amqp.Publishing{
+			ContentType: cloudEventsContentType,
+			MessageId:   msg.ID,
+			Timestamp:   msg.OccurredAt,
+			Body:        body,
+		}, nil
+	}
+
+	body, err := json.Marshal(msg.Data)
+	if err != nil {
+		return amqp.Publishing{}, fmt.Errorf("failed to marshal cloud event data: %v", err)
+	}
+	headers := amqp.Table{
+		"ce-specversion": ce.SpecVersion,
+		"ce-id":          ce.ID,
+		"ce-source":      ce.Source,
+		"ce-type":        ce.Type,
+		"ce-time":        ce.Time.Format(time.RFC3339Nano),
+	}
+	if ce.SchemaVersion != 0 {
+		headers["ce-schemaversion"] = int32(ce.SchemaVersion)
+	}
+	return amqp.Publishing{
+		Headers:     headers,
+		ContentType: ce.DataContentType,
+		MessageId:   msg.ID,
+		Timestamp:   msg.OccurredAt,
+		Body:        body,
+	}, nil
+}
+
+// ParseEncoders reads a per-routing-key encoding spec such as
+// "order.created=cloudevents-binary,*=nestjs". Valid encodings are nestjs,
+// cloudevents (structured) and cloudevents-binary; "*" sets the default.
+func ParseEncoders(spec, source string) (map[string]Encoder, error) {
+	out := map[string]Encoder{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, name, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("encoding %q: want <routing key>=<encoding>", part)
+		}
+		var enc Encoder
+		switch strings.TrimSpace(name) {
+		case "nestjs":
+			enc = NestJSEncoder{}
+		case "cloudevents":
+			enc = CloudEventsEncoder{Source: source}
+		case "cloudevents-binary":
+			enc = CloudEventsEncoder{Source: source, Binary: true}
+		default:
+			return nil, fmt.Errorf("encoding %q: unknown encoding %q", part, name)
+		}
+		out[strings.TrimSpace(key)] = enc
+	}
+	return out, nil
+}
+
+// inboundEnvelope covers the NestJS envelope and structured CloudEvents,
+// which can be told apart by specversion.
+type inboundEnvelope struct {
+	Pattern     string          `json:"pattern"`
+	ID          string          `json:"id"`
+	SpecVersion string          `json:"specversion"`
+	Type        string          `json:"type"`
+	Data        json.RawMessage `json:"data"`
+}
+
+// decodeDelivery extracts pattern, id and data from a delivery in any
+// envelope the publisher can produce.
+func decodeDelivery(d amqp.Delivery) (incomingMessage, error) {
+	if spec, ok := d.Headers["ce-specversion"].(string); ok {
+		if spec != CloudEventsSpecVersion {
+			return incomingMessage{}, fmt.Errorf("unsupported cloudevents specversion %q", spec)
+		}
+		typ, _ := d.Headers["ce-type"].(string)
+		id, _ := d.Headers["ce-id"].(string)
+		if !json.Valid(d.Body) {
+			return incomingMessage{}, fmt.Errorf("cloud event %s: data is not JSON", id)
+		}
+		return incomingMessage{Pattern: typ, ID: id, Data: d.Body}, nil
+	}
+
+	var env inboundEnvelope
+	if err := json.Unmarshal(d.Body, &env); err != nil {
+		return incomingMessage{}, err
+	}
+	if env.SpecVersion != "" {
+		if env.SpecVersion != CloudEventsSpecVersion {
+			return incomingMessage{}, fmt.Errorf("unsupported cloudevents specversion %q", env.SpecVersion)
+		}
+		return incomingMessage{Pattern: env.Type, ID: env.ID, Data: env.Data}, nil
+	}
+	return incomingMessage{Pattern: env.Pattern, ID: env.ID, Data: env.Data}, nil
+}
@@ -0,0 +1,109 @@
+package rabbitmq
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"order-service/internal/infra/messaging"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type versionedPayload struct {
+	OrderID uint64 `json:"orderId"`
+}
+
+func (versionedPayload) SchemaVersion() int { return 2 }
+
+func asDelivery(p amqp.Publishing) amqp.Delivery {
+	return amqp.Delivery{Headers: p.Headers, ContentType: p.ContentType, MessageId: p.MessageId, Body: p.Body}
+}
+
+func TestEncoders_RoundTripThroughConsumerDecoding(t *testing.T) {
+	msg := NewMessage("order.created", versionedPayload{OrderID: 7}, time.Date(2025, 9, 20, 10, 30, 0, 0, time.UTC))
+	require.Equal(t, 2, msg.SchemaVersion)
+
+	encoders := map[string]Encoder{
+		"nestjs":             NestJSEncoder{},
+		"cloudevents":        CloudEventsEncoder{Source: "/order-service"},
+		"cloudevents-binary": CloudEventsEncoder{Source: "/order-service", Binary: true},
+	}
+	for name, enc := range encoders {
+		t.Run(name, func(t *testing.T) {
+			p, err := enc.Encode(msg)
+			require.NoError(t, err)
+			assert.Equal(t, msg.ID, p.MessageId)
+
+			got, err := decodeDelivery(asDelivery(p))
+			require.NoError(t, err)
+			assert.Equal(t, "order.created", got.Pattern)
+			assert.Equal(t, msg.ID, got.ID)
+			assert.JSONEq(t, `{"orderId":7}`, string(got.Data))
+		})
+	}
+}
+
+func TestCloudEventsEncoder_Attributes(t *testing.T) {
+	msg := NewMessage("order.cancelled", versionedPayload{OrderID: 3}, time.Date(2025, 9, 20, 10, 30, 0, 0, time.UTC))
+
+	structured, err := CloudEventsEncoder{Source: "/order-service"}.Encode(msg)
+	require.NoError(t, err)
+	assert.Equal(t, "application/cloudevents+json", structured.ContentType)
+	var ce map[string]any
+	require.NoError(t, json.Unmarshal(structured.Body, &ce))
+	assert.Equal(t, "1.0", ce["specversion"])
+	assert.Equal(t, "/order-service", ce["source"])
+	assert.Equal(t, "order.cancelled", ce["type"])
+	assert.Equal(t, "2025-09-20T10:30:00Z", ce["time"])
+	assert.EqualValues(t, 2, ce["schemaversion"])
+
+	binary, err := CloudEventsEncoder{Source: "/order-service", Binary: true}.Encode(msg)
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", binary.ContentType)
+	assert.Equal(t, "1.0", binary.Headers["ce-specversion"])
+	assert.Equal(t, msg.ID, binary.Headers["ce-id"])
+	assert.Equal(t, "order.cancelled", binary.Headers["ce-type"])
+	assert.Equal(t, int32(2), binary.Headers["ce-schemaversion"])
+	assert.JSONEq(t, `{"orderId":3}`, string(binary.Body))
+}
+
+func TestParseEncoders(t *testing.T) {
+	encs, err := ParseEncoders("order.created=cloudevents-binary, *=cloudevents", "/orders")
+	require.NoError(t, err)
+	assert.Equal(t, CloudEventsEncoder{Source: "/orders", Binary: true}, encs["order.created"])
+	assert.Equal(t, CloudEventsEncoder{Source: "/orders"}, encs["*"])
+
+	_, err = ParseEncoders("order.created=avro", "/orders")
+	assert.Error(t, err)
+	_, err = ParseEncoders("cloudevents", "/orders")
+	assert.Error(t, err)
+}
+
+func TestConsumer_DispatchesCloudEvents(t *testing.T) {
+	c := newTestConsumer(1)
+	var got messaging.MessageInfo
+	c.Handle("order.qty_confirmed", func(ctx context.Context, data json.RawMessage) error {
+		got, _ = messaging.MessageFromContext(ctx)
+		return nil
+	})
+
+	ack := &fakeAck{}
+	d := amqp.Delivery{
+		Acknowledger: ack,
+		Headers:      amqp.Table{"ce-specversion": "1.0", "ce-id": "ce-1", "ce-type": "order.qty_confirmed", "ce-source": "/product-service"},
+		Body:         []byte(`{"orderId":1}`),
+	}
+	c.dispatch(context.Background(), &fakeChannel{}, d)
+	assert.True(t, ack.acked)
+	assert.Equal(t, messaging.MessageInfo{ID: "ce-1", Topic: "order.qty_confirmed"}, got)
+
+	// Other spec versions are not guessed at
+	ack, ch := &fakeAck{}, &fakeChannel{}
+	c.dispatch(context.Background(), ch, delivery(ack, `{"specversion":"0.3","type":"order.qty_confirmed","id":"x","data":{}}`, nil))
+	require.Len(t, ch.out, 1)
+	assert.Equal(t, "product_queue.dlx", ch.out[0].exchange)
+}
@@ -1,9 +1,25 @@
 package rabbitmq
 
-import "context"
+import (
+	"context"
 
-type PublisherInterface interface {
-	Publish(ctx context.Context, routingKey string, data any) error
+	"order-service/internal/infra/messaging"
+)
+
+var _ messaging.Publisher = (*Publisher)(nil)
+
+type ConsumerInterface interface {
+	messaging.Subscriber
+	Handle(pattern string, h HandlerFunc)
+	Start(ctx context.Context) error
+}
+
+var _ ConsumerInterface = (*Consumer)(nil)
+
+// DeadLetterStore inspects and re-drives a consumer's dead-letter queue.
+type DeadLetterStore interface {
+	DeadLetters(limit int) ([]DeadLetter, error)
+	Redrive(limit int, messageID string) (int, error)
 }
 
-var _ PublisherInterface = (*Publisher)(nil)
\ No newline at end of file
+var _ DeadLetterStore = (*Consumer)(nil)
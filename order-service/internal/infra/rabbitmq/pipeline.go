@@ -0,0 +1,375 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"order-service/internal/infra/messaging"
+
+	"github.com/streadway/amqp"
+)
+
+var ErrPipelineClosed = errors.New("publish pipeline closed")
+
+// PipelineConfig sizes the publishing pipeline.
+type PipelineConfig struct {
+	// QueueSize bounds the messages waiting to be published.
+	QueueSize int
+	// Workers is the number of publisher goroutines, each with its own
+	// channel in confirm mode.
+	Workers int
+	// BatchSize is the most messages a worker publishes before waiting
+	// for their confirms.
+	BatchSize int
+	// ConfirmTimeout bounds the wait for a batch's confirms. On timeout
+	// the channel is replaced and the unconfirmed messages are retried.
+	ConfirmTimeout time.Duration
+	// MaxRetries is how often a nacked or unconfirmed message is
+	// published again before it is counted as failed; negative disables
+	// retries. Messages that could not be sent at all do not use up
+	// retries.
+	MaxRetries int
+	// RetryDelay is the pause before reopening a failed channel. It
+	// doubles while the channel cannot be opened, up to MaxRetryDelay.
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+	// HighWatermark is the queue fill ratio at which the pipeline reports
+	// itself saturated.
+	HighWatermark float64
+}
+
+func DefaultPipelineConfig() PipelineConfig {
+	return PipelineConfig{
+		QueueSize:      10000,
+		Workers:        4,
+		BatchSize:      100,
+		ConfirmTimeout: 5 * time.Second,
+		MaxRetries:     3,
+		RetryDelay:     200 * time.Millisecond,
+		MaxRetryDelay:  10 * time.Second,
+		HighWatermark:  0.8,
+	}
+}
+
+// confirmChannel is the part of *amqp.Channel a pipeline worker uses. The
+// channel must already be in confirm mode.
+type confirmChannel interface {
+	Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+	NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation
+	Close() error
+}
+
+type pipelineItem struct {
+	key      string
+	msg      amqp.Publishing
+	attempts int
+}
+
+// Pipeline publishes in the background. Publish encodes the message and
+// queues it without waiting for the broker; a fixed set of workers drains
+// the queue in batches and waits for publisher confirms per batch rather
+// than per message. Messages are delivered at least once: a message whose
+// confirm is lost is published again under the same message id.
+type Pipeline struct {
+	publisher   *Publisher
+	cfg         PipelineConfig
+	openChannel func() (confirmChannel, error)
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan pipelineItem
+	wg     sync.WaitGroup
+
+	enqueued  atomic.Int64
+	published atomic.Int64
+	dropped   atomic.Int64
+	retried   atomic.Int64
+	nacked    atomic.Int64
+	failed    atomic.Int64
+	batches   atomic.Int64
+}
+
+var (
+	_ messaging.Publisher    = (*Pipeline)(nil)
+	_ messaging.Backpressure = (*Pipeline)(nil)
+)
+
+// NewPipeline starts cfg.Workers workers publishing through p's connection
+// with p's encoders.
+func NewPipeline(p *Publisher, cfg PipelineConfig) *Pipeline {
+	return newPipeline(p, cfg, func() (confirmChannel, error) {
+		ch, err := p.conn.Channel()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open channel: %v", err)
+		}
+		if err := ch.Confirm(false); err != nil {
+			ch.Close()
+			return nil, fmt.Errorf("failed to enable publisher confirms: %v", err)
+		}
+		return ch, nil
+	})
+}
+
+func newPipeline(p *Publisher, cfg PipelineConfig, open func() (confirmChannel, error)) *Pipeline {
+	def := DefaultPipelineConfig()
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = def.QueueSize
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = def.Workers
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = def.BatchSize
+	}
+	if cfg.ConfirmTimeout <= 0 {
+		cfg.ConfirmTimeout = def.ConfirmTimeout
+	}
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = def.MaxRetries
+	}
+	if cfg.RetryDelay <= 0 {
+		cfg.RetryDelay = def.RetryDelay
+	}
+	if cfg.MaxRetryDelay < cfg.RetryDelay {
+		cfg.MaxRetryDelay = max(def.MaxRetryDelay, cfg.RetryDelay)
+	}
+	if cfg.HighWatermark <= 0 || cfg.HighWatermark > 1 {
+		cfg.HighWatermark = def.HighWatermark
+	}
+
+	pl := &Pipeline{
+		publisher:   p,
+		cfg:         cfg,
+		openChannel: open,
+		queue:       make(chan pipelineItem, cfg.QueueSize),
+	}
+	for i := 0; i < cfg.Workers; i++ {
+		pl.wg.Add(1)
+		go pl.run()
+	}
+	return pl
+}
+
+// Publish queues data under routingKey. It never blocks: when the queue is
+// full the message is dropped and ErrQueueFull returned.
+func (pl *Pipeline) Publish(ctx context.Context, routingKey string, data any) error {
+	msg, err := pl.publisher.encoderFor(routingKey).Encode(NewMessage(routingKey, data, time.Now()))
+	if err != nil {
+		return err
+	}
+
+	pl.mu.RLock()
+	defer pl.mu.RUnlock()
+	if pl.closed {
+		return ErrPipelineClosed
+	}
+	select {
+	case pl.queue <- pipelineItem{key: routingKey, msg: msg}:
+		pl.enqueued.Add(1)
+		return nil
+	default:
+		pl.dropped.Add(1)
+		return fmt.Errorf("%w: dropping '%s' message %s", messaging.ErrQueueFull, routingKey, msg.MessageId)
+	}
+}
+
+// Load is the fraction of the queue in use.
+func (pl *Pipeline) Load() float64 {
+	return float64(len(pl.queue)) / float64(cap(pl.queue))
+}
+
+// Saturated reports whether the queue is past the high watermark.
+func (pl *Pipeline) Saturated() bool {
+	return pl.Load() >= pl.cfg.HighWatermark
+}
+
+func (pl *Pipeline) run() {
+	defer pl.wg.Done()
+
+	var ch confirmChannel
+	var confirms chan amqp.Confirmation
+	var lastTag uint64
+	defer func() {
+		if ch != nil {
+			ch.Close()
+		}
+	}()
+
+	var retry []pipelineItem
+	openDelay := pl.cfg.RetryDelay
+	for {
+		batch, ok := pl.nextBatch(retry)
+		if !ok {
+			return
+		}
+
+		if ch == nil {
+			var err error
+			if ch, err = pl.openChannel(); err != nil {
+				// Nothing was sent, so the batch keeps its attempts and
+				// waits for the broker however long it takes
+				log.Printf("Publish pipeline: %v, retrying in %v", err, openDelay)
+				ch = nil
+				retry = batch
+				time.Sleep(openDelay)
+				openDelay = min(2*openDelay, pl.cfg.MaxRetryDelay)
+				continue
+			}
+			openDelay = pl.cfg.RetryDelay
+			confirms = ch.NotifyPublish(make(chan amqp.Confirmation, pl.cfg.BatchSize))
+			lastTag = 0
+		}
+
+		failed, unsent, healthy := pl.publishBatch(ch, confirms, &lastTag, batch)
+		if !healthy {
+			ch.Close()
+			ch = nil
+		}
+		retry = append(pl.requeue(failed), unsent...)
+		if !healthy {
+			time.Sleep(pl.cfg.RetryDelay)
+		}
+	}
+}
+
+// nextBatch returns the messages left from the last batch topped up from
+// the queue, blocking until there is at least one. It reports false once
+// the pipeline is closed and everything has been published.
+func (pl *Pipeline) nextBatch(carry []pipelineItem) ([]pipelineItem, bool) {
+	batch := carry
+	if len(batch) == 0 {
+		item, ok := <-pl.queue
+		if !ok {
+			return nil, false
+		}
+		batch = append(batch, item)
+	}
+	for len(batch) < pl.cfg.BatchSize {
+		select {
+		case item, ok := <-pl.queue:
+			if !ok {
+				return batch, true
+			}
+			batch = append(batch, item)
+		default:
+			return batch, true
+		}
+	}
+	return batch, true
+}
+
+// publishBatch publishes batch and waits for its confirms. It returns the
+// messages that were sent but not confirmed, those that could not be sent
+// and whether ch is still usable. lastTag tracks the channel's delivery
+// tags, which count from 1.
+func (pl *Pipeline) publishBatch(ch confirmChannel, confirms chan amqp.Confirmation, lastTag *uint64, batch []pipelineItem) (failed, unsent []pipelineItem, healthy bool) {
+	pl.batches.Add(1)
+	first := *lastTag + 1
+	for i, item := range batch {
+		if err := ch.Publish(pl.publisher.exchange, item.key, false, false, item.msg); err != nil {
+			log.Printf("Publish pipeline: failed to publish '%s': %v", item.key, err)
+			// Confirms for what was already sent on this channel will never
+			// be read, so all of the batch is retried
+			return batch[:i], batch[i:], false
+		}
+		*lastTag = first + uint64(i)
+	}
+
+	acked := make([]bool, len(batch))
+	timeout := time.NewTimer(pl.cfg.ConfirmTimeout)
+	defer timeout.Stop()
+	healthy = true
+wait:
+	for n := 0; n < len(batch); {
+		select {
+		case c, ok := <-confirms:
+			if !ok {
+				healthy = false
+				break wait
+			}
+			if c.DeliveryTag < first || c.DeliveryTag > *lastTag {
+				continue
+			}
+			acked[c.DeliveryTag-first] = c.Ack
+			if !c.Ack {
+				pl.nacked.Add(1)
+			}
+			n++
+		case <-timeout.C:
+			log.Printf("Publish pipeline: no confirms after %v, replacing channel", pl.cfg.ConfirmTimeout)
+			healthy = false
+			break wait
+		}
+	}
+
+	for i, item := range batch {
+		if acked[i] {
+			pl.published.Add(1)
+		} else {
+			failed = append(failed, item)
+		}
+	}
+	return failed, nil, healthy
+}
+
+// requeue counts an attempt for messages that were sent but not
+// confirmed, keeps those with attempts left for the worker's next batch
+// and gives up on the rest.
+func (pl *Pipeline) requeue(items []pipelineItem) []pipelineItem {
+	var out []pipelineItem
+	for _, item := range items {
+		item.attempts++
+		if item.attempts > pl.cfg.MaxRetries {
+			pl.failed.Add(1)
+			log.Printf("Publish pipeline: giving up on '%s' message %s after %d attempts", item.key, item.msg.MessageId, item.attempts)
+			continue
+		}
+		pl.retried.Add(1)
+		out = append(out, item)
+	}
+	return out
+}
+
+func (pl *Pipeline) GetStats() map[string]interface{} {
+	return map[string]interface{}{
+		"queue_depth":    len(pl.queue),
+		"queue_capacity": cap(pl.queue),
+		"saturated":      pl.Saturated(),
+		"workers":        pl.cfg.Workers,
+		"enqueued":       pl.enqueued.Load(),
+		"published":      pl.published.Load(),
+		"dropped":        pl.dropped.Load(),
+		"retried":        pl.retried.Load(),
+		"nacked":         pl.nacked.Load(),
+		"failed":         pl.failed.Load(),
+		"batches":        pl.batches.Load(),
+	}
+}
+
+// Close stops accepting messages and waits until the workers have
+// published what is queued or ctx is done.
+func (pl *Pipeline) Close(ctx context.Context) error {
+	pl.mu.Lock()
+	if !pl.closed {
+		pl.closed = true
+		close(pl.queue)
+	}
+	pl.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		pl.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("publish pipeline: %d messages still queued: %w", len(pl.queue), ctx.Err())
+	}
+}
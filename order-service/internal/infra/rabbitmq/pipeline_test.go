@@ -0,0 +1,197 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"order-service/internal/infra/messaging"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeConfirmChannel confirms every publish right away unless told to
+// nack it or to stay silent.
+type fakeConfirmChannel struct {
+	mu       sync.Mutex
+	tag      uint64
+	notify   chan amqp.Confirmation
+	silent   bool
+	nack     func(amqp.Publishing) bool
+	sent     *[]amqp.Publishing
+	isClosed bool
+}
+
+func (c *fakeConfirmChannel) Publish(_, _ string, _, _ bool, msg amqp.Publishing) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tag++
+	*c.sent = append(*c.sent, msg)
+	if !c.silent {
+		c.notify <- amqp.Confirmation{DeliveryTag: c.tag, Ack: c.nack == nil || !c.nack(msg)}
+	}
+	return nil
+}
+
+func (c *fakeConfirmChannel) NotifyPublish(ch chan amqp.Confirmation) chan amqp.Confirmation {
+	c.notify = ch
+	return ch
+}
+
+func (c *fakeConfirmChannel) Close() error {
+	c.mu.Lock()
+	c.isClosed = true
+	c.mu.Unlock()
+	return nil
+}
+
+type fakeChannels struct {
+	mu        sync.Mutex
+	sent      []amqp.Publishing
+	opened    []*fakeConfirmChannel
+	setup     func(n int, ch *fakeConfirmChannel) // n counts from 0
+	failOpens int                                 // opens that fail before one succeeds
+}
+
+func (f *fakeChannels) open() (confirmChannel, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failOpens > 0 {
+		f.failOpens--
+		return nil, errors.New("connection refused")
+	}
+	ch := &fakeConfirmChannel{sent: &f.sent}
+	if f.setup != nil {
+		f.setup(len(f.opened), ch)
+	}
+	f.opened = append(f.opened, ch)
+	return ch, nil
+}
+
+func testPipelinePublisher() *Publisher {
+	return &Publisher{exchange: "order.exchange", encoders: map[string]Encoder{"*": NestJSEncoder{}}}
+}
+
+func closePipeline(t *testing.T, pl *Pipeline) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	require.NoError(t, pl.Close(ctx))
+}
+
+func TestPipeline_PublishesInConfirmedBatches(t *testing.T) {
+	chans := &fakeChannels{}
+	pl := newPipeline(testPipelinePublisher(), PipelineConfig{Workers: 1, BatchSize: 100, QueueSize: 500}, chans.open)
+
+	for i := 0; i < 250; i++ {
+		require.NoError(t, pl.Publish(context.Background(), "order.created", map[string]int{"orderId": i}))
+	}
+	closePipeline(t, pl)
+
+	stats := pl.GetStats()
+	assert.Equal(t, int64(250), stats["enqueued"])
+	assert.Equal(t, int64(250), stats["published"])
+	assert.GreaterOrEqual(t, stats["batches"], int64(3))
+	assert.Zero(t, stats["retried"])
+	assert.Len(t, chans.sent, 250)
+	assert.Len(t, chans.opened, 1)
+	assert.True(t, chans.opened[0].isClosed)
+}
+
+func TestPipeline_RetriesNackedMessagesWithTheSameID(t *testing.T) {
+	chans := &fakeChannels{}
+	var once sync.Once
+	chans.setup = func(_ int, ch *fakeConfirmChannel) {
+		ch.nack = func(amqp.Publishing) bool {
+			nacked := false
+			once.Do(func() { nacked = true })
+			return nacked
+		}
+	}
+	pl := newPipeline(testPipelinePublisher(), PipelineConfig{Workers: 1}, chans.open)
+
+	require.NoError(t, pl.Publish(context.Background(), "order.created", map[string]int{"orderId": 1}))
+	closePipeline(t, pl)
+
+	require.Len(t, chans.sent, 2)
+	assert.Equal(t, chans.sent[0].MessageId, chans.sent[1].MessageId)
+	stats := pl.GetStats()
+	assert.Equal(t, int64(1), stats["published"])
+	assert.Equal(t, int64(1), stats["nacked"])
+	assert.Equal(t, int64(1), stats["retried"])
+}
+
+func TestPipeline_ReplacesChannelWhenConfirmsTimeOut(t *testing.T) {
+	chans := &fakeChannels{setup: func(n int, ch *fakeConfirmChannel) { ch.silent = n == 0 }}
+	pl := newPipeline(testPipelinePublisher(), PipelineConfig{Workers: 1, ConfirmTimeout: 20 * time.Millisecond, RetryDelay: time.Millisecond}, chans.open)
+
+	require.NoError(t, pl.Publish(context.Background(), "order.created", map[string]int{"orderId": 1}))
+	closePipeline(t, pl)
+
+	require.Len(t, chans.opened, 2)
+	assert.True(t, chans.opened[0].isClosed)
+	assert.Equal(t, int64(1), pl.GetStats()["published"])
+}
+
+func TestPipeline_GivesUpAfterMaxRetries(t *testing.T) {
+	chans := &fakeChannels{setup: func(_ int, ch *fakeConfirmChannel) {
+		ch.nack = func(amqp.Publishing) bool { return true }
+	}}
+	pl := newPipeline(testPipelinePublisher(), PipelineConfig{Workers: 2, MaxRetries: 2}, chans.open)
+
+	require.NoError(t, pl.Publish(context.Background(), "order.created", map[string]int{"orderId": 1}))
+	closePipeline(t, pl)
+
+	assert.Len(t, chans.sent, 3)
+	stats := pl.GetStats()
+	assert.Equal(t, int64(1), stats["failed"])
+	assert.Equal(t, int64(2), stats["retried"])
+	assert.Zero(t, stats["published"])
+}
+
+func TestPipeline_BackpressureAndDrops(t *testing.T) {
+	// No workers, so nothing leaves the queue
+	pl := &Pipeline{
+		publisher: testPipelinePublisher(),
+		cfg:       PipelineConfig{HighWatermark: 0.5},
+		queue:     make(chan pipelineItem, 4),
+	}
+
+	require.NoError(t, pl.Publish(context.Background(), "order.created", 1))
+	assert.False(t, pl.Saturated())
+	require.NoError(t, pl.Publish(context.Background(), "order.created", 2))
+	assert.True(t, pl.Saturated())
+	require.NoError(t, pl.Publish(context.Background(), "order.created", 3))
+	require.NoError(t, pl.Publish(context.Background(), "order.created", 4))
+
+	err := pl.Publish(context.Background(), "order.created", 5)
+	assert.ErrorIs(t, err, messaging.ErrQueueFull)
+	stats := pl.GetStats()
+	assert.Equal(t, 4, stats["queue_depth"])
+	assert.Equal(t, int64(1), stats["dropped"])
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Error(t, pl.Close(ctx))
+	assert.True(t, errors.Is(pl.Publish(context.Background(), "order.created", 6), ErrPipelineClosed))
+}
+
+func TestPipeline_WaitsForTheBrokerWithoutUsingUpRetries(t *testing.T) {
+	chans := &fakeChannels{failOpens: 6}
+	pl := newPipeline(testPipelinePublisher(), PipelineConfig{Workers: 1, MaxRetries: 1, RetryDelay: time.Millisecond, MaxRetryDelay: 4 * time.Millisecond}, chans.open)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, pl.Publish(context.Background(), "order.created", map[string]int{"orderId": i}))
+	}
+	closePipeline(t, pl)
+
+	stats := pl.GetStats()
+	assert.Equal(t, int64(3), stats["published"])
+	assert.Zero(t, stats["failed"])
+	assert.Zero(t, stats["retried"])
+	assert.Len(t, chans.sent, 3)
+}
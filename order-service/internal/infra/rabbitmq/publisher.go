@@ -2,9 +2,11 @@ package rabbitmq
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
+	"time"
+
+	"order-service/internal/infra/messaging"
 
 	"github.com/streadway/amqp"
 )
@@ -13,12 +15,37 @@ type Publisher struct {
     conn     *amqp.Connection
     channel  *amqp.Channel
     exchange string
+    encoders map[string]Encoder // by routing key, "*" for the default
 }
 
+// NestJSMessage is the envelope NestJS microservices expect, extended
+// with the metadata described by domain.EventEnvelope.
 type NestJSMessage struct {
-    Pattern string      `json:"pattern"`
-    Data    interface{} `json:"data"`
-    ID      string      `json:"id,omitempty"`
+    Pattern       string      `json:"pattern"`
+    Data          interface{} `json:"data"`
+    ID            string      `json:"id,omitempty"`
+    EventID       string      `json:"eventId,omitempty"`
+    SchemaVersion int         `json:"schemaVersion,omitempty"`
+    OccurredAt    time.Time   `json:"occurredAt"`
+}
+
+// NewMessage wraps data for publishing under pattern with a fresh id.
+// Catalog events (domain.Event) carry their own schema version; anything
+// else is sent as version 1.
+func NewMessage(pattern string, data interface{}, now time.Time) NestJSMessage {
+    id := messaging.NewID()
+    msg := NestJSMessage{
+        Pattern:       pattern,
+        Data:          data,
+        ID:            id,
+        EventID:       id,
+        SchemaVersion: 1,
+        OccurredAt:    now.UTC(),
+    }
+    if v, ok := data.(interface{ SchemaVersion() int }); ok {
+        msg.SchemaVersion = v.SchemaVersion()
+    }
+    return msg
 }
 
 func NewPublisher(amqpURL, exchange string) (*Publisher, error) {
@@ -52,32 +79,45 @@ func NewPublisher(amqpURL, exchange string) (*Publisher, error) {
         conn:     conn,
         channel:  channel,
         exchange: exchange,
+        encoders: map[string]Encoder{"*": NestJSEncoder{}},
     }, nil
 }
 
-func (p *Publisher) Publish(ctx context.Context, pattern string, data interface{}) error {
-    message := NestJSMessage{
-        Pattern: pattern,
-        Data:    data,
+// SetEncoders chooses the envelope per routing key; "*" replaces the
+// NestJS default. It must be called before the first Publish.
+func (p *Publisher) SetEncoders(encoders map[string]Encoder) {
+    for key, enc := range encoders {
+        p.encoders[key] = enc
     }
+}
+
+func (p *Publisher) encoderFor(routingKey string) Encoder {
+    if enc, ok := p.encoders[routingKey]; ok {
+        return enc
+    }
+    return p.encoders["*"]
+}
+
+// Publish sends data under pattern. Every message gets a fresh id, carried
+// both in the envelope and as the AMQP message-id, so consumers can
+// deduplicate redeliveries.
+func (p *Publisher) Publish(ctx context.Context, pattern string, data interface{}) error {
+    message := NewMessage(pattern, data, time.Now())
 
-    body, err := json.Marshal(message)
+    publishing, err := p.encoderFor(pattern).Encode(message)
     if err != nil {
-        return fmt.Errorf("failed to marshal message: %v", err)
+        return err
     }
 
     log.Printf("Publishing message with pattern '%s' to exchange '%s'", pattern, p.exchange)
-    log.Printf("Message body: %s", string(body))
+    log.Printf("Message body: %s", string(publishing.Body))
 
     err = p.channel.Publish(
         p.exchange,
         pattern,    
         false,      
         false,      
-        amqp.Publishing{
-            ContentType: "application/json",
-            Body:        body,
-        },
+        publishing,
     )
     if err != nil {
         return fmt.Errorf("failed to publish message: %v", err)
@@ -0,0 +1,235 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RateLimit is a token bucket: Limit tokens are refilled evenly over
+// Window, and up to Burst may be spent at once.
+type RateLimit struct {
+	Limit  int
+	Window time.Duration
+	Burst  int
+}
+
+// ParseRateLimit parses "<limit>/<window>[:<burst>]", e.g. "100/1m" or
+// "20/1s:40". Burst defaults to the limit.
+func ParseRateLimit(s string) (RateLimit, error) {
+	spec, burstSpec, hasBurst := strings.Cut(strings.TrimSpace(s), ":")
+	limitSpec, windowSpec, ok := strings.Cut(spec, "/")
+	if !ok {
+		return RateLimit{}, fmt.Errorf("rate limit %q: want <limit>/<window>", s)
+	}
+	limit, err := strconv.Atoi(limitSpec)
+	if err != nil || limit <= 0 {
+		return RateLimit{}, fmt.Errorf("rate limit %q: invalid limit", s)
+	}
+	window, err := time.ParseDuration(windowSpec)
+	if err != nil || window <= 0 {
+		return RateLimit{}, fmt.Errorf("rate limit %q: invalid window", s)
+	}
+	rl := RateLimit{Limit: limit, Window: window, Burst: limit}
+	if hasBurst {
+		if rl.Burst, err = strconv.Atoi(burstSpec); err != nil || rl.Burst <= 0 {
+			return RateLimit{}, fmt.Errorf("rate limit %q: invalid burst", s)
+		}
+	}
+	return rl, nil
+}
+
+func (r RateLimit) burst() int {
+	if r.Burst > 0 {
+		return r.Burst
+	}
+	return r.Limit
+}
+
+// refillEvery is the time needed to earn one token.
+func (r RateLimit) refillEvery() time.Duration {
+	return r.Window / time.Duration(r.Limit)
+}
+
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration // until the next token, when not allowed
+	Reset      time.Duration // until the bucket is full again
+}
+
+func newRateLimitResult(rl RateLimit, allowed bool, tokens float64) RateLimitResult {
+	every := rl.refillEvery()
+	res := RateLimitResult{
+		Allowed:   allowed,
+		Limit:     rl.burst(),
+		Remaining: int(math.Floor(tokens)),
+		Reset:     time.Duration((float64(rl.burst()) - tokens) * float64(every)),
+	}
+	if !allowed {
+		res.RetryAfter = time.Duration((1 - tokens) * float64(every))
+	}
+	return res
+}
+
+// tokenBucketScript refills and spends atomically, using the Redis clock so
+// every replica sees the same bucket.
+var tokenBucketScript = redis.NewScript(`
+local burst = tonumber(ARGV[1])
+local per_ms = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * per_ms)
+
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / per_ms) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+type RedisRateLimiter struct {
+	client  *redis.Client
+	prefix  string
+	timeout time.Duration
+}
+
+func NewRedisRateLimiter(client *redis.Client, timeout time.Duration) *RedisRateLimiter {
+	return &RedisRateLimiter{client: client, prefix: "ratelimit:", timeout: timeout}
+}
+
+func (r *RedisRateLimiter) Allow(ctx context.Context, key string, rl RateLimit) (RateLimitResult, error) {
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+	perMs := float64(rl.Limit) / (float64(rl.Window) / float64(time.Millisecond))
+	out, err := tokenBucketScript.Run(ctx, r.client, []string{r.prefix + key}, rl.burst(), perMs).Slice()
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	if len(out) != 2 {
+		return RateLimitResult{}, fmt.Errorf("rate limit script: unexpected reply %v", out)
+	}
+	allowed, _ := out[0].(int64)
+	tokensStr, _ := out[1].(string)
+	tokens, err := strconv.ParseFloat(tokensStr, 64)
+	if err != nil {
+		return RateLimitResult{}, fmt.Errorf("rate limit script: %w", err)
+	}
+	return newRateLimitResult(rl, allowed == 1, tokens), nil
+}
+
+type memoryBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// MemoryRateLimiter keeps buckets in process. Limits are per replica, so it
+// is only meant as a fallback when Redis cannot be reached.
+type MemoryRateLimiter struct {
+	clock Clock
+
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+func NewMemoryRateLimiter(clock Clock) *MemoryRateLimiter {
+	if clock == nil {
+		clock = RealClock()
+	}
+	return &MemoryRateLimiter{clock: clock, buckets: make(map[string]*memoryBucket), lastSweep: clock.Now()}
+}
+
+func (m *MemoryRateLimiter) Allow(ctx context.Context, key string, rl RateLimit) (RateLimitResult, error) {
+	now := m.clock.Now()
+	burst := float64(rl.burst())
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now.Sub(m.lastSweep) > time.Minute {
+		m.sweepLocked(now)
+	}
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &memoryBucket{tokens: burst, last: now}
+		m.buckets[key] = b
+	}
+	elapsed := now.Sub(b.last)
+	if elapsed > 0 {
+		b.tokens = math.Min(burst, b.tokens+float64(elapsed)/float64(rl.refillEvery()))
+		b.last = now
+	}
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	return newRateLimitResult(rl, allowed, b.tokens), nil
+}
+
+// sweepLocked drops buckets idle long enough to have refilled completely
+// under any reasonable window. Must be called with mu held.
+func (m *MemoryRateLimiter) sweepLocked(now time.Time) {
+	for k, b := range m.buckets {
+		if now.Sub(b.last) > 10*time.Minute {
+			delete(m.buckets, k)
+		}
+	}
+	m.lastSweep = now
+}
+
+// FallbackRateLimiter uses primary and switches to fallback for any call
+// where primary fails, so a Redis outage degrades to per-replica limits
+// instead of rejecting or admitting everything.
+type FallbackRateLimiter struct {
+	primary  RateLimiter
+	fallback RateLimiter
+
+	fallbacks int64
+	lastLog   int64 // unix seconds of the last outage log line
+}
+
+func NewFallbackRateLimiter(primary, fallback RateLimiter) *FallbackRateLimiter {
+	return &FallbackRateLimiter{primary: primary, fallback: fallback}
+}
+
+func (f *FallbackRateLimiter) Allow(ctx context.Context, key string, rl RateLimit) (RateLimitResult, error) {
+	res, err := f.primary.Allow(ctx, key, rl)
+	if err == nil {
+		return res, nil
+	}
+	atomic.AddInt64(&f.fallbacks, 1)
+	now := time.Now().Unix()
+	if last := atomic.LoadInt64(&f.lastLog); now-last >= 10 && atomic.CompareAndSwapInt64(&f.lastLog, last, now) {
+		log.Printf("Rate limiter falling back to in-memory buckets: %v", err)
+	}
+	return f.fallback.Allow(ctx, key, rl)
+}
+
+func (f *FallbackRateLimiter) GetStats() map[string]interface{} {
+	return map[string]interface{}{
+		"fallbacks": atomic.LoadInt64(&f.fallbacks),
+	}
+}
@@ -0,0 +1,91 @@
+package infra
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	rl, err := ParseRateLimit("100/1m")
+	require.NoError(t, err)
+	assert.Equal(t, RateLimit{Limit: 100, Window: time.Minute, Burst: 100}, rl)
+
+	rl, err = ParseRateLimit("20/1s:40")
+	require.NoError(t, err)
+	assert.Equal(t, 40, rl.Burst)
+
+	for _, bad := range []string{"", "100", "x/1s", "10/forever", "0/1s", "10/1s:-1"} {
+		_, err := ParseRateLimit(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestMemoryRateLimiter_TokenBucket(t *testing.T) {
+	clock := newFakeClock(false)
+	m := NewMemoryRateLimiter(clock)
+	rl := RateLimit{Limit: 2, Window: time.Second}
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		res, err := m.Allow(ctx, "a", rl)
+		require.NoError(t, err)
+		assert.True(t, res.Allowed)
+	}
+	res, _ := m.Allow(ctx, "a", rl)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+	assert.Equal(t, 500*time.Millisecond, res.RetryAfter)
+
+	res, _ = m.Allow(ctx, "b", rl)
+	assert.True(t, res.Allowed, "buckets are per key")
+
+	clock.Advance(500 * time.Millisecond)
+	res, _ = m.Allow(ctx, "a", rl)
+	assert.True(t, res.Allowed, "one token refilled")
+}
+
+func TestRedisRateLimiter_SharedAcrossReplicas(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	replicaA := NewRedisRateLimiter(client, time.Second)
+	replicaB := NewRedisRateLimiter(client, time.Second)
+	rl := RateLimit{Limit: 3, Window: time.Minute}
+	ctx := context.Background()
+
+	for i, l := range []*RedisRateLimiter{replicaA, replicaB, replicaA} {
+		res, err := l.Allow(ctx, "client", rl)
+		require.NoError(t, err)
+		assert.True(t, res.Allowed, "request %d", i)
+		assert.Equal(t, 2-i, res.Remaining)
+	}
+	res, err := replicaB.Allow(ctx, "client", rl)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Greater(t, res.RetryAfter, time.Duration(0))
+	assert.True(t, mr.Exists("ratelimit:client"))
+}
+
+func TestFallbackRateLimiter_UsesMemoryWhenRedisDown(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	defer client.Close()
+	mr.Close()
+
+	f := NewFallbackRateLimiter(NewRedisRateLimiter(client, 50*time.Millisecond), NewMemoryRateLimiter(newFakeClock(false)))
+	rl := RateLimit{Limit: 1, Window: time.Minute}
+
+	res, err := f.Allow(context.Background(), "client", rl)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	res, _ = f.Allow(context.Background(), "client", rl)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, int64(2), f.GetStats()["fallbacks"])
+}
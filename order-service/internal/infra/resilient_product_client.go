@@ -0,0 +1,304 @@
+package infra
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type ResilienceConfig struct {
+	MaxRetries  int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+
+	Breaker BreakerConfig
+
+	HedgeEnabled  bool
+	HedgeMinDelay time.Duration // lower bound, also used until enough samples exist
+	LatencySample int           // number of recent latencies used for p95
+}
+
+func DefaultResilienceConfig() ResilienceConfig {
+	return ResilienceConfig{
+		MaxRetries:  2,
+		BaseBackoff: 10 * time.Millisecond,
+		MaxBackoff:  50 * time.Millisecond,
+		Breaker: BreakerConfig{
+			WindowSize:        50,
+			MinRequests:       20,
+			FailureRate:       0.5,
+			SlowCallThreshold: 100 * time.Millisecond,
+			SlowCallRate:      0.8,
+			OpenTimeout:       5 * time.Second,
+			HalfOpenProbes:    3,
+		},
+		HedgeEnabled:  false,
+		HedgeMinDelay: 20 * time.Millisecond,
+		LatencySample: 200,
+	}
+}
+
+type ResilienceStats struct {
+	Requests        int64
+	Successes       int64
+	Failures        int64
+	Retries         int64
+	Hedges          int64
+	HedgeWins       int64
+	ShortCircuited  int64
+	BreakerOpened   int64
+	BreakerHalfOpen int64
+	BreakerClosed   int64
+}
+
+// ResilientProductClient wraps a ProductClientInterface with jittered
+// retries, a circuit breaker and optional hedged requests.
+type ResilientProductClient struct {
+	next    ProductClientInterface
+	cfg     ResilienceConfig
+	clock   Clock
+	breaker *CircuitBreaker
+	latency *latencyTracker
+	jitter  func() float64
+
+	stats ResilienceStats
+}
+
+var _ ProductClientInterface = (*ResilientProductClient)(nil)
+
+func NewResilientProductClient(next ProductClientInterface, cfg ResilienceConfig, clock Clock) *ResilientProductClient {
+	if clock == nil {
+		clock = RealClock()
+	}
+	c := &ResilientProductClient{
+		next:    next,
+		cfg:     cfg,
+		clock:   clock,
+		breaker: NewCircuitBreaker(cfg.Breaker, clock),
+		latency: newLatencyTracker(cfg.LatencySample),
+		jitter:  rand.Float64,
+	}
+	c.breaker.onStateChange = func(_, to BreakerState) {
+		switch to {
+		case BreakerOpen:
+			atomic.AddInt64(&c.stats.BreakerOpened, 1)
+		case BreakerHalfOpen:
+			atomic.AddInt64(&c.stats.BreakerHalfOpen, 1)
+		case BreakerClosed:
+			atomic.AddInt64(&c.stats.BreakerClosed, 1)
+		}
+	}
+	return c
+}
+
+func (c *ResilientProductClient) GetProductById(ctx context.Context, id uint64) (*ProductInfo, error) {
+	return execute(c, ctx, func(ctx context.Context) (*ProductInfo, error) {
+		return c.next.GetProductById(ctx, id)
+	})
+}
+
+func (c *ResilientProductClient) GetProductsByIds(ctx context.Context, ids []uint64) (map[uint64]*ProductInfo, error) {
+	return execute(c, ctx, func(ctx context.Context) (map[uint64]*ProductInfo, error) {
+		return c.next.GetProductsByIds(ctx, ids)
+	})
+}
+
+// execute runs fn under the breaker, retrying transient failures with
+// jittered backoff. Each attempt may be hedged.
+func execute[T any](c *ResilientProductClient, ctx context.Context, fn func(context.Context) (T, error)) (T, error) {
+	var zero T
+	atomic.AddInt64(&c.stats.Requests, 1)
+
+	for attempt := 0; ; attempt++ {
+		call, ok := c.breaker.Allow()
+		if !ok {
+			atomic.AddInt64(&c.stats.ShortCircuited, 1)
+			atomic.AddInt64(&c.stats.Failures, 1)
+			return zero, ErrCircuitOpen
+		}
+
+		start := c.clock.Now()
+		v, err := hedged(c, ctx, fn)
+		elapsed := c.clock.Now().Sub(start)
+
+		failed := err != nil && isRetryable(ctx, err)
+		c.breaker.Record(call, failed, elapsed)
+		if err == nil {
+			c.latency.observe(elapsed)
+			atomic.AddInt64(&c.stats.Successes, 1)
+			return v, nil
+		}
+
+		if !failed || attempt >= c.cfg.MaxRetries {
+			atomic.AddInt64(&c.stats.Failures, 1)
+			return zero, err
+		}
+
+		atomic.AddInt64(&c.stats.Retries, 1)
+		select {
+		case <-c.clock.After(c.backoff(attempt)):
+		case <-ctx.Done():
+			atomic.AddInt64(&c.stats.Failures, 1)
+			return zero, ctx.Err()
+		}
+	}
+}
+
+// backoff returns a full-jitter delay for the given attempt.
+func (c *ResilientProductClient) backoff(attempt int) time.Duration {
+	d := c.cfg.BaseBackoff << attempt
+	if d <= 0 || (c.cfg.MaxBackoff > 0 && d > c.cfg.MaxBackoff) {
+		d = c.cfg.MaxBackoff
+	}
+	return time.Duration(c.jitter() * float64(d))
+}
+
+type callResult[T any] struct {
+	value T
+	err   error
+	hedge bool
+}
+
+// hedged performs one logical attempt, racing a second request against the
+// first if it has not answered within the current p95 latency.
+func hedged[T any](c *ResilientProductClient, ctx context.Context, fn func(context.Context) (T, error)) (T, error) {
+	if !c.cfg.HedgeEnabled {
+		return fn(ctx)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan callResult[T], 2)
+	launch := func(hedge bool) {
+		go func() {
+			v, err := fn(ctx)
+			results <- callResult[T]{value: v, err: err, hedge: hedge}
+		}()
+	}
+
+	launch(false)
+	pending := 1
+	hedgeTimer := c.clock.After(c.hedgeDelay())
+
+	var zero T
+	var lastErr error
+	for pending > 0 {
+		select {
+		case <-hedgeTimer:
+			hedgeTimer = nil
+			atomic.AddInt64(&c.stats.Hedges, 1)
+			launch(true)
+			pending++
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				if r.hedge {
+					atomic.AddInt64(&c.stats.HedgeWins, 1)
+				}
+				return r.value, nil
+			}
+			lastErr = r.err
+			if hedgeTimer != nil {
+				// Failed before the hedge fired; let the retry loop handle it.
+				return zero, lastErr
+			}
+		}
+	}
+	return zero, lastErr
+}
+
+func (c *ResilientProductClient) hedgeDelay() time.Duration {
+	d := c.latency.percentile(0.95)
+	if d < c.cfg.HedgeMinDelay {
+		d = c.cfg.HedgeMinDelay
+	}
+	return d
+}
+
+func (c *ResilientProductClient) BreakerState() BreakerState {
+	return c.breaker.State()
+}
+
+func (c *ResilientProductClient) GetStats() map[string]interface{} {
+	return map[string]interface{}{
+		"state":             c.breaker.State().String(),
+		"requests":          atomic.LoadInt64(&c.stats.Requests),
+		"successes":         atomic.LoadInt64(&c.stats.Successes),
+		"failures":          atomic.LoadInt64(&c.stats.Failures),
+		"retries":           atomic.LoadInt64(&c.stats.Retries),
+		"hedges":            atomic.LoadInt64(&c.stats.Hedges),
+		"hedge_wins":        atomic.LoadInt64(&c.stats.HedgeWins),
+		"short_circuited":   atomic.LoadInt64(&c.stats.ShortCircuited),
+		"breaker_opened":    atomic.LoadInt64(&c.stats.BreakerOpened),
+		"breaker_half_open": atomic.LoadInt64(&c.stats.BreakerHalfOpen),
+		"breaker_closed":    atomic.LoadInt64(&c.stats.BreakerClosed),
+		"p95_latency_ms":    float64(c.latency.percentile(0.95)) / float64(time.Millisecond),
+	}
+}
+
+// isRetryable reports whether err is a transient failure worth retrying.
+// Product lookups are GETs, so any transport error or 5xx/429 is safe to
+// repeat; cancellation by the caller is not.
+func isRetryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var statusErr *ProductServiceError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= http.StatusInternalServerError ||
+			statusErr.StatusCode == http.StatusTooManyRequests
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+type latencyTracker struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+	filled  int
+}
+
+func newLatencyTracker(size int) *latencyTracker {
+	if size <= 0 {
+		size = 1
+	}
+	return &latencyTracker{samples: make([]time.Duration, size)}
+}
+
+func (t *latencyTracker) observe(d time.Duration) {
+	t.mu.Lock()
+	t.samples[t.next] = d
+	t.next = (t.next + 1) % len(t.samples)
+	if t.filled < len(t.samples) {
+		t.filled++
+	}
+	t.mu.Unlock()
+}
+
+func (t *latencyTracker) percentile(p float64) time.Duration {
+	t.mu.Lock()
+	sorted := make([]time.Duration, t.filled)
+	copy(sorted, t.samples[:t.filled])
+	t.mu.Unlock()
+
+	if len(sorted) == 0 {
+		return 0
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	idx := int(p * float64(len(sorted)-1))
+	return sorted[idx]
+}
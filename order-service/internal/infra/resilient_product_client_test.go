@@ -0,0 +1,288 @@
+package infra
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+// fakeClock only moves when Advance is called. With auto set, After fires
+// immediately and advances the clock, which keeps retry tests synchronous.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	auto    bool
+	waiters []fakeWaiter
+	slept   []time.Duration
+}
+
+func newFakeClock(auto bool) *fakeClock {
+	return &fakeClock{now: time.Unix(1700000000, 0), auto: auto}
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	f.slept = append(f.slept, d)
+	if f.auto || d <= 0 {
+		f.now = f.now.Add(d)
+		ch <- f.now
+		return ch
+	}
+	f.waiters = append(f.waiters, fakeWaiter{at: f.now.Add(d), ch: ch})
+	return ch
+}
+
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+	remaining := f.waiters[:0]
+	for _, w := range f.waiters {
+		if !w.at.After(f.now) {
+			w.ch <- f.now
+			continue
+		}
+		remaining = append(remaining, w)
+	}
+	f.waiters = remaining
+}
+
+func (f *fakeClock) waitForWaiters(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		f.mu.Lock()
+		got := len(f.waiters)
+		f.mu.Unlock()
+		if got >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d clock waiters", n)
+}
+
+type stubProductClient func(ctx context.Context, id uint64) (*ProductInfo, error)
+
+func (s stubProductClient) GetProductById(ctx context.Context, id uint64) (*ProductInfo, error) {
+	return s(ctx, id)
+}
+
+func (s stubProductClient) GetProductsByIds(ctx context.Context, ids []uint64) (map[uint64]*ProductInfo, error) {
+	out := make(map[uint64]*ProductInfo, len(ids))
+	for _, id := range ids {
+		p, err := s(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if p != nil {
+			out[id] = p
+		}
+	}
+	return out, nil
+}
+
+func testResilienceConfig() ResilienceConfig {
+	cfg := DefaultResilienceConfig()
+	cfg.Breaker = BreakerConfig{
+		WindowSize:        4,
+		MinRequests:       4,
+		FailureRate:       0.5,
+		SlowCallThreshold: 100 * time.Millisecond,
+		SlowCallRate:      0.75,
+		OpenTimeout:       time.Second,
+		HalfOpenProbes:    1,
+	}
+	return cfg
+}
+
+func TestResilientProductClient_RetriesTransientErrors(t *testing.T) {
+	clock := newFakeClock(true)
+	var calls int32
+	stub := stubProductClient(func(ctx context.Context, id uint64) (*ProductInfo, error) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			return nil, &ProductServiceError{StatusCode: 503}
+		}
+		return &ProductInfo{ID: id}, nil
+	})
+
+	c := NewResilientProductClient(stub, testResilienceConfig(), clock)
+	c.jitter = func() float64 { return 1 }
+
+	p, err := c.GetProductById(context.Background(), 7)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(7), p.ID)
+	assert.Equal(t, int32(3), calls)
+	assert.Equal(t, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond}, clock.slept)
+	assert.Equal(t, int64(2), c.GetStats()["retries"])
+}
+
+func TestResilientProductClient_DoesNotRetryClientErrors(t *testing.T) {
+	var calls int32
+	stub := stubProductClient(func(ctx context.Context, id uint64) (*ProductInfo, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, &ProductServiceError{StatusCode: 400}
+	})
+
+	c := NewResilientProductClient(stub, testResilienceConfig(), newFakeClock(true))
+
+	_, err := c.GetProductById(context.Background(), 1)
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), calls)
+	assert.Equal(t, BreakerClosed, c.BreakerState())
+}
+
+func TestResilientProductClient_BreakerOpensAndRecovers(t *testing.T) {
+	clock := newFakeClock(true)
+	var healthy atomic.Bool
+	var calls int32
+	stub := stubProductClient(func(ctx context.Context, id uint64) (*ProductInfo, error) {
+		atomic.AddInt32(&calls, 1)
+		if healthy.Load() {
+			return &ProductInfo{ID: id}, nil
+		}
+		return nil, &ProductServiceError{StatusCode: 500}
+	})
+
+	cfg := testResilienceConfig()
+	cfg.MaxRetries = 0
+	c := NewResilientProductClient(stub, cfg, clock)
+
+	for i := 0; i < 4; i++ {
+		_, _ = c.GetProductById(context.Background(), 1)
+	}
+	assert.Equal(t, BreakerOpen, c.BreakerState())
+
+	_, err := c.GetProductById(context.Background(), 1)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, int32(4), calls, "open breaker must fail fast")
+
+	clock.Advance(time.Second)
+	assert.Equal(t, BreakerHalfOpen, c.BreakerState())
+
+	healthy.Store(true)
+	_, err = c.GetProductById(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, BreakerClosed, c.BreakerState())
+
+	stats := c.GetStats()
+	assert.Equal(t, int64(1), stats["breaker_opened"])
+	assert.Equal(t, int64(1), stats["short_circuited"])
+	assert.Equal(t, int64(1), stats["breaker_closed"])
+}
+
+func TestCircuitBreaker_IgnoresLateResultsDuringHalfOpen(t *testing.T) {
+	clock := newFakeClock(false)
+	b := NewCircuitBreaker(testResilienceConfig().Breaker, clock)
+
+	late, ok := b.Allow()
+	require.True(t, ok)
+	for i := 0; i < 4; i++ {
+		call, ok := b.Allow()
+		require.True(t, ok)
+		b.Record(call, true, 0)
+	}
+	require.Equal(t, BreakerOpen, b.State())
+
+	clock.Advance(time.Second)
+	probe, ok := b.Allow()
+	require.True(t, ok)
+
+	// A call admitted while closed finishes now; it is not the probe.
+	b.Record(late, false, 0)
+	assert.Equal(t, BreakerHalfOpen, b.State())
+	_, ok = b.Allow()
+	assert.False(t, ok, "only one probe may be in flight")
+
+	b.Record(probe, false, 0)
+	assert.Equal(t, BreakerClosed, b.State())
+}
+
+func TestResilientProductClient_BreakerOpensOnSlowCalls(t *testing.T) {
+	clock := newFakeClock(false)
+	stub := stubProductClient(func(ctx context.Context, id uint64) (*ProductInfo, error) {
+		clock.Advance(150 * time.Millisecond)
+		return &ProductInfo{ID: id}, nil
+	})
+
+	c := NewResilientProductClient(stub, testResilienceConfig(), clock)
+	for i := 0; i < 4; i++ {
+		_, err := c.GetProductById(context.Background(), 1)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, BreakerOpen, c.BreakerState())
+}
+
+func TestResilientProductClient_HedgesSlowPrimary(t *testing.T) {
+	clock := newFakeClock(false)
+	release := make(chan struct{})
+	var calls int32
+	stub := stubProductClient(func(ctx context.Context, id uint64) (*ProductInfo, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			select {
+			case <-release:
+				return &ProductInfo{ID: id, Name: "primary"}, nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		return &ProductInfo{ID: id, Name: "hedge"}, nil
+	})
+
+	cfg := testResilienceConfig()
+	cfg.HedgeEnabled = true
+	cfg.HedgeMinDelay = 30 * time.Millisecond
+	c := NewResilientProductClient(stub, cfg, clock)
+	defer close(release)
+
+	done := make(chan *ProductInfo)
+	go func() {
+		p, err := c.GetProductById(context.Background(), 3)
+		assert.NoError(t, err)
+		done <- p
+	}()
+
+	clock.waitForWaiters(t, 1)
+	clock.Advance(30 * time.Millisecond)
+
+	select {
+	case p := <-done:
+		assert.Equal(t, "hedge", p.Name)
+	case <-time.After(time.Second):
+		t.Fatal("hedged request did not complete")
+	}
+	assert.Equal(t, int64(1), c.GetStats()["hedges"])
+	assert.Equal(t, int64(1), c.GetStats()["hedge_wins"])
+}
+
+func TestIsRetryable(t *testing.T) {
+	ctx := context.Background()
+	assert.True(t, isRetryable(ctx, &ProductServiceError{StatusCode: 502}))
+	assert.True(t, isRetryable(ctx, &ProductServiceError{StatusCode: 429}))
+	assert.False(t, isRetryable(ctx, &ProductServiceError{StatusCode: 404}))
+	assert.False(t, isRetryable(ctx, context.Canceled))
+	assert.False(t, isRetryable(ctx, errors.New("decode error")))
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.False(t, isRetryable(cancelled, &ProductServiceError{StatusCode: 503}))
+}
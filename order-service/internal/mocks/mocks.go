@@ -6,12 +6,17 @@ import (
 	"context"
 	"order-service/internal/domain"
 	"order-service/internal/infra"
+	"order-service/internal/repository"
+	"sync/atomic"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 )
 
 type MockOrderRepository struct {
 	mock.Mock
+	lastChange  atomic.Value
+	lastMessage atomic.Value
 }
 
 type MockProductClient struct {
@@ -35,6 +40,14 @@ func (m *MockProductClient) GetProductById(ctx context.Context, productId uint64
 	return args.Get(0).(*infra.ProductInfo), args.Error(1)
 }
 
+func (m *MockProductClient) GetProductsByIds(ctx context.Context, ids []uint64) (map[uint64]*infra.ProductInfo, error) {
+	args := m.Called(ctx, ids)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[uint64]*infra.ProductInfo), args.Error(1)
+}
+
 func (m *MockOrderRepository) Save(order *domain.Order) error {
 	args := m.Called(order)
 	return args.Error(0)
@@ -45,12 +58,15 @@ func (m *MockOrderRepository) SaveBatch(orders []*domain.Order) error {
 	return args.Error(0)
 }
 
+// FindByID returns a copy of the configured order on every call, as a
+// database would, so callers updating what they read do not race.
 func (m *MockOrderRepository) FindByID(id uint64) (*domain.Order, error) {
 	args := m.Called(id)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).(*domain.Order), args.Error(1)
+	o := *args.Get(0).(*domain.Order)
+	return &o, args.Error(1)
 }
 
 func (m *MockOrderRepository) FindByProductId(productId uint64) ([]domain.Order, error) {
@@ -59,4 +75,147 @@ func (m *MockOrderRepository) FindByProductId(productId uint64) ([]domain.Order,
 		return nil, args.Error(1)
 	}
 	return args.Get(0).([]domain.Order), args.Error(1)
-}
\ No newline at end of file
+}
+
+func (m *MockOrderRepository) FindMostOrderedProductIds(limit int) ([]uint64, error) {
+	args := m.Called(limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]uint64), args.Error(1)
+}
+
+func (m *MockOrderRepository) FindByCustomer(customerID string, after *repository.Cursor, limit int) ([]domain.Order, error) {
+	args := m.Called(customerID, after, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.Order), args.Error(1)
+}
+
+func (m *MockOrderRepository) FindForReplay(f repository.ReplayFilter, afterID uint64, limit int) ([]domain.Order, error) {
+	args := m.Called(f, afterID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.Order), args.Error(1)
+}
+
+// UpdateStatus matches on the transition (order id, from, to) so tests need
+// not build the whole history entry; use LastStatusChange and
+// LastInboxMessage to inspect what was passed.
+func (m *MockOrderRepository) UpdateStatus(change *domain.OrderStatusHistory, version uint64, msg *domain.InboxMessage) error {
+	m.lastChange.Store(change)
+	m.lastMessage.Store(msg)
+	args := m.Called(change.OrderID, change.FromStatus, change.ToStatus)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepository) LastStatusChange() *domain.OrderStatusHistory {
+	change, _ := m.lastChange.Load().(*domain.OrderStatusHistory)
+	return change
+}
+
+func (m *MockOrderRepository) LastInboxMessage() *domain.InboxMessage {
+	msg, _ := m.lastMessage.Load().(*domain.InboxMessage)
+	return msg
+}
+
+func (m *MockOrderRepository) FindStatusHistory(orderID uint64) ([]domain.OrderStatusHistory, error) {
+	args := m.Called(orderID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.OrderStatusHistory), args.Error(1)
+}
+
+type MockInboxRepository struct {
+	mock.Mock
+}
+
+func (m *MockInboxRepository) Prune(cutoff time.Time, limit int) (int64, error) {
+	args := m.Called(cutoff, limit)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+type MockAuditRepository struct {
+	mock.Mock
+}
+
+func (m *MockAuditRepository) Append(rec *domain.AuditRecord) error {
+	args := m.Called(rec)
+	return args.Error(0)
+}
+
+func (m *MockAuditRepository) List(beforeID uint64, limit int) ([]domain.AuditRecord, error) {
+	args := m.Called(beforeID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.AuditRecord), args.Error(1)
+}
+
+type MockSagaRepository struct {
+	mock.Mock
+}
+
+func (m *MockSagaRepository) Create(s *domain.Saga, entry *domain.SagaLogEntry) error {
+	args := m.Called(s, entry)
+	return args.Error(0)
+}
+
+func (m *MockSagaRepository) FindByOrderID(orderID uint64) (*domain.Saga, error) {
+	args := m.Called(orderID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Saga), args.Error(1)
+}
+
+func (m *MockSagaRepository) Update(s *domain.Saga, entry *domain.SagaLogEntry) error {
+	args := m.Called(s, entry)
+	return args.Error(0)
+}
+
+func (m *MockSagaRepository) FindStalled(cutoff time.Time, limit int) ([]domain.Saga, error) {
+	args := m.Called(cutoff, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.Saga), args.Error(1)
+}
+
+func (m *MockSagaRepository) FindLog(sagaID uint64) ([]domain.SagaLogEntry, error) {
+	args := m.Called(sagaID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.SagaLogEntry), args.Error(1)
+}
+
+type MockRefundRepository struct {
+	mock.Mock
+}
+
+func (m *MockRefundRepository) Reserve(r *domain.Refund, change *domain.OrderStatusHistory, version uint64) error {
+	args := m.Called(r, change, version)
+	return args.Error(0)
+}
+
+func (m *MockRefundRepository) Release(r *domain.Refund, change *domain.OrderStatusHistory, version uint64) error {
+	args := m.Called(r, change, version)
+	return args.Error(0)
+}
+
+func (m *MockRefundRepository) Complete(r *domain.Refund) error {
+	args := m.Called(r)
+	return args.Error(0)
+}
+
+func (m *MockRefundRepository) FindByOrderID(orderID uint64) ([]domain.Refund, error) {
+	args := m.Called(orderID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.Refund), args.Error(1)
+}
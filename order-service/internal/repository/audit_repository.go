@@ -0,0 +1,12 @@
+package repository
+
+import (
+	"order-service/internal/domain"
+)
+
+type AuditRepository interface {
+	Append(rec *domain.AuditRecord) error
+	// List returns records newest first, starting below beforeID when it
+	// is non-zero.
+	List(beforeID uint64, limit int) ([]domain.AuditRecord, error)
+}
@@ -0,0 +1,21 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrDuplicateMessage is returned when a consumed message is already in
+// the inbox, i.e. its effect has been applied before.
+var ErrDuplicateMessage = errors.New("message already processed")
+
+// VersionConflictError is returned by compare-and-swap updates when the
+// row's version is no longer the one the caller read.
+type VersionConflictError struct {
+	OrderID  uint64
+	Expected uint64
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("order %d: version %d is stale", e.OrderID, e.Expected)
+}
@@ -0,0 +1,11 @@
+package repository
+
+import "time"
+
+// InboxRepository maintains the inbox of consumed messages. Rows are
+// written by OrderRepository.UpdateStatus; this interface only cleans up.
+type InboxRepository interface {
+	// Prune deletes up to limit messages processed before cutoff and
+	// returns how many were deleted.
+	Prune(cutoff time.Time, limit int) (int64, error)
+}
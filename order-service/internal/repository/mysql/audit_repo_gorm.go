@@ -0,0 +1,38 @@
+package mysql
+
+import (
+	"log"
+	"order-service/internal/domain"
+	"order-service/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+type auditRepo struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) repository.AuditRepository {
+	return &auditRepo{db: db}
+}
+
+func (r *auditRepo) Append(rec *domain.AuditRecord) error {
+	if err := r.db.Create(rec).Error; err != nil {
+		log.Printf("Audit append error: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (r *auditRepo) List(beforeID uint64, limit int) ([]domain.AuditRecord, error) {
+	q := r.db.Order("id DESC").Limit(limit)
+	if beforeID > 0 {
+		q = q.Where("id < ?", beforeID)
+	}
+	var out []domain.AuditRecord
+	if err := q.Find(&out).Error; err != nil {
+		log.Printf("Audit list error: %v", err)
+		return nil, err
+	}
+	return out, nil
+}
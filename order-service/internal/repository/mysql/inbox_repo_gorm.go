@@ -0,0 +1,23 @@
+package mysql
+
+import (
+	"time"
+
+	"order-service/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+type inboxRepo struct {
+	db *gorm.DB
+}
+
+func NewInboxRepository(db *gorm.DB) repository.InboxRepository {
+	return &inboxRepo{db: db}
+}
+
+func (r *inboxRepo) Prune(cutoff time.Time, limit int) (int64, error) {
+	// Bounded deletes keep each statement's locks short on a busy table
+	res := r.db.Exec("DELETE FROM inbox_messages WHERE processed_at < ? LIMIT ?", cutoff, limit)
+	return res.RowsAffected, res.Error
+}
@@ -6,9 +6,13 @@ import (
 	"order-service/internal/domain"
 	"order-service/internal/repository"
 
+	mysqldrv "github.com/go-sql-driver/mysql"
 	"gorm.io/gorm"
 )
 
+// mysqlDuplicateEntry is ER_DUP_ENTRY.
+const mysqlDuplicateEntry = 1062
+
 type orderRepo struct {
     db *gorm.DB
 }
@@ -19,6 +23,9 @@ func NewOrderRepository(db *gorm.DB) repository.OrderRepository {
 
 // CRITICAL FIX: Ensure ID is properly assigned and returned
 func (r *orderRepo) Save(order *domain.Order) error {
+    if order.Version == 0 {
+        order.Version = 1
+    }
     // Use Create which will populate the ID field
     result := r.db.Create(order)
     if result.Error != nil {
@@ -42,6 +49,12 @@ func (r *orderRepo) SaveBatch(orders []*domain.Order) error {
         return nil
     }
     
+    for _, order := range orders {
+        if order.Version == 0 {
+            order.Version = 1
+        }
+    }
+
     // Use transaction for batch insert
     tx := r.db.Begin()
     defer func() {
@@ -109,4 +122,108 @@ func (r *orderRepo) FindByProductId(productId uint64) ([]domain.Order, error) {
         return nil, nil
     }
     return out, nil
-}
\ No newline at end of file
+}
+
+// FindMostOrderedProductIds returns product ids ranked by order count.
+func (r *orderRepo) FindMostOrderedProductIds(limit int) ([]uint64, error) {
+    var ids []uint64
+    err := r.db.Model(&domain.Order{}).
+        Select("product_id").
+        Group("product_id").
+        Order("COUNT(*) DESC").
+        Limit(limit).
+        Pluck("product_id", &ids).Error
+    if err != nil {
+        log.Printf("FindMostOrderedProductIds error: %v", err)
+        return nil, err
+    }
+    return ids, nil
+}
+
+// FindByCustomer pages with a keyset on (created_at, id) so it is served by
+// idx_orders_customer_created however deep the client pages.
+func (r *orderRepo) FindByCustomer(customerID string, after *repository.Cursor, limit int) ([]domain.Order, error) {
+    q := r.db.Where("customer_id = ?", customerID)
+    if after != nil {
+        q = q.Where("created_at < ? OR (created_at = ? AND id < ?)", after.CreatedAt, after.CreatedAt, after.ID)
+    }
+    var out []domain.Order
+    if err := q.Order("created_at DESC, id DESC").Limit(limit).Find(&out).Error; err != nil {
+        log.Printf("FindByCustomer error: %v", err)
+        return nil, err
+    }
+    return out, nil
+}
+
+// FindForReplay walks the primary key, so a long replay pages cheaply
+// whatever else the filter restricts.
+func (r *orderRepo) FindForReplay(f repository.ReplayFilter, afterID uint64, limit int) ([]domain.Order, error) {
+    q := r.db.Where("id > ?", afterID)
+    if f.FromID > 0 {
+        q = q.Where("id >= ?", f.FromID)
+    }
+    if f.ToID > 0 {
+        q = q.Where("id <= ?", f.ToID)
+    }
+    if len(f.Statuses) > 0 {
+        q = q.Where("status IN ?", f.Statuses)
+    }
+    if f.ProductID > 0 {
+        q = q.Where("product_id = ?", f.ProductID)
+    }
+    if !f.CreatedFrom.IsZero() {
+        q = q.Where("created_at >= ?", f.CreatedFrom)
+    }
+    if !f.CreatedTo.IsZero() {
+        q = q.Where("created_at < ?", f.CreatedTo)
+    }
+    var out []domain.Order
+    if err := q.Order("id ASC").Limit(limit).Find(&out).Error; err != nil {
+        log.Printf("FindForReplay error: %v", err)
+        return nil, err
+    }
+    return out, nil
+}
+
+func (r *orderRepo) UpdateStatus(change *domain.OrderStatusHistory, version uint64, msg *domain.InboxMessage) error {
+    err := r.db.Transaction(func(tx *gorm.DB) error {
+        // Claim the message first: the primary key makes concurrent
+        // redeliveries serialize here and all but one fail
+        if msg != nil {
+            if err := tx.Create(msg).Error; err != nil {
+                var myErr *mysqldrv.MySQLError
+                if errors.As(err, &myErr) && myErr.Number == mysqlDuplicateEntry {
+                    return repository.ErrDuplicateMessage
+                }
+                return err
+            }
+        }
+        result := tx.Model(&domain.Order{}).
+            Where("id = ? AND version = ?", change.OrderID, version).
+            Updates(map[string]interface{}{
+                "status":  change.ToStatus,
+                "version": gorm.Expr("version + 1"),
+            })
+        if result.Error != nil {
+            return result.Error
+        }
+        if result.RowsAffected != 1 {
+            return &repository.VersionConflictError{OrderID: change.OrderID, Expected: version}
+        }
+        return tx.Create(change).Error
+    })
+    var conflict *repository.VersionConflictError
+    if err != nil && !errors.As(err, &conflict) && !errors.Is(err, repository.ErrDuplicateMessage) {
+        log.Printf("UpdateStatus error: %v", err)
+    }
+    return err
+}
+
+func (r *orderRepo) FindStatusHistory(orderID uint64) ([]domain.OrderStatusHistory, error) {
+    var out []domain.OrderStatusHistory
+    if err := r.db.Where("order_id = ?", orderID).Order("id ASC").Find(&out).Error; err != nil {
+        log.Printf("FindStatusHistory error: %v", err)
+        return nil, err
+    }
+    return out, nil
+}
@@ -0,0 +1,92 @@
+package mysql
+
+import (
+	"errors"
+	"log"
+
+	"order-service/internal/domain"
+	"order-service/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+type refundRepo struct {
+	db *gorm.DB
+}
+
+func NewRefundRepository(db *gorm.DB) repository.RefundRepository {
+	return &refundRepo{db: db}
+}
+
+func (r *refundRepo) Reserve(refund *domain.Refund, change *domain.OrderStatusHistory, version uint64) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := updateRefundedAmount(tx, change, version, refund.Amount); err != nil {
+			return err
+		}
+		return tx.Create(refund).Error
+	})
+	logRefundError("Reserve", err)
+	return err
+}
+
+func (r *refundRepo) Release(refund *domain.Refund, change *domain.OrderStatusHistory, version uint64) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := updateRefundedAmount(tx, change, version, -refund.Amount); err != nil {
+			return err
+		}
+		return tx.Model(refund).Updates(map[string]interface{}{
+			"status":         refund.Status,
+			"failure_reason": refund.FailureReason,
+			"updated_at":     refund.UpdatedAt,
+		}).Error
+	})
+	logRefundError("Release", err)
+	return err
+}
+
+// updateRefundedAmount adds delta to the order's refunded amount and moves
+// it to change.ToStatus if it is still at version.
+func updateRefundedAmount(tx *gorm.DB, change *domain.OrderStatusHistory, version uint64, delta int64) error {
+	result := tx.Model(&domain.Order{}).
+		Where("id = ? AND version = ?", change.OrderID, version).
+		Updates(map[string]interface{}{
+			"status":          change.ToStatus,
+			"refunded_amount": gorm.Expr("refunded_amount + ?", delta),
+			"version":         gorm.Expr("version + 1"),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != 1 {
+		return &repository.VersionConflictError{OrderID: change.OrderID, Expected: version}
+	}
+	if change.FromStatus == change.ToStatus {
+		return nil
+	}
+	return tx.Create(change).Error
+}
+
+func logRefundError(op string, err error) {
+	var conflict *repository.VersionConflictError
+	if err != nil && !errors.As(err, &conflict) {
+		log.Printf("Refund %s error: %v", op, err)
+	}
+}
+
+func (r *refundRepo) Complete(refund *domain.Refund) error {
+	err := r.db.Model(refund).Updates(map[string]interface{}{
+		"status":     refund.Status,
+		"updated_at": refund.UpdatedAt,
+	}).Error
+	logRefundError("Complete", err)
+	return err
+}
+
+func (r *refundRepo) FindByOrderID(orderID uint64) ([]domain.Refund, error) {
+	var out []domain.Refund
+	if err := r.db.Where("order_id = ?", orderID).Order("id ASC").Find(&out).Error; err != nil {
+		log.Printf("Refund FindByOrderID error: %v", err)
+		return nil, err
+	}
+	return out, nil
+}
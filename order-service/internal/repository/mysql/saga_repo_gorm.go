@@ -0,0 +1,99 @@
+package mysql
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"order-service/internal/domain"
+	"order-service/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+type sagaRepo struct {
+	db *gorm.DB
+}
+
+func NewSagaRepository(db *gorm.DB) repository.SagaRepository {
+	return &sagaRepo{db: db}
+}
+
+func (r *sagaRepo) Create(s *domain.Saga, entry *domain.SagaLogEntry) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(s).Error; err != nil {
+			return err
+		}
+		entry.SagaID = s.ID
+		return tx.Create(entry).Error
+	})
+	if err != nil {
+		log.Printf("Saga create error: %v", err)
+	}
+	return err
+}
+
+func (r *sagaRepo) FindByOrderID(orderID uint64) (*domain.Saga, error) {
+	var s domain.Saga
+	if err := r.db.Where("order_id = ?", orderID).First(&s).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		log.Printf("Saga FindByOrderID error: %v", err)
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *sagaRepo) Update(s *domain.Saga, entry *domain.SagaLogEntry) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&domain.Saga{}).
+			Where("id = ? AND version = ?", s.ID, s.Version).
+			Updates(map[string]interface{}{
+				"state":          s.State,
+				"step":           s.Step,
+				"step_name":      s.StepName,
+				"attempts":       s.Attempts,
+				"failure_reason": s.FailureReason,
+				"updated_at":     s.UpdatedAt,
+				"version":        gorm.Expr("version + 1"),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return &repository.VersionConflictError{OrderID: s.OrderID, Expected: s.Version}
+		}
+		entry.SagaID = s.ID
+		return tx.Create(entry).Error
+	})
+	var conflict *repository.VersionConflictError
+	if err != nil {
+		if !errors.As(err, &conflict) {
+			log.Printf("Saga update error: %v", err)
+		}
+		return err
+	}
+	s.Version++
+	return nil
+}
+
+func (r *sagaRepo) FindStalled(cutoff time.Time, limit int) ([]domain.Saga, error) {
+	var out []domain.Saga
+	err := r.db.Where("state IN ? AND updated_at < ?", []domain.SagaState{domain.SagaRunning, domain.SagaCompensating}, cutoff).
+		Order("updated_at ASC").Limit(limit).Find(&out).Error
+	if err != nil {
+		log.Printf("Saga FindStalled error: %v", err)
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *sagaRepo) FindLog(sagaID uint64) ([]domain.SagaLogEntry, error) {
+	var out []domain.SagaLogEntry
+	if err := r.db.Where("saga_id = ?", sagaID).Order("id ASC").Find(&out).Error; err != nil {
+		log.Printf("Saga FindLog error: %v", err)
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,216 @@
+package mysql
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"order-service/internal/domain"
+	"order-service/internal/repository"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type webhookRepo struct {
+	db *gorm.DB
+}
+
+func NewWebhookRepository(db *gorm.DB) repository.WebhookRepository {
+	return &webhookRepo{db: db}
+}
+
+func (r *webhookRepo) CreateEndpoint(e *domain.WebhookEndpoint) error {
+	if err := r.db.Create(e).Error; err != nil {
+		log.Printf("Webhook CreateEndpoint error: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (r *webhookRepo) FindEndpoint(id uint64) (*domain.WebhookEndpoint, error) {
+	var e domain.WebhookEndpoint
+	if err := r.db.First(&e, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		log.Printf("Webhook FindEndpoint error: %v", err)
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (r *webhookRepo) ListEndpoints(customerID string) ([]domain.WebhookEndpoint, error) {
+	q := r.db.Order("id ASC")
+	if customerID != "" {
+		q = q.Where("customer_id = ?", customerID)
+	}
+	var out []domain.WebhookEndpoint
+	if err := q.Find(&out).Error; err != nil {
+		log.Printf("Webhook ListEndpoints error: %v", err)
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *webhookRepo) UpdateEndpoint(e *domain.WebhookEndpoint) error {
+	if err := r.db.Save(e).Error; err != nil {
+		log.Printf("Webhook UpdateEndpoint error: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (r *webhookRepo) DeleteEndpoint(id uint64) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		deliveries := tx.Model(&domain.WebhookDelivery{}).Select("id").Where("endpoint_id = ?", id)
+		if err := tx.Where("delivery_id IN (?)", deliveries).Delete(&domain.WebhookAttempt{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("endpoint_id = ?", id).Delete(&domain.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&domain.WebhookEndpoint{}, id).Error
+	})
+	if err != nil {
+		log.Printf("Webhook DeleteEndpoint error: %v", err)
+	}
+	return err
+}
+
+func (r *webhookRepo) FindSubscribers(customerID string) ([]domain.WebhookEndpoint, error) {
+	var out []domain.WebhookEndpoint
+	err := r.db.Where("enabled = ? AND customer_id IN ?", true, []string{"", customerID}).
+		Order("id ASC").Find(&out).Error
+	if err != nil {
+		log.Printf("Webhook FindSubscribers error: %v", err)
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *webhookRepo) Enqueue(deliveries []*domain.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	if err := r.db.Create(deliveries).Error; err != nil {
+		log.Printf("Webhook Enqueue error: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (r *webhookRepo) ClaimDue(now, leaseUntil time.Time, limit int) ([]domain.WebhookDelivery, error) {
+	var out []domain.WebhookDelivery
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		enabled := tx.Model(&domain.WebhookEndpoint{}).Select("id").Where("enabled = ?", true)
+		// SKIP LOCKED lets replicas claim disjoint batches concurrently
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: domain.WebhookDelivery{}.TableName()}, Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ? AND endpoint_id IN (?)", domain.WebhookPending, now, enabled).
+			Order("next_attempt_at ASC").Limit(limit).Find(&out).Error
+		if err != nil || len(out) == 0 {
+			return err
+		}
+		ids := make([]uint64, len(out))
+		for i := range out {
+			ids[i] = out[i].ID
+		}
+		return tx.Model(&domain.WebhookDelivery{}).Where("id IN ?", ids).
+			Update("next_attempt_at", leaseUntil).Error
+	})
+	if err != nil {
+		log.Printf("Webhook ClaimDue error: %v", err)
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *webhookRepo) RecordAttempt(d *domain.WebhookDelivery, a *domain.WebhookAttempt, succeeded bool) (int, error) {
+	var failures int
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		a.DeliveryID = d.ID
+		if err := tx.Create(a).Error; err != nil {
+			return err
+		}
+		err := tx.Model(d).Updates(map[string]interface{}{
+			"status":           d.Status,
+			"attempts":         d.Attempts,
+			"next_attempt_at":  d.NextAttemptAt,
+			"last_status_code": d.LastStatusCode,
+			"last_error":       d.LastError,
+			"delivered_at":     d.DeliveredAt,
+			"updated_at":       d.UpdatedAt,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		failed := gorm.Expr("consecutive_failures + 1")
+		if succeeded {
+			failed = gorm.Expr("0")
+		}
+		err = tx.Model(&domain.WebhookEndpoint{}).Where("id = ?", d.EndpointID).
+			Update("consecutive_failures", failed).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&domain.WebhookEndpoint{}).Where("id = ?", d.EndpointID).
+			Pluck("consecutive_failures", &failures).Error
+	})
+	if err != nil {
+		log.Printf("Webhook RecordAttempt error: %v", err)
+		return 0, err
+	}
+	return failures, nil
+}
+
+func (r *webhookRepo) DisableEndpoint(id uint64, at time.Time, reason string) error {
+	err := r.db.Model(&domain.WebhookEndpoint{}).Where("id = ? AND enabled = ?", id, true).
+		Updates(map[string]interface{}{
+			"enabled":         false,
+			"disabled_at":     at,
+			"disabled_reason": reason,
+			"updated_at":      at,
+		}).Error
+	if err != nil {
+		log.Printf("Webhook DisableEndpoint error: %v", err)
+	}
+	return err
+}
+
+func (r *webhookRepo) ListDeliveries(endpointID uint64, status domain.WebhookDeliveryStatus, beforeID uint64, limit int) ([]domain.WebhookDelivery, error) {
+	q := r.db.Where("endpoint_id = ?", endpointID)
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	if beforeID != 0 {
+		q = q.Where("id < ?", beforeID)
+	}
+	var out []domain.WebhookDelivery
+	if err := q.Order("id DESC").Limit(limit).Find(&out).Error; err != nil {
+		log.Printf("Webhook ListDeliveries error: %v", err)
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *webhookRepo) FindDelivery(id uint64) (*domain.WebhookDelivery, error) {
+	var d domain.WebhookDelivery
+	if err := r.db.First(&d, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		log.Printf("Webhook FindDelivery error: %v", err)
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *webhookRepo) FindAttempts(deliveryID uint64) ([]domain.WebhookAttempt, error) {
+	var out []domain.WebhookAttempt
+	if err := r.db.Where("delivery_id = ?", deliveryID).Order("id ASC").Find(&out).Error; err != nil {
+		log.Printf("Webhook FindAttempts error: %v", err)
+		return nil, err
+	}
+	return out, nil
+}
@@ -2,11 +2,48 @@ package repository
 
 import (
 	"order-service/internal/domain"
+	"time"
 )
 
+// Cursor marks a position in a newest-first listing; the next page starts
+// strictly after it.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        uint64
+}
+
+// ReplayFilter selects orders whose events are published again. Zero
+// fields do not restrict the selection. The id range is inclusive and the
+// creation window is [CreatedFrom, CreatedTo).
+type ReplayFilter struct {
+	FromID      uint64               `json:"fromId,omitempty"`
+	ToID        uint64               `json:"toId,omitempty"`
+	Statuses    []domain.OrderStatus `json:"statuses,omitempty"`
+	ProductID   uint64               `json:"productId,omitempty"`
+	CreatedFrom time.Time            `json:"createdFrom,omitempty"`
+	CreatedTo   time.Time            `json:"createdTo,omitempty"`
+}
+
 type OrderRepository interface {
 	Save(order *domain.Order) error
 	SaveBatch(orders []*domain.Order) error  
 	FindByID(id uint64) (*domain.Order, error)
 	FindByProductId(id uint64) ([]domain.Order, error)
+	FindMostOrderedProductIds(limit int) ([]uint64, error)
+	// FindByCustomer lists a customer's orders newest first, starting after
+	// the cursor when one is given.
+	FindByCustomer(customerID string, after *Cursor, limit int) ([]domain.Order, error)
+	// UpdateStatus moves change.OrderID to change.ToStatus and appends
+	// change to the status history in the same transaction, provided the
+	// order is still at version. The order's version is then version+1.
+	// A *VersionConflictError means the order moved on and nothing was
+	// written. When the change is caused by a consumed message, msg is
+	// added to the inbox in that transaction too; ErrDuplicateMessage means
+	// it was already there.
+	UpdateStatus(change *domain.OrderStatusHistory, version uint64, msg *domain.InboxMessage) error
+	// FindForReplay returns up to limit orders matching f with an id
+	// greater than afterID, in id order.
+	FindForReplay(f ReplayFilter, afterID uint64, limit int) ([]domain.Order, error)
+	// FindStatusHistory returns an order's status changes, oldest first.
+	FindStatusHistory(orderID uint64) ([]domain.OrderStatusHistory, error)
 }
\ No newline at end of file
@@ -0,0 +1,23 @@
+package repository
+
+import "order-service/internal/domain"
+
+// RefundRepository persists refunds together with the order they change,
+// so an order's refunded amount always matches its pending and succeeded
+// refunds.
+type RefundRepository interface {
+	// Reserve inserts r, adds r.Amount to the order's refunded amount and
+	// moves the order to change.ToStatus, provided the order is still at
+	// version; otherwise it returns a *VersionConflictError and writes
+	// nothing. change is appended to the status history unless the status
+	// stays the same. The order's version is then version+1.
+	Reserve(r *domain.Refund, change *domain.OrderStatusHistory, version uint64) error
+	// Release writes r's status and failure reason and takes r.Amount off
+	// the order again, under the same version check and history rule as
+	// Reserve.
+	Release(r *domain.Refund, change *domain.OrderStatusHistory, version uint64) error
+	// Complete writes r's status.
+	Complete(r *domain.Refund) error
+	// FindByOrderID returns an order's refunds, oldest first.
+	FindByOrderID(orderID uint64) ([]domain.Refund, error)
+}
@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"time"
+
+	"order-service/internal/domain"
+)
+
+// SagaRepository persists fulfilment sagas. Every write appends a log
+// entry in the same transaction, so the log always matches the saga.
+type SagaRepository interface {
+	// Create inserts s and its first log entry, setting both ids.
+	Create(s *domain.Saga, entry *domain.SagaLogEntry) error
+	// FindByOrderID returns nil without error when the order has no saga.
+	FindByOrderID(orderID uint64) (*domain.Saga, error)
+	// Update writes s if its version is still s.Version and bumps it;
+	// otherwise it returns a *VersionConflictError.
+	Update(s *domain.Saga, entry *domain.SagaLogEntry) error
+	// FindStalled returns up to limit running or compensating sagas last
+	// updated before cutoff, oldest first.
+	FindStalled(cutoff time.Time, limit int) ([]domain.Saga, error)
+	// FindLog returns a saga's log, oldest first.
+	FindLog(sagaID uint64) ([]domain.SagaLogEntry, error)
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"time"
+
+	"order-service/internal/domain"
+)
+
+// WebhookRepository persists webhook endpoints, their delivery queue and
+// the log of delivery attempts.
+type WebhookRepository interface {
+	// CreateEndpoint inserts e and sets its id.
+	CreateEndpoint(e *domain.WebhookEndpoint) error
+	// FindEndpoint returns nil without error when there is no such
+	// endpoint.
+	FindEndpoint(id uint64) (*domain.WebhookEndpoint, error)
+	// ListEndpoints returns customerID's endpoints, or every endpoint when
+	// customerID is empty, oldest first.
+	ListEndpoints(customerID string) ([]domain.WebhookEndpoint, error)
+	// UpdateEndpoint writes every field of e.
+	UpdateEndpoint(e *domain.WebhookEndpoint) error
+	// DeleteEndpoint removes an endpoint with its deliveries and their
+	// attempts.
+	DeleteEndpoint(id uint64) error
+	// FindSubscribers returns the enabled endpoints that hear about orders
+	// of customerID: those of that customer and those of no customer.
+	FindSubscribers(customerID string) ([]domain.WebhookEndpoint, error)
+
+	// Enqueue inserts deliveries and sets their ids.
+	Enqueue(deliveries []*domain.WebhookDelivery) error
+	// ClaimDue returns up to limit pending deliveries of enabled endpoints
+	// that are due at now, oldest due first, and moves their next attempt
+	// to leaseUntil so other replicas skip them while they are being sent.
+	ClaimDue(now, leaseUntil time.Time, limit int) ([]domain.WebhookDelivery, error)
+	// RecordAttempt appends a to the log and writes d's status, attempts,
+	// next attempt and last result in one transaction. The endpoint's
+	// consecutive failures are reset when succeeded and incremented
+	// otherwise; the new count is returned.
+	RecordAttempt(d *domain.WebhookDelivery, a *domain.WebhookAttempt, succeeded bool) (int, error)
+	// DisableEndpoint stops deliveries to an endpoint. Its pending
+	// deliveries stay queued until it is enabled again.
+	DisableEndpoint(id uint64, at time.Time, reason string) error
+
+	// ListDeliveries returns up to limit deliveries of an endpoint, newest
+	// first, with an id below beforeID unless it is zero. An empty status
+	// matches every status.
+	ListDeliveries(endpointID uint64, status domain.WebhookDeliveryStatus, beforeID uint64, limit int) ([]domain.WebhookDelivery, error)
+	// FindDelivery returns nil without error when there is no such
+	// delivery.
+	FindDelivery(id uint64) (*domain.WebhookDelivery, error)
+	// FindAttempts returns a delivery's attempts, oldest first.
+	FindAttempts(deliveryID uint64) ([]domain.WebhookAttempt, error)
+}
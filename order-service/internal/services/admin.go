@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"order-service/internal/domain"
+	"order-service/internal/repository"
+)
+
+// OverrideStatus forces an order into a status regardless of the normal
+// flow, e.g. to fix an order stuck in pending after a lost event. A
+// non-zero ifVersion makes the override conditional on the order still
+// being at that version; it fails with ErrVersionMismatch otherwise.
+// Overrides are never retried: the operator decided based on what they saw.
+func (u *OrderService) OverrideStatus(ctx context.Context, id uint64, to domain.OrderStatus, reason string, ifVersion uint64) (*domain.Order, error) {
+	if !to.IsValid() {
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidOrder, to)
+	}
+
+	o, err := u.GetOrderById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if ifVersion != 0 && o.Version != ifVersion {
+		return nil, ErrVersionMismatch
+	}
+	if o.Status == to {
+		return o, nil
+	}
+
+	err = u.updateStatus(ctx, o, to, "override: "+reason)
+	var conflict *repository.VersionConflictError
+	switch {
+	case err == nil:
+		return o, nil
+	case !errors.As(err, &conflict):
+		return nil, storageError(err)
+	case ifVersion != 0:
+		return nil, fmt.Errorf("%w: %w", ErrVersionMismatch, err)
+	default:
+		return nil, fmt.Errorf("%w: %w", ErrConcurrentUpdate, err)
+	}
+}
+
+// FlushProductCache drops cached product lookups from this replica and
+// from Redis, returning how many Redis keys were removed.
+func (u *OrderService) FlushProductCache(ctx context.Context) (int, error) {
+	u.localCache.Range(func(key, _ any) bool {
+		u.localCache.Delete(key)
+		return true
+	})
+	if u.redisClient == nil {
+		return 0, nil
+	}
+
+	removed := 0
+	iter := u.redisClient.Scan(ctx, 0, "product:*", 500).Iterator()
+	var batch []string
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		n, err := u.redisClient.Del(ctx, batch...).Result()
+		removed += int(n)
+		batch = batch[:0]
+		return err
+	}
+	for iter.Next(ctx) {
+		batch = append(batch, iter.Val())
+		if len(batch) == 500 {
+			if err := flush(); err != nil {
+				return removed, fmt.Errorf("%w: %w", ErrStorageUnavailable, err)
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return removed, fmt.Errorf("%w: %w", ErrStorageUnavailable, err)
+	}
+	if err := flush(); err != nil {
+		return removed, fmt.Errorf("%w: %w", ErrStorageUnavailable, err)
+	}
+	log.Printf("Product cache flushed: %d Redis keys", removed)
+	return removed, nil
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"order-service/internal/domain"
+	"order-service/internal/mocks"
+	"order-service/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderService_OverrideStatus(t *testing.T) {
+	mockRepo := new(mocks.MockOrderRepository)
+	service := NewOrderService(mockRepo, new(mocks.MockProductClient), new(mocks.MockPublisher))
+
+	mockRepo.On("FindByID", uint64(1)).Return(&domain.Order{ID: 1, Status: domain.StatusFailed, Version: 4}, nil)
+	mockRepo.On("UpdateStatus", uint64(1), domain.StatusFailed, domain.StatusConfirmed).
+		Return(&repository.VersionConflictError{OrderID: 1, Expected: 4})
+
+	_, err := service.OverrideStatus(context.Background(), 1, "shipped", "typo", 0)
+	assert.ErrorIs(t, err, ErrInvalidOrder)
+
+	o, err := service.OverrideStatus(context.Background(), 1, domain.StatusFailed, "no-op", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.StatusFailed, o.Status)
+
+	_, err = service.OverrideStatus(context.Background(), 1, domain.StatusConfirmed, "stale read", 3)
+	assert.ErrorIs(t, err, ErrVersionMismatch)
+	mockRepo.AssertNotCalled(t, "UpdateStatus", uint64(1), domain.StatusFailed, domain.StatusConfirmed)
+
+	_, err = service.OverrideStatus(context.Background(), 1, domain.StatusConfirmed, "raced", 4)
+	assert.ErrorIs(t, err, ErrVersionMismatch)
+
+	_, err = service.OverrideStatus(context.Background(), 1, domain.StatusConfirmed, "raced", 0)
+	assert.ErrorIs(t, err, ErrConcurrentUpdate)
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"order-service/internal/auth"
+	"order-service/internal/domain"
+	"order-service/internal/repository"
+)
+
+// AuditLog records privileged actions. Entries are attributed to the
+// principal in ctx, or "system" for internal callers.
+type AuditLog struct {
+	repo repository.AuditRepository
+}
+
+func NewAuditLog(repo repository.AuditRepository) *AuditLog {
+	return &AuditLog{repo: repo}
+}
+
+// Record appends an entry. A failure is logged but not returned: the
+// action has already happened and must not be reported as failed.
+func (a *AuditLog) Record(ctx context.Context, action, target, detail, outcome string) {
+	actor := "system"
+	if p, ok := auth.FromContext(ctx); ok {
+		actor = p.ID()
+	}
+	rec := &domain.AuditRecord{
+		Actor:      actor,
+		Action:     action,
+		Target:     target,
+		Detail:     detail,
+		Outcome:    outcome,
+		OccurredAt: time.Now(),
+	}
+	if err := a.repo.Append(rec); err != nil {
+		log.Printf("AUDIT WRITE FAILED actor=%s action=%s target=%s outcome=%s: %v", actor, action, target, outcome, err)
+	}
+}
+
+// Recent returns audit entries newest first.
+func (a *AuditLog) Recent(ctx context.Context, beforeID uint64, limit int) ([]domain.AuditRecord, error) {
+	if limit <= 0 || limit > MaxPageSize {
+		limit = DefaultPageSize
+	}
+	recs, err := a.repo.List(beforeID, limit)
+	if err != nil {
+		return nil, storageError(err)
+	}
+	if recs == nil {
+		recs = []domain.AuditRecord{}
+	}
+	return recs, nil
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"order-service/internal/auth"
+	"order-service/internal/infra"
+)
+
+// Priority decides which requests are shed first when the service is
+// saturated. Lower priorities are rejected while headroom is still left for
+// higher ones.
+type Priority int
+
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityCritical
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityCritical:
+		return "critical"
+	default:
+		return "normal"
+	}
+}
+
+// ParsePriority maps a transport-level priority hint to a Priority. Unknown
+// or empty values are treated as normal.
+func ParsePriority(s string) Priority {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "low":
+		return PriorityLow
+	case "critical", "high":
+		return PriorityCritical
+	default:
+		return PriorityNormal
+	}
+}
+
+// RequestedPriority parses a transport-level priority hint from the caller
+// in ctx. Only authenticated service principals may ask for critical;
+// anyone else asking for it gets normal.
+func RequestedPriority(ctx context.Context, hint string) Priority {
+	p := ParsePriority(hint)
+	if p <= PriorityNormal {
+		return p
+	}
+	if caller, ok := auth.FromContext(ctx); ok && caller.CustomerID == "" {
+		return p
+	}
+	return PriorityNormal
+}
+
+type priorityKey struct{}
+
+// WithPriority attaches a request priority to ctx.
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+func priorityFrom(ctx context.Context) Priority {
+	if p, ok := ctx.Value(priorityKey{}).(Priority); ok {
+		return p
+	}
+	return PriorityNormal
+}
+
+type LimiterConfig struct {
+	InitialLimit  int
+	MinLimit      int
+	MaxLimit      int
+	LatencyTarget time.Duration // completions slower than this count as congestion
+	BackoffRatio  float64       // multiplicative decrease applied on congestion
+
+	// Fraction of the current limit available to each priority below
+	// critical; the rest is reserved for more important traffic.
+	LowShare    float64
+	NormalShare float64
+}
+
+func DefaultLimiterConfig() LimiterConfig {
+	return LimiterConfig{
+		InitialLimit:  100,
+		MinLimit:      10,
+		MaxLimit:      1000,
+		LatencyTarget: 300 * time.Millisecond,
+		BackoffRatio:  0.9,
+		LowShare:      0.5,
+		NormalShare:   0.9,
+	}
+}
+
+// AdaptiveLimiter is an AIMD concurrency limiter. The limit grows by one for
+// every limit's worth of fast completions while it is actually being used,
+// and shrinks multiplicatively whenever a request is slow or fails because
+// a dependency is saturated. Requests over the limit are rejected
+// immediately rather than queued.
+type AdaptiveLimiter struct {
+	cfg   LimiterConfig
+	clock infra.Clock
+
+	mu       sync.Mutex
+	limit    float64
+	inflight int
+
+	accepted  int64
+	rejected  [PriorityCritical + 1]int64
+	increases int64
+	decreases int64
+}
+
+func NewAdaptiveLimiter(cfg LimiterConfig, clock infra.Clock) *AdaptiveLimiter {
+	if clock == nil {
+		clock = infra.RealClock()
+	}
+	if cfg.MinLimit <= 0 {
+		cfg.MinLimit = 1
+	}
+	if cfg.MaxLimit < cfg.MinLimit {
+		cfg.MaxLimit = cfg.MinLimit
+	}
+	if cfg.InitialLimit < cfg.MinLimit {
+		cfg.InitialLimit = cfg.MinLimit
+	}
+	if cfg.InitialLimit > cfg.MaxLimit {
+		cfg.InitialLimit = cfg.MaxLimit
+	}
+	if cfg.BackoffRatio <= 0 || cfg.BackoffRatio >= 1 {
+		cfg.BackoffRatio = 0.9
+	}
+	return &AdaptiveLimiter{
+		cfg:   cfg,
+		clock: clock,
+		limit: float64(cfg.InitialLimit),
+	}
+}
+
+// Acquire admits a request of the given priority. When admitted, the
+// returned release function must be called exactly once with whether the
+// request failed because of congestion.
+func (l *AdaptiveLimiter) Acquire(p Priority) (release func(congested bool), ok bool) {
+	l.mu.Lock()
+	if float64(l.inflight) >= l.capacityLocked(p) {
+		l.rejected[p]++
+		l.mu.Unlock()
+		return nil, false
+	}
+	l.inflight++
+	l.accepted++
+	l.mu.Unlock()
+
+	start := l.clock.Now()
+	var once sync.Once
+	return func(congested bool) {
+		once.Do(func() {
+			l.complete(l.clock.Now().Sub(start), congested)
+		})
+	}, true
+}
+
+// capacityLocked must be called with mu held.
+func (l *AdaptiveLimiter) capacityLocked(p Priority) float64 {
+	limit := math.Floor(l.limit)
+	switch p {
+	case PriorityLow:
+		return math.Max(1, math.Floor(limit*l.cfg.LowShare))
+	case PriorityNormal:
+		return math.Max(1, math.Floor(limit*l.cfg.NormalShare))
+	default:
+		return limit
+	}
+}
+
+func (l *AdaptiveLimiter) complete(latency time.Duration, congested bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	inflight := l.inflight
+	l.inflight--
+
+	if congested || (l.cfg.LatencyTarget > 0 && latency > l.cfg.LatencyTarget) {
+		l.limit = math.Max(float64(l.cfg.MinLimit), l.limit*l.cfg.BackoffRatio)
+		l.decreases++
+		return
+	}
+	// Only grow when the limit is the bottleneck; an idle service has not
+	// proven it can handle more.
+	if float64(inflight)*2 >= l.limit && l.limit < float64(l.cfg.MaxLimit) {
+		l.limit = math.Min(float64(l.cfg.MaxLimit), l.limit+1/l.limit)
+		l.increases++
+	}
+}
+
+// Limit returns the current concurrency limit.
+func (l *AdaptiveLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+func (l *AdaptiveLimiter) GetStats() map[string]interface{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return map[string]interface{}{
+		"limit":             int(l.limit),
+		"inflight":          l.inflight,
+		"accepted":          l.accepted,
+		"rejected_low":      l.rejected[PriorityLow],
+		"rejected_normal":   l.rejected[PriorityNormal],
+		"rejected_critical": l.rejected[PriorityCritical],
+		"limit_increases":   l.increases,
+		"limit_decreases":   l.decreases,
+	}
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"order-service/internal/auth"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stepClock is a manual clock for latency-sensitive tests.
+type stepClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *stepClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *stepClock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	ch <- c.Now().Add(d)
+	return ch
+}
+
+func (c *stepClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+func testLimiterConfig() LimiterConfig {
+	cfg := DefaultLimiterConfig()
+	cfg.InitialLimit = 10
+	cfg.MinLimit = 2
+	cfg.MaxLimit = 20
+	cfg.LatencyTarget = 100 * time.Millisecond
+	cfg.BackoffRatio = 0.5
+	return cfg
+}
+
+func TestAdaptiveLimiter_ShedsLowPriorityFirst(t *testing.T) {
+	l := NewAdaptiveLimiter(testLimiterConfig(), &stepClock{})
+
+	for i := 0; i < 5; i++ {
+		_, ok := l.Acquire(PriorityLow)
+		require.True(t, ok)
+	}
+	_, ok := l.Acquire(PriorityLow)
+	assert.False(t, ok, "low priority only gets half the limit")
+
+	for i := 0; i < 4; i++ {
+		_, ok := l.Acquire(PriorityNormal)
+		require.True(t, ok)
+	}
+	_, ok = l.Acquire(PriorityNormal)
+	assert.False(t, ok, "normal priority leaves headroom for critical")
+
+	_, ok = l.Acquire(PriorityCritical)
+	assert.True(t, ok)
+	_, ok = l.Acquire(PriorityCritical)
+	assert.False(t, ok)
+
+	stats := l.GetStats()
+	assert.Equal(t, int64(1), stats["rejected_low"])
+	assert.Equal(t, int64(1), stats["rejected_normal"])
+	assert.Equal(t, int64(1), stats["rejected_critical"])
+	assert.Equal(t, 10, stats["inflight"])
+}
+
+func TestAdaptiveLimiter_DecreasesOnSlowOrCongestedCalls(t *testing.T) {
+	clock := &stepClock{}
+	l := NewAdaptiveLimiter(testLimiterConfig(), clock)
+
+	release, ok := l.Acquire(PriorityNormal)
+	require.True(t, ok)
+	clock.Advance(150 * time.Millisecond)
+	release(false)
+	assert.Equal(t, 5, l.Limit())
+
+	release, _ = l.Acquire(PriorityNormal)
+	release(true)
+	assert.Equal(t, 2, l.Limit(), "limit never drops below the minimum")
+}
+
+func TestAdaptiveLimiter_GrowsOnlyWhenUtilised(t *testing.T) {
+	l := NewAdaptiveLimiter(testLimiterConfig(), &stepClock{})
+
+	release, _ := l.Acquire(PriorityCritical)
+	release(false)
+	assert.Equal(t, int64(0), l.GetStats()["limit_increases"], "an idle limiter must not grow")
+
+	for round := 0; round < 3; round++ {
+		var releases []func(bool)
+		for i := 0; i < 10; i++ {
+			r, ok := l.Acquire(PriorityCritical)
+			if ok {
+				releases = append(releases, r)
+			}
+		}
+		for _, r := range releases {
+			r(false)
+		}
+	}
+	assert.Greater(t, l.Limit(), 10)
+	assert.LessOrEqual(t, l.Limit(), 20)
+}
+
+func TestPriorityFromContext(t *testing.T) {
+	assert.Equal(t, PriorityNormal, priorityFrom(context.Background()))
+	assert.Equal(t, PriorityLow, priorityFrom(WithPriority(context.Background(), ParsePriority("LOW"))))
+	assert.Equal(t, PriorityCritical, ParsePriority("critical"))
+	assert.Equal(t, PriorityNormal, ParsePriority("bogus"))
+}
+
+func TestRequestedPriority_CriticalOnlyForServicePrincipals(t *testing.T) {
+	service := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "checkout", Method: auth.MethodAPIKey})
+	customer := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "42", Method: auth.MethodJWT, CustomerID: "42"})
+
+	assert.Equal(t, PriorityCritical, RequestedPriority(service, "critical"))
+	assert.Equal(t, PriorityNormal, RequestedPriority(customer, "critical"))
+	assert.Equal(t, PriorityNormal, RequestedPriority(auth.WithAnonymous(context.Background()), "critical"))
+	assert.Equal(t, PriorityNormal, RequestedPriority(context.Background(), "high"))
+	assert.Equal(t, PriorityLow, RequestedPriority(customer, "low"))
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"order-service/internal/auth"
+	"order-service/internal/domain"
+	"order-service/internal/repository"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// OrderPage is one page of a newest-first listing. NextCursor is empty on
+// the last page.
+type OrderPage struct {
+	Orders     []domain.Order `json:"orders"`
+	NextCursor string         `json:"nextCursor,omitempty"`
+}
+
+// ListCustomerOrders pages through a customer's orders. Customers may only
+// list their own; service accounts and admins may list anyone's.
+func (u *OrderService) ListCustomerOrders(ctx context.Context, customerID, cursor string, limit int) (*OrderPage, error) {
+	if scope, ok := auth.CustomerScope(ctx); ok && scope != customerID {
+		return nil, ErrForbidden
+	}
+	return u.listCustomerOrders(customerID, cursor, limit)
+}
+
+// ListMyOrders pages through the calling customer's orders.
+func (u *OrderService) ListMyOrders(ctx context.Context, cursor string, limit int) (*OrderPage, error) {
+	p, ok := auth.FromContext(ctx)
+	if !ok {
+		return nil, ErrUnauthenticated
+	}
+	if p.CustomerID == "" {
+		return nil, fmt.Errorf("%w: caller is not a customer", ErrForbidden)
+	}
+	return u.listCustomerOrders(p.CustomerID, cursor, limit)
+}
+
+func (u *OrderService) listCustomerOrders(customerID, cursor string, limit int) (*OrderPage, error) {
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+	var after *repository.Cursor
+	if cursor != "" {
+		c, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = c
+	}
+
+	// Ask for one extra row to learn whether another page exists
+	orders, err := u.repo.FindByCustomer(customerID, after, limit+1)
+	if err != nil {
+		return nil, storageError(err)
+	}
+	page := &OrderPage{Orders: orders}
+	if len(orders) > limit {
+		page.Orders = orders[:limit]
+		page.NextCursor = encodeCursor(orders[limit-1])
+	}
+	if page.Orders == nil {
+		page.Orders = []domain.Order{}
+	}
+	return page, nil
+}
+
+// ownedByCaller reports whether a customer-scoped caller may see o.
+func ownedByCaller(ctx context.Context, o *domain.Order) bool {
+	scope, ok := auth.CustomerScope(ctx)
+	return !ok || o.CustomerID == scope
+}
+
+func encodeCursor(o domain.Order) string {
+	raw := strconv.FormatInt(o.CreatedAt.UnixNano(), 10) + ":" + strconv.FormatUint(o.ID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(s string) (*repository.Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	ts, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	nanos, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	orderID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &repository.Cursor{CreatedAt: time.Unix(0, nanos), ID: orderID}, nil
+}
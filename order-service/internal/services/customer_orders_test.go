@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"order-service/internal/auth"
+	"order-service/internal/domain"
+	"order-service/internal/mocks"
+	"order-service/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func customerCtx(id string) context.Context {
+	return auth.WithPrincipal(context.Background(), &auth.Principal{Subject: id, Method: auth.MethodJWT, CustomerID: id})
+}
+
+func TestOrderService_ListCustomerOrders_Pages(t *testing.T) {
+	mockRepo := new(mocks.MockOrderRepository)
+	service := NewOrderService(mockRepo, new(mocks.MockProductClient), new(mocks.MockPublisher))
+
+	t0 := time.Unix(1700000000, 0)
+	orders := []domain.Order{
+		{ID: 3, CustomerID: "42", CreatedAt: t0.Add(2 * time.Second)},
+		{ID: 2, CustomerID: "42", CreatedAt: t0.Add(time.Second)},
+		{ID: 1, CustomerID: "42", CreatedAt: t0},
+	}
+	mockRepo.On("FindByCustomer", "42", (*repository.Cursor)(nil), 3).Return(orders, nil)
+	mockRepo.On("FindByCustomer", "42", &repository.Cursor{CreatedAt: t0.Add(time.Second), ID: 2}, 3).Return(orders[2:], nil)
+
+	page, err := service.ListMyOrders(customerCtx("42"), "", 2)
+	require.NoError(t, err)
+	assert.Len(t, page.Orders, 2)
+	require.NotEmpty(t, page.NextCursor)
+
+	page, err = service.ListCustomerOrders(customerCtx("42"), "42", page.NextCursor, 2)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), page.Orders[0].ID)
+	assert.Empty(t, page.NextCursor, "last page has no cursor")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestOrderService_CustomerIsolation(t *testing.T) {
+	mockRepo := new(mocks.MockOrderRepository)
+	service := NewOrderService(mockRepo, new(mocks.MockProductClient), new(mocks.MockPublisher))
+	mockRepo.On("FindByID", uint64(7)).Return(&domain.Order{ID: 7, CustomerID: "42"}, nil)
+	mockRepo.On("FindByCustomer", "42", mock.Anything, mock.Anything).Return([]domain.Order{}, nil)
+
+	_, err := service.ListCustomerOrders(customerCtx("99"), "42", "", 10)
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	_, err = service.GetOrderById(customerCtx("99"), 7)
+	assert.ErrorIs(t, err, ErrOrderNotFound, "other customers' orders look missing")
+
+	o, err := service.GetOrderById(customerCtx("42"), 7)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(7), o.ID)
+
+	admin := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "ops", Method: auth.MethodJWT, CustomerID: "ops", Roles: []string{auth.RoleAdmin}})
+	_, err = service.ListCustomerOrders(admin, "42", "", 10)
+	assert.NoError(t, err)
+
+	_, err = service.ListMyOrders(context.Background(), "", 10)
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+
+	// Without credentials, on a server where they are optional
+	anon := auth.WithAnonymous(context.Background())
+	_, err = service.ListCustomerOrders(anon, "42", "", 10)
+	assert.ErrorIs(t, err, ErrForbidden)
+	_, err = service.GetOrderById(anon, 7)
+	assert.ErrorIs(t, err, ErrOrderNotFound)
+	_, err = service.ListMyOrders(anon, "", 10)
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+
+	_, err = service.ListCustomerOrders(customerCtx("42"), "42", "not-base64!", 10)
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrorKind classifies service errors so transports can map them to
+// status codes without inspecting messages.
+type ErrorKind int
+
+const (
+	KindInternal ErrorKind = iota
+	KindNotFound
+	KindValidation
+	KindConflict
+	KindUnavailable
+	KindTimeout
+	KindOverloaded
+	KindRateLimited
+	KindUnauthenticated
+	KindForbidden
+	KindPreconditionFailed
+)
+
+// Error is a typed service error. Code is stable and machine-readable;
+// Message is safe to show to clients. Internal causes are attached by
+// wrapping, e.g. fmt.Errorf("%w: %w", ErrStorageUnavailable, err).
+type Error struct {
+	Kind    ErrorKind
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+var (
+	ErrOrderNotFound             = &Error{Kind: KindNotFound, Code: "ORDER_NOT_FOUND", Message: "order not found"}
+	ErrProductNotFound           = &Error{Kind: KindValidation, Code: "PRODUCT_NOT_FOUND", Message: "product not found"}
+	ErrInvalidOrder              = &Error{Kind: KindValidation, Code: "INVALID_ORDER", Message: "invalid order"}
+	ErrOutOfStock                = &Error{Kind: KindConflict, Code: "OUT_OF_STOCK", Message: "product is out of stock"}
+	ErrOrderNotCancellable       = &Error{Kind: KindConflict, Code: "ORDER_NOT_CANCELLABLE", Message: "order cannot be cancelled"}
+	ErrConcurrentUpdate          = &Error{Kind: KindConflict, Code: "CONCURRENT_UPDATE", Message: "order was modified concurrently"}
+	ErrProductServiceUnavailable = &Error{Kind: KindUnavailable, Code: "PRODUCT_SERVICE_UNAVAILABLE", Message: "product service unavailable"}
+	ErrProductServiceTimeout     = &Error{Kind: KindTimeout, Code: "PRODUCT_SERVICE_TIMEOUT", Message: "product service timed out"}
+	ErrStorageUnavailable        = &Error{Kind: KindUnavailable, Code: "STORAGE_UNAVAILABLE", Message: "order storage unavailable"}
+	ErrStorageTimeout            = &Error{Kind: KindTimeout, Code: "STORAGE_TIMEOUT", Message: "order storage timed out"}
+	ErrOverloaded                = &Error{Kind: KindOverloaded, Code: "SERVICE_OVERLOADED", Message: "service overloaded"}
+	ErrInvalidCursor             = &Error{Kind: KindValidation, Code: "INVALID_CURSOR", Message: "invalid pagination cursor"}
+	ErrUnauthenticated           = &Error{Kind: KindUnauthenticated, Code: "UNAUTHENTICATED", Message: "authentication required"}
+	ErrForbidden                 = &Error{Kind: KindForbidden, Code: "FORBIDDEN", Message: "not allowed to access this resource"}
+	ErrVersionMismatch           = &Error{Kind: KindPreconditionFailed, Code: "VERSION_MISMATCH", Message: "order has changed since the version you read"}
+)
+
+// AsError returns the typed error in err's chain, if any.
+func AsError(err error) (*Error, bool) {
+	var e *Error
+	if errors.As(err, &e) {
+		return e, true
+	}
+	return nil, false
+}
+
+// KindOf returns the kind of err, treating bare context errors as
+// timeouts and anything untyped as internal.
+func KindOf(err error) ErrorKind {
+	if e, ok := AsError(err); ok {
+		return e.Kind
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return KindTimeout
+	}
+	return KindInternal
+}
+
+// storageError classifies a repository failure.
+func storageError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", ErrStorageTimeout, err)
+	}
+	return fmt.Errorf("%w: %w", ErrStorageUnavailable, err)
+}
+
+// productError classifies a product client failure.
+func productError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", ErrProductServiceTimeout, err)
+	}
+	return fmt.Errorf("%w: %w", ErrProductServiceUnavailable, err)
+}
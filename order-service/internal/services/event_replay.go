@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"order-service/internal/domain"
+	"order-service/internal/infra"
+	"order-service/internal/infra/messaging"
+	"order-service/internal/repository"
+)
+
+const (
+	// DefaultReplayRate is the publish rate used when a request sets none.
+	DefaultReplayRate = 50.0
+	// replayPageSize is how many orders are read per repository call and
+	// how often progress is reported.
+	replayPageSize = 200
+	// maxReplayFailureStreak aborts a replay once this many publishes in
+	// a row have failed; the broker is most likely down.
+	maxReplayFailureStreak = 10
+	// maxReportedReplayFailures caps the failed ids kept in a report.
+	maxReportedReplayFailures = 100
+)
+
+var (
+	ErrInvalidReplay = &Error{Kind: KindValidation, Code: "INVALID_REPLAY", Message: "invalid replay request"}
+	ErrReplayAborted = &Error{Kind: KindUnavailable, Code: "REPLAY_ABORTED", Message: "replay aborted after repeated publish failures"}
+)
+
+// ReplayRequest selects orders and the events to publish again for each.
+type ReplayRequest struct {
+	Filter repository.ReplayFilter
+	// Events defaults to order.created. order.created is only replayed for
+	// pending orders unless Force is set, and order.cancelled only for
+	// orders that are cancelled.
+	Events []string
+	// Force re-sends order.created for orders past pending. The product
+	// service reserves stock again for every order.created it receives.
+	Force bool
+	// Rate caps publishes per second; zero means DefaultReplayRate.
+	Rate float64
+	// MaxOrders stops the run after this many orders; zero means no cap.
+	MaxOrders int
+	// DryRun selects and counts without publishing.
+	DryRun bool
+}
+
+// ReplayReport is the progress, and in the end the outcome, of a replay.
+// When Complete is false the run can be resumed by setting
+// Filter.FromID to LastOrderID+1.
+type ReplayReport struct {
+	DryRun         bool      `json:"dryRun"`
+	Matched        int       `json:"matched"`
+	Published      int       `json:"published"`
+	Skipped        int       `json:"skipped"`
+	Failed         int       `json:"failed"`
+	FailedOrderIDs []uint64  `json:"failedOrderIds,omitempty"`
+	LastOrderID    uint64    `json:"lastOrderId,omitempty"`
+	Complete       bool      `json:"complete"`
+	StartedAt      time.Time `json:"startedAt"`
+	FinishedAt     time.Time `json:"finishedAt,omitempty"`
+}
+
+// EventReplayer re-publishes the events of existing orders, e.g. after the
+// product service missed them. Consumers see the replayed events with new
+// message ids, so they must tolerate orders they have already processed.
+type EventReplayer struct {
+	repo  repository.OrderRepository
+	pub   messaging.Publisher
+	clock infra.Clock
+}
+
+func NewEventReplayer(repo repository.OrderRepository, pub messaging.Publisher, clock infra.Clock) *EventReplayer {
+	if clock == nil {
+		clock = infra.RealClock()
+	}
+	return &EventReplayer{repo: repo, pub: pub, clock: clock}
+}
+
+// replayEvent builds the event of type typ for o, or nil when o has no
+// such event or it is not safe to send again without force.
+func replayEvent(typ string, o domain.Order, force bool) (domain.Event, error) {
+	switch typ {
+	case domain.EventOrderCreated:
+		if o.Status != domain.StatusPending && !force {
+			return nil, nil
+		}
+		return domain.OrderCreatedEvent{
+			OrderID:    o.ID,
+			ProductId:  o.ProductId,
+			TotalPrice: o.TotalPrice,
+			CreatedAt:  o.CreatedAt,
+		}, nil
+	case domain.EventOrderCancelled:
+		if o.Status != domain.StatusCancelled {
+			return nil, nil
+		}
+		return domain.OrderCancelledEvent{OrderID: o.ID}, nil
+	}
+	return nil, fmt.Errorf("%w: unknown event type %q", ErrInvalidReplay, typ)
+}
+
+func (req *ReplayRequest) validate() error {
+	f := req.Filter
+	if f.FromID > 0 && f.ToID > 0 && f.FromID > f.ToID {
+		return fmt.Errorf("%w: fromId must not exceed toId", ErrInvalidReplay)
+	}
+	if !f.CreatedFrom.IsZero() && !f.CreatedTo.IsZero() && !f.CreatedFrom.Before(f.CreatedTo) {
+		return fmt.Errorf("%w: createdFrom must be before createdTo", ErrInvalidReplay)
+	}
+	for _, s := range f.Statuses {
+		if !s.IsValid() {
+			return fmt.Errorf("%w: unknown status %q", ErrInvalidReplay, s)
+		}
+	}
+	if req.Rate < 0 || req.MaxOrders < 0 {
+		return fmt.Errorf("%w: rate and maxOrders must not be negative", ErrInvalidReplay)
+	}
+	if len(req.Events) == 0 {
+		req.Events = []string{domain.EventOrderCreated}
+	}
+	for _, typ := range req.Events {
+		if _, err := replayEvent(typ, domain.Order{}, true); err != nil {
+			return err
+		}
+	}
+	if req.Rate == 0 {
+		req.Rate = DefaultReplayRate
+	}
+	return nil
+}
+
+// Replay publishes the selected events in order id order, pacing publishes
+// to req.Rate. progress, if not nil, is called with the running report
+// after every page of orders. The report is returned even when Replay
+// stops early because ctx is done or the publisher keeps failing.
+func (r *EventReplayer) Replay(ctx context.Context, req ReplayRequest, progress func(ReplayReport)) (ReplayReport, error) {
+	rep := ReplayReport{DryRun: req.DryRun, StartedAt: r.clock.Now()}
+	if err := req.validate(); err != nil {
+		return rep, err
+	}
+	interval := time.Duration(float64(time.Second) / req.Rate)
+
+	finish := func(err error) (ReplayReport, error) {
+		rep.FinishedAt = r.clock.Now()
+		log.Printf("Event replay finished: matched=%d published=%d skipped=%d failed=%d complete=%t dry_run=%t",
+			rep.Matched, rep.Published, rep.Skipped, rep.Failed, rep.Complete, rep.DryRun)
+		return rep, err
+	}
+
+	streak := 0
+	first := true
+	for {
+		limit := replayPageSize
+		if req.MaxOrders > 0 && req.MaxOrders-rep.Matched < limit {
+			limit = req.MaxOrders - rep.Matched
+		}
+		if limit == 0 {
+			return finish(nil)
+		}
+		orders, err := r.repo.FindForReplay(req.Filter, rep.LastOrderID, limit)
+		if err != nil {
+			return finish(storageError(fmt.Errorf("select orders for replay: %w", err)))
+		}
+
+		for _, o := range orders {
+			for _, typ := range req.Events {
+				evt, _ := replayEvent(typ, o, req.Force)
+				if evt == nil {
+					rep.Skipped++
+					continue
+				}
+				if req.DryRun {
+					continue
+				}
+				if !first {
+					select {
+					case <-ctx.Done():
+						return finish(ctx.Err())
+					case <-r.clock.After(interval):
+					}
+				}
+				first = false
+
+				if err := r.pub.Publish(ctx, evt.EventType(), evt); err != nil {
+					log.Printf("Replay of %s for order %d failed: %v", typ, o.ID, err)
+					rep.Failed++
+					if len(rep.FailedOrderIDs) < maxReportedReplayFailures {
+						rep.FailedOrderIDs = append(rep.FailedOrderIDs, o.ID)
+					}
+					if streak++; streak >= maxReplayFailureStreak {
+						return finish(fmt.Errorf("%w: %w", ErrReplayAborted, err))
+					}
+					continue
+				}
+				streak = 0
+				rep.Published++
+			}
+			rep.Matched++
+			rep.LastOrderID = o.ID
+		}
+
+		if progress != nil {
+			progress(rep)
+		}
+		if len(orders) < limit {
+			rep.Complete = true
+			return finish(nil)
+		}
+		if err := ctx.Err(); err != nil {
+			return finish(err)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"order-service/internal/domain"
+	"order-service/internal/mocks"
+	"order-service/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// waitRecordingClock is a stepClock that remembers every wait requested.
+type waitRecordingClock struct {
+	stepClock
+	mu    sync.Mutex
+	waits []time.Duration
+}
+
+func (c *waitRecordingClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	c.waits = append(c.waits, d)
+	c.mu.Unlock()
+	return c.stepClock.After(d)
+}
+
+func replayOrders(ids ...uint64) []domain.Order {
+	out := make([]domain.Order, len(ids))
+	for i, id := range ids {
+		out[i] = domain.Order{ID: id, ProductId: 7, TotalPrice: 100, Status: domain.StatusPending}
+	}
+	return out
+}
+
+func TestEventReplayer_PublishesAtTheRequestedRate(t *testing.T) {
+	filter := repository.ReplayFilter{ProductID: 7, Statuses: []domain.OrderStatus{domain.StatusPending}}
+	repo := new(mocks.MockOrderRepository)
+	repo.On("FindForReplay", filter, uint64(0), replayPageSize).Return(replayOrders(3, 5, 9), nil)
+	pub, published := capturingPublisher(domain.EventOrderCreated)
+	clock := &waitRecordingClock{}
+
+	var progress []ReplayReport
+	rep, err := NewEventReplayer(repo, pub, clock).Replay(context.Background(), ReplayRequest{Filter: filter, Rate: 4}, func(r ReplayReport) {
+		progress = append(progress, r)
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, rep.Matched)
+	assert.Equal(t, 3, rep.Published)
+	assert.Equal(t, uint64(9), rep.LastOrderID)
+	assert.True(t, rep.Complete)
+	require.Len(t, published(), 3)
+	assert.Equal(t, domain.OrderCreatedEvent{OrderID: 5, ProductId: 7, TotalPrice: 100}, published()[1])
+	assert.Equal(t, []time.Duration{250 * time.Millisecond, 250 * time.Millisecond}, clock.waits)
+	require.Len(t, progress, 1)
+	assert.Equal(t, 3, progress[0].Published)
+}
+
+func TestEventReplayer_DryRunPublishesNothing(t *testing.T) {
+	repo := new(mocks.MockOrderRepository)
+	repo.On("FindForReplay", mock.Anything, uint64(0), replayPageSize).Return(replayOrders(1, 2), nil)
+	pub := new(mocks.MockPublisher)
+	clock := &waitRecordingClock{}
+
+	rep, err := NewEventReplayer(repo, pub, clock).Replay(context.Background(), ReplayRequest{DryRun: true}, nil)
+	require.NoError(t, err)
+
+	assert.True(t, rep.DryRun)
+	assert.Equal(t, 2, rep.Matched)
+	assert.Zero(t, rep.Published)
+	assert.Empty(t, clock.waits)
+	pub.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestEventReplayer_CancelledEventOnlyForCancelledOrders(t *testing.T) {
+	orders := replayOrders(1, 2)
+	orders[1].Status = domain.StatusCancelled
+	repo := new(mocks.MockOrderRepository)
+	repo.On("FindForReplay", mock.Anything, uint64(0), replayPageSize).Return(orders, nil)
+	pub, published := capturingPublisher(domain.EventOrderCancelled)
+
+	rep, err := NewEventReplayer(repo, pub, &stepClock{}).Replay(context.Background(), ReplayRequest{Events: []string{domain.EventOrderCancelled}}, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, rep.Matched)
+	assert.Equal(t, 1, rep.Published)
+	assert.Equal(t, 1, rep.Skipped)
+	assert.Equal(t, []any{domain.OrderCancelledEvent{OrderID: 2}}, published())
+}
+
+func TestEventReplayer_CreatedOnlyForPendingUnlessForced(t *testing.T) {
+	orders := replayOrders(1, 2, 3)
+	orders[1].Status = domain.StatusConfirmed
+	orders[2].Status = domain.StatusPaid
+	repo := new(mocks.MockOrderRepository)
+	repo.On("FindForReplay", mock.Anything, uint64(0), replayPageSize).Return(orders, nil)
+
+	pub, published := capturingPublisher(domain.EventOrderCreated)
+	rep, err := NewEventReplayer(repo, pub, &stepClock{}).Replay(context.Background(), ReplayRequest{}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, rep.Published)
+	assert.Equal(t, 2, rep.Skipped)
+	assert.Equal(t, []any{domain.OrderCreatedEvent{OrderID: 1, ProductId: 7, TotalPrice: 100}}, published())
+
+	pub, published = capturingPublisher(domain.EventOrderCreated)
+	rep, err = NewEventReplayer(repo, pub, &stepClock{}).Replay(context.Background(), ReplayRequest{Force: true}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 3, rep.Published)
+	assert.Len(t, published(), 3)
+}
+
+func TestEventReplayer_MaxOrdersLeavesAResumePoint(t *testing.T) {
+	repo := new(mocks.MockOrderRepository)
+	repo.On("FindForReplay", mock.Anything, uint64(0), 2).Return(replayOrders(4, 6), nil)
+	pub, _ := capturingPublisher(domain.EventOrderCreated)
+
+	rep, err := NewEventReplayer(repo, pub, &stepClock{}).Replay(context.Background(), ReplayRequest{MaxOrders: 2}, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, rep.Matched)
+	assert.False(t, rep.Complete)
+	assert.Equal(t, uint64(6), rep.LastOrderID)
+	repo.AssertNumberOfCalls(t, "FindForReplay", 1)
+}
+
+func TestEventReplayer_AbortsWhenPublisherKeepsFailing(t *testing.T) {
+	ids := make([]uint64, 15)
+	for i := range ids {
+		ids[i] = uint64(i + 1)
+	}
+	repo := new(mocks.MockOrderRepository)
+	repo.On("FindForReplay", mock.Anything, uint64(0), replayPageSize).Return(replayOrders(ids...), nil)
+	pub := new(mocks.MockPublisher)
+	pub.On("Publish", mock.Anything, domain.EventOrderCreated, mock.Anything).Return(errors.New("connection refused"))
+
+	rep, err := NewEventReplayer(repo, pub, &stepClock{}).Replay(context.Background(), ReplayRequest{}, nil)
+	require.ErrorIs(t, err, ErrReplayAborted)
+	assert.Equal(t, KindUnavailable, KindOf(err))
+
+	assert.Equal(t, maxReplayFailureStreak, rep.Failed)
+	assert.Len(t, rep.FailedOrderIDs, maxReplayFailureStreak)
+	assert.Equal(t, uint64(9), rep.LastOrderID, "the order being published when it gave up is replayed on resume")
+	assert.False(t, rep.Complete)
+}
+
+func TestEventReplayer_RejectsInvalidRequests(t *testing.T) {
+	r := NewEventReplayer(new(mocks.MockOrderRepository), new(mocks.MockPublisher), &stepClock{})
+	now := time.Now()
+	for name, req := range map[string]ReplayRequest{
+		"id range":      {Filter: repository.ReplayFilter{FromID: 9, ToID: 3}},
+		"time window":   {Filter: repository.ReplayFilter{CreatedFrom: now, CreatedTo: now.Add(-time.Hour)}},
+		"status":        {Filter: repository.ReplayFilter{Statuses: []domain.OrderStatus{"shipped"}}},
+		"event type":    {Events: []string{"order.shipped"}},
+		"negative rate": {Rate: -1},
+	} {
+		_, err := r.Replay(context.Background(), req, nil)
+		assert.ErrorIs(t, err, ErrInvalidReplay, name)
+	}
+}
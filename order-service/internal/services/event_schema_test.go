@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"order-service/api/events"
+	"order-service/internal/domain"
+	rabbit "order-service/internal/infra/rabbitmq"
+	"order-service/internal/mocks"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// validateEnvelope wraps data the way the RabbitMQ publisher does and checks
+// it against the schema generated for the event.
+func validateEnvelope(t *testing.T, pattern string, data any) error {
+	t.Helper()
+	v, ok := data.(domain.Event)
+	require.True(t, ok, "%s payload %T is not a catalog event", pattern, data)
+
+	name := fmt.Sprintf("%s.v%d.schema.json", v.EventType(), v.SchemaVersion())
+	raw, err := events.Schemas.ReadFile(name)
+	require.NoError(t, err)
+	c := jsonschema.NewCompiler()
+	c.AssertFormat = true
+	require.NoError(t, c.AddResource(name, strings.NewReader(string(raw))))
+	schema, err := c.Compile(name)
+	require.NoError(t, err)
+
+	body, err := json.Marshal(rabbit.NewMessage(pattern, data, time.Now()))
+	require.NoError(t, err)
+	var doc any
+	require.NoError(t, json.Unmarshal(body, &doc))
+	return schema.Validate(doc)
+}
+
+// capturingPublisher records what the service hands to the publisher.
+func capturingPublisher(pattern string) (*mocks.MockPublisher, func() []any) {
+	var mu sync.Mutex
+	var got []any
+	pub := new(mocks.MockPublisher)
+	pub.On("Publish", mock.Anything, pattern, mock.Anything).Run(func(args mock.Arguments) {
+		mu.Lock()
+		got = append(got, args.Get(2))
+		mu.Unlock()
+	}).Return(nil)
+	return pub, func() []any {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]any(nil), got...)
+	}
+}
+
+func TestPublishedEventsMatchSchemas(t *testing.T) {
+	t.Run("order.created", func(t *testing.T) {
+		repo := new(mocks.MockOrderRepository)
+		prod := new(mocks.MockProductClient)
+		prod.On("GetProductById", mock.Anything, uint64(1)).Return(CreateMockProduct(1, "Test Product", 1000, 10), nil)
+		repo.On("Save", mock.AnythingOfType("*domain.Order")).
+			Run(func(args mock.Arguments) { args.Get(0).(*domain.Order).ID = 11 }).Return(nil)
+		pub, published := capturingPublisher(domain.EventOrderCreated)
+
+		_, err := NewOrderService(repo, prod, pub).CreateOrder(context.Background(), 1, 1000)
+		require.NoError(t, err)
+		require.Eventually(t, func() bool { return len(published()) == 1 }, time.Second, 5*time.Millisecond)
+		assert.NoError(t, validateEnvelope(t, domain.EventOrderCreated, published()[0]))
+	})
+
+	t.Run("order.cancelled", func(t *testing.T) {
+		repo := new(mocks.MockOrderRepository)
+		repo.On("FindByID", uint64(12)).Return(&domain.Order{ID: 12, Status: domain.StatusPending, Version: 1}, nil)
+		repo.On("UpdateStatus", uint64(12), domain.StatusPending, domain.StatusCancelled).Return(nil)
+		pub, published := capturingPublisher(domain.EventOrderCancelled)
+
+		_, err := NewOrderService(repo, new(mocks.MockProductClient), pub).CancelOrder(context.Background(), 12)
+		require.NoError(t, err)
+		require.Eventually(t, func() bool { return len(published()) == 1 }, time.Second, 5*time.Millisecond)
+		assert.NoError(t, validateEnvelope(t, domain.EventOrderCancelled, published()[0]))
+	})
+
+	t.Run("order.refunded", func(t *testing.T) {
+		repo := new(mocks.MockOrderRepository)
+		repo.On("FindByID", uint64(13)).Return(&domain.Order{ID: 13, TotalPrice: 1000, Status: domain.StatusConfirmed, Version: 1}, nil)
+		refunds := new(mocks.MockRefundRepository)
+		refunds.On("Reserve", mock.Anything, mock.Anything, uint64(1)).Return(nil)
+		refunds.On("Complete", mock.Anything).Return(nil)
+		pub, published := capturingPublisher(domain.EventOrderRefunded)
+
+		s := NewOrderService(repo, new(mocks.MockProductClient), pub)
+		s.SetRefundRepository(refunds)
+		_, err := s.RefundOrder(context.Background(), 13, 400, "late delivery", 0)
+		require.NoError(t, err)
+		require.Len(t, published(), 1)
+		assert.NoError(t, validateEnvelope(t, domain.EventOrderRefunded, published()[0]))
+	})
+
+	t.Run("saga commands", func(t *testing.T) {
+		for _, cmd := range []domain.Event{
+			domain.StockReleaseCommand{OrderID: 1, ProductId: 2},
+			domain.PaymentChargeCommand{OrderID: 1, Amount: 1000},
+			domain.PaymentRefundCommand{OrderID: 1, Amount: 1000},
+		} {
+			assert.NoError(t, validateEnvelope(t, cmd.EventType(), cmd), cmd.EventType())
+		}
+	})
+}
+
+func TestEventSchemasRejectContractDrift(t *testing.T) {
+	// A renamed field must not slip through as an extra property
+	drifted := struct {
+		domain.OrderCancelledEvent
+		OrderId uint64 `json:"order_id"`
+	}{domain.OrderCancelledEvent{OrderID: 1}, 1}
+	assert.Error(t, validateEnvelope(t, domain.EventOrderCancelled, drifted))
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"order-service/internal/domain"
+	"order-service/internal/infra"
+	"order-service/internal/repository"
+)
+
+type GroupCommitConfig struct {
+	MaxBatch int           // flush as soon as this many orders are waiting
+	Linger   time.Duration // how long the first order waits for company
+}
+
+func DefaultGroupCommitConfig() GroupCommitConfig {
+	return GroupCommitConfig{
+		MaxBatch: 100,
+		Linger:   2 * time.Millisecond,
+	}
+}
+
+type pendingWrite struct {
+	order *domain.Order
+	done  chan error
+	taken bool // set once the write belongs to a flushing batch
+}
+
+// groupCommitter persists orders from concurrent callers through a single
+// SaveBatch transaction. A caller that gives up before its batch is flushed
+// is removed from the batch, so its order is never written.
+type groupCommitter struct {
+	repo  repository.OrderRepository
+	cfg   GroupCommitConfig
+	clock infra.Clock
+
+	mu      sync.Mutex
+	pending []*pendingWrite
+	gen     uint64
+	armed   bool
+
+	batches   int64
+	writes    int64
+	fallbacks int64
+	cancelled int64
+}
+
+func newGroupCommitter(repo repository.OrderRepository, cfg GroupCommitConfig, clock infra.Clock) *groupCommitter {
+	if clock == nil {
+		clock = infra.RealClock()
+	}
+	if cfg.MaxBatch <= 0 {
+		cfg.MaxBatch = 1
+	}
+	return &groupCommitter{repo: repo, cfg: cfg, clock: clock}
+}
+
+// Save queues order for the next batch and waits until it is persisted,
+// returning the error for this order only.
+func (g *groupCommitter) Save(ctx context.Context, order *domain.Order) error {
+	atomic.AddInt64(&g.writes, 1)
+	w := &pendingWrite{order: order, done: make(chan error, 1)}
+
+	g.mu.Lock()
+	g.pending = append(g.pending, w)
+	if len(g.pending) >= g.cfg.MaxBatch {
+		batch := g.takeLocked()
+		g.mu.Unlock()
+		go g.flush(batch)
+	} else {
+		if !g.armed {
+			g.armed = true
+			go g.flushAfterLinger(g.gen)
+		}
+		g.mu.Unlock()
+	}
+
+	select {
+	case err := <-w.done:
+		return err
+	case <-ctx.Done():
+	}
+
+	g.mu.Lock()
+	if !w.taken {
+		for i, p := range g.pending {
+			if p == w {
+				g.pending = append(g.pending[:i], g.pending[i+1:]...)
+				break
+			}
+		}
+		g.mu.Unlock()
+		atomic.AddInt64(&g.cancelled, 1)
+		return ctx.Err()
+	}
+	g.mu.Unlock()
+
+	// Already being written; report the real outcome rather than pretend
+	// the order does not exist.
+	return <-w.done
+}
+
+func (g *groupCommitter) flushAfterLinger(gen uint64) {
+	<-g.clock.After(g.cfg.Linger)
+
+	g.mu.Lock()
+	if g.gen != gen {
+		g.mu.Unlock()
+		return
+	}
+	batch := g.takeLocked()
+	g.mu.Unlock()
+	g.flush(batch)
+}
+
+// takeLocked must be called with mu held.
+func (g *groupCommitter) takeLocked() []*pendingWrite {
+	batch := g.pending
+	for _, w := range batch {
+		w.taken = true
+	}
+	g.pending = nil
+	g.gen++
+	g.armed = false
+	return batch
+}
+
+func (g *groupCommitter) flush(batch []*pendingWrite) {
+	if len(batch) == 0 {
+		return
+	}
+	atomic.AddInt64(&g.batches, 1)
+
+	orders := make([]*domain.Order, len(batch))
+	for i, w := range batch {
+		orders[i] = w.order
+	}
+
+	err := g.repo.SaveBatch(orders)
+	if err == nil || len(batch) == 1 {
+		for _, w := range batch {
+			w.done <- err
+		}
+		return
+	}
+
+	// The transaction rolled back as a whole. Retry each order on its own
+	// so one bad row does not fail every caller in the batch.
+	atomic.AddInt64(&g.fallbacks, 1)
+	for _, w := range batch {
+		w.order.ID = 0
+		w.done <- g.repo.Save(w.order)
+	}
+}
+
+func (g *groupCommitter) GetStats() map[string]interface{} {
+	batches := atomic.LoadInt64(&g.batches)
+	writes := atomic.LoadInt64(&g.writes)
+	avg := float64(0)
+	if batches > 0 {
+		avg = float64(writes-atomic.LoadInt64(&g.cancelled)) / float64(batches)
+	}
+	return map[string]interface{}{
+		"writes":         writes,
+		"batches":        batches,
+		"avg_batch_size": avg,
+		"fallbacks":      atomic.LoadInt64(&g.fallbacks),
+		"cancelled":      atomic.LoadInt64(&g.cancelled),
+	}
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"order-service/internal/domain"
+	"order-service/internal/mocks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func (g *groupCommitter) pendingCount() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.pending)
+}
+
+func TestOrderService_GroupCommit(t *testing.T) {
+	mockRepo := new(mocks.MockOrderRepository)
+	mockProdClient := new(mocks.MockProductClient)
+	mockPublisher := new(mocks.MockPublisher)
+
+	mockProdClient.On("GetProductById", mock.Anything, uint64(1)).Return(CreateMockProduct(1, "Test Product", 1000, 10), nil)
+	mockPublisher.On("Publish", mock.Anything, "order.created", mock.Anything).Return(nil).Maybe()
+
+	var nextID uint64
+	mockRepo.On("SaveBatch", mock.Anything).Run(func(args mock.Arguments) {
+		for _, o := range args.Get(0).([]*domain.Order) {
+			o.ID = atomic.AddUint64(&nextID, 1)
+		}
+	}).Return(nil).Once()
+
+	service := NewOrderService(mockRepo, mockProdClient, mockPublisher)
+	service.EnableGroupCommit(GroupCommitConfig{MaxBatch: 3, Linger: time.Hour})
+
+	ids := make(chan uint64, 3)
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			o, err := service.CreateOrder(context.Background(), 1, int64(1000+i))
+			assert.NoError(t, err)
+			if o != nil {
+				ids <- o.ID
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := map[uint64]bool{}
+	for id := range ids {
+		seen[id] = true
+	}
+	assert.Len(t, seen, 3, "each caller gets its own id")
+	mockRepo.AssertNotCalled(t, "Save", mock.Anything)
+	mockRepo.AssertExpectations(t)
+	assert.Equal(t, int64(1), service.GetServiceStats()["group_commit"].(map[string]interface{})["batches"])
+}
+
+func TestGroupCommitter_FallsBackToSingleSaves(t *testing.T) {
+	mockRepo := new(mocks.MockOrderRepository)
+	mockRepo.On("SaveBatch", mock.Anything).Return(errors.New("duplicate entry"))
+	mockRepo.On("Save", mock.MatchedBy(func(o *domain.Order) bool { return o.TotalPrice == 1 })).
+		Run(func(args mock.Arguments) { args.Get(0).(*domain.Order).ID = 11 }).Return(nil)
+	mockRepo.On("Save", mock.MatchedBy(func(o *domain.Order) bool { return o.TotalPrice == 2 })).
+		Return(errors.New("duplicate entry"))
+
+	g := newGroupCommitter(mockRepo, GroupCommitConfig{MaxBatch: 2, Linger: time.Hour}, nil)
+
+	good := &domain.Order{TotalPrice: 1}
+	bad := &domain.Order{TotalPrice: 2}
+	errs := make([]error, 2)
+	var wg sync.WaitGroup
+	for i, o := range []*domain.Order{good, bad} {
+		wg.Add(1)
+		go func(i int, o *domain.Order) {
+			defer wg.Done()
+			errs[i] = g.Save(context.Background(), o)
+		}(i, o)
+	}
+	wg.Wait()
+
+	assert.NoError(t, errs[0])
+	assert.Equal(t, uint64(11), good.ID)
+	assert.Error(t, errs[1])
+	assert.Equal(t, int64(1), g.GetStats()["fallbacks"])
+}
+
+func TestGroupCommitter_CancelledCallerIsNotWritten(t *testing.T) {
+	mockRepo := new(mocks.MockOrderRepository)
+	g := newGroupCommitter(mockRepo, GroupCommitConfig{MaxBatch: 10, Linger: time.Hour}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- g.Save(ctx, &domain.Order{}) }()
+
+	for g.pendingCount() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+
+	err := <-done
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 0, g.pendingCount())
+	mockRepo.AssertNotCalled(t, "SaveBatch", mock.Anything)
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"order-service/internal/infra"
+	"order-service/internal/repository"
+)
+
+// InboxPrunerConfig controls how long consumed message ids are remembered.
+// Retention must comfortably exceed the longest time the broker may hold
+// a message before redelivering it.
+type InboxPrunerConfig struct {
+	Retention time.Duration
+	Interval  time.Duration
+	BatchSize int
+}
+
+func DefaultInboxPrunerConfig() InboxPrunerConfig {
+	return InboxPrunerConfig{
+		Retention: 7 * 24 * time.Hour,
+		Interval:  time.Hour,
+		BatchSize: 1000,
+	}
+}
+
+// InboxPruner periodically deletes inbox rows older than the retention.
+type InboxPruner struct {
+	repo  repository.InboxRepository
+	cfg   InboxPrunerConfig
+	clock infra.Clock
+
+	mu      sync.Mutex
+	pruned  int64
+	lastRun time.Time
+	lastErr error
+}
+
+func NewInboxPruner(repo repository.InboxRepository, cfg InboxPrunerConfig, clock infra.Clock) *InboxPruner {
+	if clock == nil {
+		clock = infra.RealClock()
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultInboxPrunerConfig().BatchSize
+	}
+	return &InboxPruner{repo: repo, cfg: cfg, clock: clock}
+}
+
+// Run prunes once per interval until ctx is done.
+func (p *InboxPruner) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.clock.After(p.cfg.Interval):
+		}
+		if n, err := p.PruneOnce(); err != nil {
+			log.Printf("Inbox pruning failed after %d rows: %v", n, err)
+		} else if n > 0 {
+			log.Printf("Pruned %d inbox messages", n)
+		}
+	}
+}
+
+// PruneOnce deletes everything past the retention in batches and returns
+// the number of rows removed.
+func (p *InboxPruner) PruneOnce() (int64, error) {
+	cutoff := p.clock.Now().Add(-p.cfg.Retention)
+	var total int64
+	var err error
+	for {
+		var n int64
+		n, err = p.repo.Prune(cutoff, p.cfg.BatchSize)
+		total += n
+		if err != nil || n < int64(p.cfg.BatchSize) {
+			break
+		}
+	}
+
+	p.mu.Lock()
+	p.pruned += total
+	p.lastRun = p.clock.Now()
+	p.lastErr = err
+	p.mu.Unlock()
+	return total, err
+}
+
+func (p *InboxPruner) GetStats() map[string]interface{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := map[string]interface{}{
+		"retention": p.cfg.Retention.String(),
+		"pruned":    p.pruned,
+	}
+	if !p.lastRun.IsZero() {
+		stats["last_run"] = p.lastRun
+	}
+	if p.lastErr != nil {
+		stats["last_error"] = p.lastErr.Error()
+	}
+	return stats
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"order-service/internal/mocks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInboxPruner_PrunesInBatchesPastRetention(t *testing.T) {
+	clock := &stepClock{now: time.Date(2025, 9, 20, 12, 0, 0, 0, time.UTC)}
+	cutoff := clock.now.Add(-48 * time.Hour)
+
+	repo := new(mocks.MockInboxRepository)
+	repo.On("Prune", cutoff, 2).Return(int64(2), nil).Twice()
+	repo.On("Prune", cutoff, 2).Return(int64(1), nil).Once()
+
+	p := NewInboxPruner(repo, InboxPrunerConfig{Retention: 48 * time.Hour, Interval: time.Hour, BatchSize: 2}, clock)
+	n, err := p.PruneOnce()
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), n)
+	repo.AssertExpectations(t)
+	assert.Equal(t, int64(5), p.GetStats()["pruned"])
+}
+
+func TestInboxPruner_StopsOnError(t *testing.T) {
+	clock := &stepClock{now: time.Date(2025, 9, 20, 12, 0, 0, 0, time.UTC)}
+	repo := new(mocks.MockInboxRepository)
+	repo.On("Prune", clock.now.Add(-time.Hour), 10).Return(int64(0), errors.New("lock wait timeout")).Once()
+
+	p := NewInboxPruner(repo, InboxPrunerConfig{Retention: time.Hour, Interval: time.Hour, BatchSize: 10}, clock)
+	_, err := p.PruneOnce()
+	assert.Error(t, err)
+	assert.Equal(t, "lock wait timeout", p.GetStats()["last_error"])
+}
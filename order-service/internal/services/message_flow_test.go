@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"order-service/internal/domain"
+	"order-service/internal/infra"
+	"order-service/internal/infra/messaging"
+	"order-service/internal/mocks"
+	"order-service/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryOrderRepo keeps orders in a map so a status change made by an event
+// handler is visible to later reads. Methods the flow does not use panic
+// through the nil embedded interface.
+type memoryOrderRepo struct {
+	repository.OrderRepository
+
+	mu      sync.Mutex
+	nextID  uint64
+	orders  map[uint64]domain.Order
+	history []domain.OrderStatusHistory
+	inbox   map[string]bool
+}
+
+func newMemoryOrderRepo() *memoryOrderRepo {
+	return &memoryOrderRepo{orders: map[uint64]domain.Order{}, inbox: map[string]bool{}}
+}
+
+func (r *memoryOrderRepo) Save(o *domain.Order) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	o.ID, o.Version = r.nextID, 1
+	r.orders[o.ID] = *o
+	return nil
+}
+
+func (r *memoryOrderRepo) SaveBatch(orders []*domain.Order) error {
+	for _, o := range orders {
+		r.Save(o)
+	}
+	return nil
+}
+
+func (r *memoryOrderRepo) FindByID(id uint64) (*domain.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	o, ok := r.orders[id]
+	if !ok {
+		return nil, nil
+	}
+	return &o, nil
+}
+
+func (r *memoryOrderRepo) UpdateStatus(change *domain.OrderStatusHistory, version uint64, msg *domain.InboxMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if msg != nil {
+		if r.inbox[msg.MessageID] {
+			return repository.ErrDuplicateMessage
+		}
+		r.inbox[msg.MessageID] = true
+	}
+	o := r.orders[change.OrderID]
+	if o.Version != version {
+		return &repository.VersionConflictError{OrderID: o.ID, Expected: version}
+	}
+	o.Status = change.ToStatus
+	o.Version++
+	r.orders[o.ID] = o
+	r.history = append(r.history, *change)
+	return nil
+}
+
+// fakeProductService answers order.created the way the product service
+// does: order.qty_confirmed when the product has stock, order.qty_failed
+// otherwise.
+func fakeProductService(b messaging.Broker, stock map[uint64]int64) {
+	b.Subscribe(domain.EventOrderCreated, messaging.AckOnSuccess(func(ctx context.Context, data json.RawMessage) error {
+		var evt domain.OrderCreatedEvent
+		if err := json.Unmarshal(data, &evt); err != nil {
+			return messaging.Permanent(err)
+		}
+		if stock[evt.ProductId] > 0 {
+			return b.Publish(ctx, "order.qty_confirmed", domain.StockConfirmedEvent{OrderID: evt.OrderID})
+		}
+		return b.Publish(ctx, "order.qty_failed", domain.StockFailedEvent{OrderID: evt.OrderID, Reason: "out of stock"})
+	}))
+}
+
+func newMessageFlowService(t *testing.T) (*OrderService, *memoryOrderRepo, *messaging.MemoryBroker) {
+	t.Helper()
+	broker := messaging.NewMemoryBroker(messaging.DefaultMemoryBrokerConfig())
+	t.Cleanup(broker.Close)
+
+	products := new(mocks.MockProductClient)
+	products.On("GetProductById", mock.Anything, uint64(1)).Return(&infra.ProductInfo{ID: 1, Qty: 5}, nil)
+	products.On("GetProductById", mock.Anything, uint64(2)).Return(&infra.ProductInfo{ID: 2, Qty: 5}, nil)
+
+	repo := newMemoryOrderRepo()
+	s := NewOrderService(repo, products, broker)
+	s.RegisterEventHandlers(broker)
+	fakeProductService(broker, map[uint64]int64{1: 3})
+	return s, repo, broker
+}
+
+func TestMessageFlow_CreateThenConfirm(t *testing.T) {
+	s, repo, broker := newMessageFlowService(t)
+
+	var mu sync.Mutex
+	var seen []string
+	broker.Subscribe("order.#", func(_ context.Context, d messaging.Delivery) {
+		mu.Lock()
+		seen = append(seen, d.Message().Topic)
+		mu.Unlock()
+		d.Ack()
+	})
+
+	order, err := s.CreateOrder(context.Background(), 1, 1000)
+	require.NoError(t, err)
+
+	got, err := s.WaitForFinalStatus(context.Background(), order.ID, 2*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, domain.StatusConfirmed, got.Status)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	require.NoError(t, broker.WaitIdle(ctx))
+
+	mu.Lock()
+	assert.Equal(t, []string{"order.created", "order.qty_confirmed"}, seen)
+	mu.Unlock()
+
+	require.Len(t, repo.history, 1)
+	assert.Equal(t, "event:order.qty_confirmed", repo.history[0].Actor)
+	assert.NotEmpty(t, repo.history[0].SourceEventID)
+	assert.Empty(t, broker.DeadLetters())
+}
+
+func TestMessageFlow_CreateThenFail(t *testing.T) {
+	s, repo, _ := newMessageFlowService(t)
+
+	order, err := s.CreateOrder(context.Background(), 2, 1000)
+	require.NoError(t, err)
+
+	got, err := s.WaitForFinalStatus(context.Background(), order.ID, 2*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, domain.StatusFailed, got.Status)
+	require.Len(t, repo.history, 1)
+	assert.Equal(t, "out of stock", repo.history[0].Reason)
+}
+
+func TestMessageFlow_UndecodableReplyIsDeadLettered(t *testing.T) {
+	_, _, broker := newMessageFlowService(t)
+
+	require.NoError(t, broker.Publish(context.Background(), "order.qty_confirmed", "not an event"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	require.NoError(t, broker.WaitIdle(ctx))
+
+	dead := broker.DeadLetters()
+	require.Len(t, dead, 1)
+	assert.Equal(t, "order.qty_confirmed", dead[0].Message.Topic)
+	assert.Equal(t, 1, dead[0].Attempts)
+}
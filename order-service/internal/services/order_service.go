@@ -6,35 +6,40 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"order-service/internal/auth"
 	"order-service/internal/domain"
 	"order-service/internal/infra"
-	rabbit "order-service/internal/infra/rabbitmq"
+	"order-service/internal/infra/messaging"
 	"order-service/internal/repository"
-	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"golang.org/x/sync/singleflight"
 )
 
-var ErrOrderNotFound = errors.New("order not found")
-
 type OrderService struct {
     repo           repository.OrderRepository
     prodClient     infra.ProductClientInterface
-    publisher      rabbit.PublisherInterface
+    publisher      messaging.Publisher
     redisClient    *redis.Client
+    broadcaster    *StatusBroadcaster
     
     // Performance optimizations
     sf             singleflight.Group
     localCache     *sync.Map
     
-    // Connection pools
-    dbWorkers      chan struct{}
-    eventWorkers   chan struct{}
+    // Admission control
+    limiter        *AdaptiveLimiter
+    committer      *groupCommitter // nil unless group commit is enabled
+    sagas          *SagaOrchestrator // nil unless fulfilment runs as a saga
+    payments       infra.PaymentProvider // nil when orders are not paid for here
+    refunds        repository.RefundRepository // nil when refunds are not stored
+    webhooks       *WebhookService // nil when partners are not notified
     
     stats          *ServiceStats
+    versionRetries atomic.Int64 // status updates retried after a version conflict
 }
 
 type ServiceStats struct {
@@ -84,7 +89,7 @@ func (s *ServiceStats) GetStats() (int64, int64, int64, int64, int64) {
 }
 
 type cachedProduct struct {
-    product   interface{}
+    product   *infra.ProductInfo
     expiresAt time.Time
 }
 
@@ -92,16 +97,14 @@ func (cp *cachedProduct) isExpired() bool {
     return time.Now().After(cp.expiresAt)
 }
 
-func NewOrderService(r repository.OrderRepository, p infra.ProductClientInterface, pub rabbit.PublisherInterface) *OrderService {
-    numCPU := runtime.NumCPU()
-    
+func NewOrderService(r repository.OrderRepository, p infra.ProductClientInterface, pub messaging.Publisher) *OrderService {
     service := &OrderService{
         repo:         r,
         prodClient:   p,
         publisher:    pub,
+        broadcaster:  NewStatusBroadcaster(),
         localCache:   &sync.Map{},
-        dbWorkers:    make(chan struct{}, numCPU*20),  // Limit concurrent DB operations
-        eventWorkers: make(chan struct{}, numCPU*30),  // Separate pool for events
+        limiter:      NewAdaptiveLimiter(DefaultLimiterConfig(), nil),
         stats:        &ServiceStats{},
     }
     
@@ -113,10 +116,92 @@ func (u *OrderService) SetRedisClient(client *redis.Client) {
     u.redisClient = client
 }
 
-// BALANCED APPROACH: Fast response + reliable data
+// SetConcurrencyLimiter replaces the limiter guarding order creation.
+func (u *OrderService) SetConcurrencyLimiter(l *AdaptiveLimiter) {
+    u.limiter = l
+}
+
+// EnableGroupCommit makes CreateOrder persist orders through SaveBatch,
+// grouping concurrent callers into one transaction.
+func (u *OrderService) EnableGroupCommit(cfg GroupCommitConfig) {
+    u.committer = newGroupCommitter(u.repo, cfg, nil)
+}
+
+// SetPaymentProvider makes orders pay through p: the price is authorized
+// before the order is saved and captured once stock is reserved, which
+// moves the order to paid instead of confirmed. Call it before creating a
+// SagaOrchestrator.
+func (u *OrderService) SetPaymentProvider(p infra.PaymentProvider) {
+    u.payments = p
+}
+
+// SetRefundRepository enables RefundOrder and ListRefunds.
+func (u *OrderService) SetRefundRepository(r repository.RefundRepository) {
+    u.refunds = r
+}
+
+// SetWebhookService notifies partner webhooks of every stored status
+// change.
+func (u *OrderService) SetWebhookService(w *WebhookService) {
+    u.webhooks = w
+}
+
+// SetSagaOrchestrator makes CreateOrder start a fulfilment saga instead of
+// publishing order.created itself. Replies must then be wired with
+// o.RegisterHandlers rather than RegisterEventHandlers.
+func (u *OrderService) SetSagaOrchestrator(o *SagaOrchestrator) {
+    u.sagas = o
+}
+
+// CreateOrder admits the request through the adaptive limiter before doing
+// any work, so an overloaded service sheds low-priority traffic early
+// instead of letting every request time out.
 func (u *OrderService) CreateOrder(ctx context.Context, productId uint64, totalPrice int64) (*domain.Order, error) {
-    start := time.Now()
     u.stats.IncrementTotalRequests()
+
+    // An order whose event cannot be queued would never be confirmed, so
+    // shed load while the publisher is backed up
+    if bp, ok := u.publisher.(messaging.Backpressure); ok && bp.Saturated() {
+        u.stats.IncrementFailedOrders()
+        return nil, fmt.Errorf("%w: event publishing backlog", ErrOverloaded)
+    }
+
+    release, ok := u.limiter.Acquire(priorityFrom(ctx))
+    if !ok {
+        u.stats.IncrementFailedOrders()
+        return nil, fmt.Errorf("%w: concurrency limit reached", ErrOverloaded)
+    }
+
+    order, err := u.createOrder(ctx, productId, totalPrice)
+    release(isCongestion(err))
+    return order, err
+}
+
+// isCongestion reports whether err indicates the service or a dependency
+// is saturated, as opposed to a bad request.
+func isCongestion(err error) bool {
+    if err == nil {
+        return false
+    }
+    switch KindOf(err) {
+    case KindTimeout, KindUnavailable, KindOverloaded:
+        return true
+    }
+    return false
+}
+
+// BALANCED APPROACH: Fast response + reliable data
+func (u *OrderService) createOrder(ctx context.Context, productId uint64, totalPrice int64) (*domain.Order, error) {
+    start := time.Now()
+
+    if productId == 0 {
+        u.stats.IncrementFailedOrders()
+        return nil, fmt.Errorf("%w: productId is required", ErrInvalidOrder)
+    }
+    if totalPrice < 0 {
+        u.stats.IncrementFailedOrders()
+        return nil, fmt.Errorf("%w: totalPrice must not be negative", ErrInvalidOrder)
+    }
     
     // FAST PATH: Parallel product validation
     productChan := make(chan interface{}, 1)
@@ -135,7 +220,11 @@ func (u *OrderService) CreateOrder(ctx context.Context, productId uint64, totalP
             return
         }
         if prod == nil {
-            productErrChan <- errors.New("product not found")
+            productErrChan <- ErrProductNotFound
+            return
+        }
+        if prod.Qty <= 0 {
+            productErrChan <- ErrOutOfStock
             return
         }
         productChan <- prod
@@ -149,6 +238,10 @@ func (u *OrderService) CreateOrder(ctx context.Context, productId uint64, totalP
         Status:     domain.StatusPending,
         CreatedAt:  time.Now(),
     }
+    if p, ok := auth.FromContext(ctx); ok {
+        order.CreatedBy = p.ID()
+        order.CustomerID = p.CustomerID
+    }
     
     // Wait for product validation with timeout
     select {
@@ -161,43 +254,46 @@ func (u *OrderService) CreateOrder(ctx context.Context, productId uint64, totalP
         }
     case <-time.After(200 * time.Millisecond):
         u.stats.IncrementFailedOrders()
-        return nil, errors.New("product validation timeout")
+        return nil, fmt.Errorf("%w: product validation timeout", ErrProductServiceTimeout)
     }
     
-    // CRITICAL: Save to database with connection pooling
-    select {
-    case u.dbWorkers <- struct{}{}:
-        defer func() { <-u.dbWorkers }()
-        
-        if err := u.repo.Save(order); err != nil {
-            u.stats.IncrementFailedOrders()
-            return nil, fmt.Errorf("failed to save order: %w", err)
-        }
-        
-        if order.ID == 0 {
-            u.stats.IncrementFailedOrders()
-            return nil, errors.New("order saved but ID not assigned")
-        }
-        
-    case <-time.After(100 * time.Millisecond):
+    // Hold the money before anyone is told about the order
+    if err := u.authorizePayment(ctx, order); err != nil {
         u.stats.IncrementFailedOrders()
-        return nil, errors.New("database connection timeout")
+        return nil, err
     }
     
-    u.stats.IncrementSuccessfulOrders()
+    // CRITICAL: Save to database
+    if err := u.saveOrder(ctx, order); err != nil {
+        u.stats.IncrementFailedOrders()
+        u.releasePaymentOrLog(context.Background(), order)
+        if errors.Is(err, context.Canceled) {
+            return nil, err
+        }
+        return nil, storageError(err)
+    }
     
-    // Async event publishing with separate worker pool
-    select {
-    case u.eventWorkers <- struct{}{}:
-        go func() {
-            defer func() { <-u.eventWorkers }()
-            u.publishOrderCreatedEvent(context.Background(), order)
-        }()
-    default:
-        // Event worker pool full, skip event (or log warning)
-        log.Printf("Event worker pool full, skipping event for order %d", order.ID)
+    if order.ID == 0 {
+        u.stats.IncrementFailedOrders()
+        return nil, errors.New("order saved but ID not assigned")
     }
     
+    // order.created is the saga's first command, or goes straight to the
+    // product service. Without it nothing would ever move the order on, so
+    // it fails right away
+    if u.sagas != nil {
+        if err := u.sagas.Start(context.Background(), order); err != nil {
+            log.Printf("Failed to start saga for order %d: %v", order.ID, err)
+            u.abandonOrder(order, "saga could not be started")
+            return nil, err
+        }
+    } else if err := u.publishOrderCreatedEvent(context.Background(), order); err != nil {
+        log.Printf("Failed to publish event for order %d: %v", order.ID, err)
+        u.abandonOrder(order, "order.created could not be published")
+        return nil, fmt.Errorf("%w: %w", ErrOverloaded, err)
+    }
+    u.stats.IncrementSuccessfulOrders()
+    
     // Log response time
     elapsed := time.Since(start)
     if elapsed > 500*time.Millisecond {
@@ -207,10 +303,28 @@ func (u *OrderService) CreateOrder(ctx context.Context, productId uint64, totalP
     return order, nil
 }
 
-// FAST CACHE: Optimized for speed
+// abandonOrder fails a saved order that never got going and releases its
+// payment.
+func (u *OrderService) abandonOrder(order *domain.Order, reason string) {
+    u.stats.IncrementFailedOrders()
+    if _, err := u.transition(context.Background(), order.ID, domain.StatusPending, domain.StatusFailed, reason); err != nil {
+        log.Printf("Failed to fail abandoned order %d: %v", order.ID, err)
+    }
+    u.releasePaymentOrLog(context.Background(), order)
+}
+
+func (u *OrderService) saveOrder(ctx context.Context, order *domain.Order) error {
+    if u.committer != nil {
+        return u.committer.Save(ctx, order)
+    }
+    return u.repo.Save(order)
+}
+
+// FAST CACHE: Optimized for speed. Only products in stock count as valid;
+// out of stock ones are rejected by the slower path, which sees them too.
 func (u *OrderService) isProductValidCached(productId uint64) bool {
     if val, ok := u.localCache.Load(productId); ok {
-        if cached, ok := val.(*cachedProduct); ok && !cached.isExpired() {
+        if cached, ok := val.(*cachedProduct); ok && !cached.isExpired() && cached.product.Qty > 0 {
             u.stats.IncrementCacheHits()
             return true
         }
@@ -219,7 +333,9 @@ func (u *OrderService) isProductValidCached(productId uint64) bool {
     return false
 }
 
-func (u *OrderService) getProductWithFastCache(ctx context.Context, productId uint64) (interface{}, error) {
+// getProductWithFastCache returns the product from the local cache, Redis
+// or the product service, in that order, or nil if it does not exist.
+func (u *OrderService) getProductWithFastCache(ctx context.Context, productId uint64) (*infra.ProductInfo, error) {
     cacheKey := fmt.Sprintf("product:%d", productId)
     
     // Use singleflight to prevent thundering herd
@@ -238,14 +354,14 @@ func (u *OrderService) getProductWithFastCache(ctx context.Context, productId ui
             
             cached, err := u.redisClient.Get(ctx, cacheKey).Result()
             if err == nil {
-                var prod interface{}
+                var prod infra.ProductInfo
                 if err := json.Unmarshal([]byte(cached), &prod); err == nil {
                     // Update local cache
                     u.localCache.Store(productId, &cachedProduct{
-                        product:   prod,
+                        product:   &prod,
                         expiresAt: time.Now().Add(30 * time.Second),
                     })
-                    return prod, nil
+                    return &prod, nil
                 }
             }
         }
@@ -256,49 +372,53 @@ func (u *OrderService) getProductWithFastCache(ctx context.Context, productId ui
         
         prod, err := u.prodClient.GetProductById(ctx, productId)
         if err != nil {
-            return nil, fmt.Errorf("product service error: %w", err)
+            return nil, productError(err)
+        }
+        if prod == nil {
+            // Return an untyped nil so the result below reads as "not found"
+            return nil, nil
         }
 
-        if prod != nil {
-            // Cache immediately (synchronous for consistency)
-            u.localCache.Store(productId, &cachedProduct{
-                product:   prod,
-                expiresAt: time.Now().Add(30 * time.Second),
-            })
-            
-            // Redis cache async
-            if u.redisClient != nil {
-                go func() {
-                    if data, err := json.Marshal(prod); err == nil {
-                        ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
-                        defer cancel()
-                        u.redisClient.Set(ctx, cacheKey, data, 5*time.Minute)
-                    }
-                }()
-            }
+        // Cache immediately (synchronous for consistency)
+        u.localCache.Store(productId, &cachedProduct{
+            product:   prod,
+            expiresAt: time.Now().Add(30 * time.Second),
+        })
+        
+        // Redis cache async
+        if u.redisClient != nil {
+            go func() {
+                if data, err := json.Marshal(prod); err == nil {
+                    ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+                    defer cancel()
+                    u.redisClient.Set(ctx, cacheKey, data, 5*time.Minute)
+                }
+            }()
         }
 
         return prod, nil
     })
 
-    return result, err
+    prod, _ := result.(*infra.ProductInfo)
+    return prod, err
 }
 
-func (u *OrderService) publishOrderCreatedEvent(ctx context.Context, order *domain.Order) {
-    evt := map[string]any{
-        "orderId":    order.ID,
-        "productId":  order.ProductId,
-        "totalPrice": order.TotalPrice,
-        "createdAt":  order.CreatedAt,
+// publishOrderCreatedEvent hands order.created to the publisher, which
+// usually queues it and sends it in the background. It fails when the
+// queue is full.
+func (u *OrderService) publishOrderCreatedEvent(ctx context.Context, order *domain.Order) error {
+    evt := domain.OrderCreatedEvent{
+        OrderID:    order.ID,
+        ProductId:  order.ProductId,
+        TotalPrice: order.TotalPrice,
+        CreatedAt:  order.CreatedAt,
     }
 
-    // Single attempt with timeout for performance
+    // Bounded in case the publisher sends synchronously
     ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
     defer cancel()
     
-    if err := u.publisher.Publish(ctx, "order.created", evt); err != nil {
-        log.Printf("Failed to publish event for order %d: %v", order.ID, err)
-    }
+    return u.publisher.Publish(ctx, evt.EventType(), evt)
 }
 
 func (u *OrderService) logStats() {
@@ -315,10 +435,9 @@ func (u *OrderService) logStats() {
                 hitRate = float64(hits) / float64(hits+misses) * 100
             }
             
-            log.Printf("OrderService: Total=%d, Success=%.1f%%, Failed=%d, Cache=%.1f%%, DBPool=%d/%d, EventPool=%d/%d",
+            log.Printf("OrderService: Total=%d, Success=%.1f%%, Failed=%d, Cache=%.1f%%, Limit=%d",
                 total, successRate, failed, hitRate, 
-                len(u.dbWorkers), cap(u.dbWorkers),
-                len(u.eventWorkers), cap(u.eventWorkers))
+                u.limiter.Limit())
         }
     }
 }
@@ -329,10 +448,12 @@ func (u *OrderService) GetOrderById(ctx context.Context, id uint64) (*domain.Ord
     
     o, err := u.repo.FindByID(id)
     if err != nil {
-        return nil, err
+        return nil, storageError(err)
     }
     
-    if o == nil {
+    // Another customer's order is reported as missing, not forbidden,
+    // so ids cannot be probed
+    if o == nil || !ownedByCaller(ctx, o) {
         return nil, ErrOrderNotFound
     }
     return o, nil
@@ -344,42 +465,103 @@ func (u *OrderService) GetOrderByProductId(ctx context.Context, id uint64) ([]do
     
     o, err := u.repo.FindByProductId(id)
     if err != nil {
-        return nil, err
+        return nil, storageError(err)
     }
     
-    if o == nil {
+    if _, scoped := auth.CustomerScope(ctx); scoped {
+        own := o[:0:0]
+        for i := range o {
+            if ownedByCaller(ctx, &o[i]) {
+                own = append(own, o[i])
+            }
+        }
+        o = own
+    }
+    if len(o) == 0 {
         return nil, ErrOrderNotFound
     }
     return o, nil
 }
 
-func (u *OrderService) WarmupProductCache(ctx context.Context, productIds []uint64) error {
-    if u.redisClient == nil {
-        return nil
+// CancelOrder cancels an order that is still pending.
+func (u *OrderService) CancelOrder(ctx context.Context, id uint64) (*domain.Order, error) {
+    o, err := u.GetOrderById(ctx, id)
+    if err != nil {
+        return nil, err
+    }
+    if o.Status != domain.StatusPending {
+        return nil, fmt.Errorf("%w: order is %s", ErrOrderNotCancellable, o.Status)
     }
 
-    // Parallel warmup with limited concurrency
-    sem := make(chan struct{}, 10)
-    var wg sync.WaitGroup
-    
-    for _, id := range productIds {
-        wg.Add(1)
-        go func(productId uint64) {
-            defer wg.Done()
-            sem <- struct{}{}
-            defer func() { <-sem }()
-            
-            ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-            defer cancel()
-            
-            if _, err := u.getProductWithFastCache(ctx, productId); err != nil {
-                log.Printf("Cache warmup failed for product %d: %v", productId, err)
+    o, err = u.transition(ctx, id, domain.StatusPending, domain.StatusCancelled, "cancelled by client")
+    if err != nil {
+        return nil, err
+    }
+    if o == nil {
+        // Status moved between the read and the update
+        return nil, ErrOrderNotCancellable
+    }
+
+    evt := domain.OrderCancelledEvent{OrderID: o.ID}
+    if err := u.publisher.Publish(context.Background(), evt.EventType(), evt); err != nil {
+        log.Printf("Failed to publish cancel event for order %d: %v", o.ID, err)
+    }
+    u.releasePaymentOrLog(context.Background(), o)
+
+    return o, nil
+}
+
+// WarmupProductCache loads the given products through the batch endpoint
+// and stores them in the local and Redis caches. It returns how many were
+// cached; ids the product service does not know are skipped.
+func (u *OrderService) WarmupProductCache(ctx context.Context, productIds []uint64) (int, error) {
+    const chunkSize = 100
+
+    cached := 0
+    for start := 0; start < len(productIds); start += chunkSize {
+        end := start + chunkSize
+        if end > len(productIds) {
+            end = len(productIds)
+        }
+
+        products, err := u.prodClient.GetProductsByIds(ctx, productIds[start:end])
+        if err != nil {
+            return cached, fmt.Errorf("warmup batch lookup: %w", err)
+        }
+
+        for id, prod := range products {
+            u.localCache.Store(id, &cachedProduct{
+                product:   prod,
+                expiresAt: time.Now().Add(30 * time.Second),
+            })
+            cached++
+
+            if u.redisClient == nil {
+                continue
+            }
+            data, err := json.Marshal(prod)
+            if err != nil {
+                continue
             }
-        }(id)
+            if err := u.redisClient.Set(ctx, fmt.Sprintf("product:%d", id), data, 5*time.Minute).Err(); err != nil {
+                log.Printf("Cache warmup failed for product %d: %v", id, err)
+            }
+        }
     }
-    
-    wg.Wait()
-    return nil
+    return cached, nil
+}
+
+// WarmupPopularProducts warms the cache with the products that appear in
+// the most orders and returns how many were cached.
+func (u *OrderService) WarmupPopularProducts(ctx context.Context, limit int) (int, error) {
+    ids, err := u.repo.FindMostOrderedProductIds(limit)
+    if err != nil {
+        return 0, fmt.Errorf("load popular products: %w", err)
+    }
+    if len(ids) == 0 {
+        return 0, nil
+    }
+    return u.WarmupProductCache(ctx, ids)
 }
 
 func (u *OrderService) GetServiceStats() map[string]interface{} {
@@ -395,13 +577,17 @@ func (u *OrderService) GetServiceStats() map[string]interface{} {
         hitRate = float64(hits) / float64(hits+misses) * 100
     }
     
-    return map[string]interface{}{
+    stats := map[string]interface{}{
         "total_requests":     total,
         "successful_orders":  success,
         "failed_orders":      failed,
         "success_rate":       successRate,
         "cache_hit_rate":     hitRate,
-        "db_pool_usage":      float64(len(u.dbWorkers)) / float64(cap(u.dbWorkers)) * 100,
-        "event_pool_usage":   float64(len(u.eventWorkers)) / float64(cap(u.eventWorkers)) * 100,
+        "concurrency":        u.limiter.GetStats(),
+        "version_retries":    u.versionRetries.Load(),
+    }
+    if u.committer != nil {
+        stats["group_commit"] = u.committer.GetStats()
     }
+    return stats
 }
\ No newline at end of file
@@ -3,16 +3,21 @@ package services
 import (
 	"context"
 	"errors"
+	"order-service/internal/auth"
 	"order-service/internal/domain"
 	"order-service/internal/infra"
+	"order-service/internal/infra/messaging"
 	"order-service/internal/mocks"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/go-redis/redis/v8"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 type MockRedisClient struct {
@@ -92,6 +97,15 @@ func TestOrderService_CreateOrder(t *testing.T) {
 			},
 			expectedError: "product not found",
 		},
+		{
+			name:       "product out of stock",
+			productId:  777,
+			totalPrice: 1000,
+			setupMocks: func(mockRepo *mocks.MockOrderRepository, mockProdClient *mocks.MockProductClient, mockPub *mocks.MockPublisher) {
+				mockProdClient.On("GetProductById", mock.Anything, uint64(777)).Return(&infra.ProductInfo{ID: 777, Qty: 0}, nil)
+			},
+			expectedError: "out of stock",
+		},
 		{
 			name:       "product returns nil",
 			productId:  888,
@@ -128,12 +142,6 @@ func TestOrderService_CreateOrder(t *testing.T) {
 			tt.setupMocks(mockRepo, mockProdClient, mockPublisher)
 
 			service := NewOrderService(mockRepo, mockProdClient, mockPublisher)
-			
-			if tt.name == "service overloaded - synchronous fallback fails" {
-				for i := 0; i < cap(service.orderBuffer)+1; i++ {
-					service.orderBuffer <- &domain.Order{}
-				}
-			}
 
 			result, err := service.CreateOrder(context.Background(), tt.productId, tt.totalPrice)
 
@@ -383,8 +391,12 @@ func TestOrderService_BatchProcessing(t *testing.T) {
 
 	mockProdClient.On("GetProductById", mock.Anything, uint64(1)).Return(product, nil)
 	
+	var nextID uint64
 	mockRepo.On("SaveBatch", mock.AnythingOfType("[]*domain.Order")).Return(nil).Maybe()
-	mockRepo.On("Save", mock.AnythingOfType("*domain.Order")).Return(nil).Maybe()
+	mockRepo.On("Save", mock.AnythingOfType("*domain.Order")).Return(nil).Maybe().Run(func(args mock.Arguments) {
+		order := args.Get(0).(*domain.Order)
+		order.ID = atomic.AddUint64(&nextID, 1)
+	})
 	mockPublisher.On("Publish", mock.Anything, "order.created", mock.Anything).Return(nil).Maybe()
 
 	service := NewOrderService(mockRepo, mockProdClient, mockPublisher)
@@ -410,42 +422,137 @@ func TestOrderService_ServiceOverload(t *testing.T) {
 	mockProdClient := new(mocks.MockProductClient)
 	mockPublisher := new(mocks.MockPublisher)
 
-	product := &infra.ProductInfo{
-		ID:    1,
-		Name:  "Test Product",
-		Price: 1000,
-		Qty:   5,
+	service := NewOrderService(mockRepo, mockProdClient, mockPublisher)
+	cfg := DefaultLimiterConfig()
+	cfg.InitialLimit, cfg.MinLimit = 2, 2
+	service.SetConcurrencyLimiter(NewAdaptiveLimiter(cfg, nil))
+
+	for i := 0; i < 2; i++ {
+		_, ok := service.limiter.Acquire(PriorityCritical)
+		require.True(t, ok)
 	}
 
-	mockProdClient.On("GetProductById", mock.Anything, uint64(1)).Return(product, nil)
+	result, err := service.CreateOrder(context.Background(), 1, 1000)
+	assert.ErrorIs(t, err, ErrOverloaded)
+	assert.Nil(t, result)
 
-	service := NewOrderService(mockRepo, mockProdClient, mockPublisher)
+	// Rejected before any dependency is touched
+	mockProdClient.AssertNotCalled(t, "GetProductById", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "Save", mock.Anything)
+}
 
-	for len(service.orderBuffer) < cap(service.orderBuffer) {
-		select {
-		case service.orderBuffer <- &domain.Order{}:
-		default:
-			return
-		}
+// saturatedPublisher reports a backed-up publish queue.
+type saturatedPublisher struct{ mocks.MockPublisher }
+
+func (*saturatedPublisher) Saturated() bool { return true }
+
+func TestOrderService_ShedsLoadWhilePublisherIsSaturated(t *testing.T) {
+	mockRepo := new(mocks.MockOrderRepository)
+	mockProdClient := new(mocks.MockProductClient)
+	service := NewOrderService(mockRepo, mockProdClient, &saturatedPublisher{})
+
+	result, err := service.CreateOrder(context.Background(), 1, 1000)
+	assert.ErrorIs(t, err, ErrOverloaded)
+	assert.Nil(t, result)
+	mockProdClient.AssertNotCalled(t, "GetProductById", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "Save", mock.Anything)
+}
+
+func TestOrderService_FailsOrderWhenPublishQueueIsFull(t *testing.T) {
+	repo := newMemoryOrderRepo()
+	products := new(mocks.MockProductClient)
+	products.On("GetProductById", mock.Anything, uint64(1)).Return(&infra.ProductInfo{ID: 1, Qty: 5}, nil)
+	pub := new(mocks.MockPublisher)
+	pub.On("Publish", mock.Anything, domain.EventOrderCreated, mock.Anything).Return(messaging.ErrQueueFull)
+	pay := infra.NewFakePaymentProvider()
+	service := NewOrderService(repo, products, pub)
+	service.SetPaymentProvider(pay)
+
+	result, err := service.CreateOrder(context.Background(), 1, 1000)
+	assert.ErrorIs(t, err, ErrOverloaded)
+	assert.Nil(t, result)
+
+	require.Len(t, repo.orders, 1)
+	for _, o := range repo.orders {
+		assert.Equal(t, domain.StatusFailed, o.Status)
+		auth, _ := pay.Authorization(o.PaymentID)
+		assert.True(t, auth.Voided)
 	}
+}
 
-	for len(service.workerPool) < cap(service.workerPool) {
-		select {
-		case service.workerPool <- struct{}{}:
-		default:
-			return
-		}
+func TestOrderService_CancelOrder(t *testing.T) {
+	mockRepo := new(mocks.MockOrderRepository)
+	mockProdClient := new(mocks.MockProductClient)
+	mockPublisher := new(mocks.MockPublisher)
+
+	mockRepo.On("FindByID", uint64(1)).Return(&domain.Order{ID: 1, Status: domain.StatusPending}, nil)
+	mockRepo.On("FindByID", uint64(2)).Return(&domain.Order{ID: 2, Status: domain.StatusConfirmed}, nil)
+	mockRepo.On("UpdateStatus", uint64(1), domain.StatusPending, domain.StatusCancelled).Return(nil)
+	mockPublisher.On("Publish", mock.Anything, "order.cancelled", mock.Anything).Return(nil).Maybe()
+
+	service := NewOrderService(mockRepo, mockProdClient, mockPublisher)
+
+	order, err := service.CancelOrder(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.StatusCancelled, order.Status)
+
+	_, err = service.CancelOrder(context.Background(), 2)
+	assert.ErrorIs(t, err, ErrOrderNotCancellable)
+
+	time.Sleep(50 * time.Millisecond)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestOrderService_CreateOrder_OutOfStockFromCache(t *testing.T) {
+	tests := []struct {
+		name  string
+		cache func(t *testing.T, s *OrderService)
+	}{
+		{"local cache", func(t *testing.T, s *OrderService) {
+			s.localCache.Store(uint64(7), &cachedProduct{product: &infra.ProductInfo{ID: 7, Qty: 0}, expiresAt: time.Now().Add(time.Minute)})
+		}},
+		{"redis", func(t *testing.T, s *OrderService) {
+			mr := miniredis.RunT(t)
+			require.NoError(t, mr.Set("product:7", `{"id":7,"name":"Seven","price":700,"qty":0}`))
+			s.SetRedisClient(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+		}},
 	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mocks.MockOrderRepository)
+			mockProdClient := new(mocks.MockProductClient)
+			service := NewOrderService(mockRepo, mockProdClient, new(mocks.MockPublisher))
+			tt.cache(t, service)
 
-	result, err := service.CreateOrder(context.Background(), 1, 1000)
-	
-	if err != nil {
-		assert.Contains(t, err.Error(), "service overloaded")
-		assert.Nil(t, result)
-	} else {
-		assert.NotNil(t, result)
+			_, err := service.CreateOrder(context.Background(), 7, 700)
+			assert.ErrorIs(t, err, ErrOutOfStock)
+			mockProdClient.AssertNotCalled(t, "GetProductById", mock.Anything, mock.Anything)
+			mockRepo.AssertNotCalled(t, "Save", mock.Anything)
+		})
 	}
+}
+
+func TestOrderService_WarmupPopularProducts(t *testing.T) {
+	mockRepo := new(mocks.MockOrderRepository)
+	mockProdClient := new(mocks.MockProductClient)
+	mockPublisher := new(mocks.MockPublisher)
+
+	mockRepo.On("FindMostOrderedProductIds", 3).Return([]uint64{5, 2, 9}, nil)
+	mockProdClient.On("GetProductsByIds", mock.Anything, []uint64{5, 2, 9}).Return(map[uint64]*infra.ProductInfo{
+		5: {ID: 5, Name: "Five", Price: 500, Qty: 1},
+		2: {ID: 2, Name: "Two", Price: 200, Qty: 1},
+	}, nil)
+
+	service := NewOrderService(mockRepo, mockProdClient, mockPublisher)
+
+	n, err := service.WarmupPopularProducts(context.Background(), 3)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.True(t, service.isProductValidCached(5))
+	assert.True(t, service.isProductValidCached(2))
+	assert.False(t, service.isProductValidCached(9))
 
+	mockRepo.AssertExpectations(t)
 	mockProdClient.AssertExpectations(t)
 }
 
@@ -474,4 +581,22 @@ func BenchmarkOrderService_CreateOrder(b *testing.B) {
 			_, _ = service.CreateOrder(context.Background(), 1, 1000)
 		}
 	})
-}
\ No newline at end of file
+}
+func TestOrderService_CreateOrder_RecordsCreator(t *testing.T) {
+	mockRepo := new(mocks.MockOrderRepository)
+	mockProdClient := new(mocks.MockProductClient)
+	mockPublisher := new(mocks.MockPublisher)
+
+	mockProdClient.On("GetProductById", mock.Anything, uint64(1)).Return(CreateMockProduct(1, "Test Product", 1000, 10), nil)
+	mockRepo.On("Save", mock.MatchedBy(func(o *domain.Order) bool { return o.CreatedBy == "jwt:42" })).
+		Run(func(args mock.Arguments) { args.Get(0).(*domain.Order).ID = 1 }).Return(nil)
+	mockPublisher.On("Publish", mock.Anything, "order.created", mock.Anything).Return(nil).Maybe()
+
+	service := NewOrderService(mockRepo, mockProdClient, mockPublisher)
+	ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "42", Method: auth.MethodJWT})
+
+	order, err := service.CreateOrder(ctx, 1, 1000)
+	require.NoError(t, err)
+	assert.Equal(t, "jwt:42", order.CreatedBy)
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,304 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"order-service/internal/auth"
+	"order-service/internal/domain"
+	"order-service/internal/infra/messaging"
+	"order-service/internal/repository"
+)
+
+// statusResyncInterval bounds how long a watcher can miss a transition if
+// a broadcast was lost, e.g. while Redis was unreachable.
+const statusResyncInterval = 5 * time.Second
+
+// maxStatusUpdateAttempts bounds how often transition re-reads an order
+// after a version conflict.
+const maxStatusUpdateAttempts = 3
+
+// RegisterEventHandlers wires the product service replies into the
+// order status update path.
+func (u *OrderService) RegisterEventHandlers(sub messaging.Subscriber) {
+	sub.Subscribe(domain.EventStockConfirmed, messaging.AckOnSuccess(func(ctx context.Context, data json.RawMessage) error {
+		var evt domain.StockConfirmedEvent
+		if err := json.Unmarshal(data, &evt); err != nil {
+			return messaging.Permanent(fmt.Errorf("decode order.qty_confirmed: %w", err))
+		}
+		return u.HandleStockConfirmed(ctx, evt)
+	}))
+	sub.Subscribe(domain.EventStockFailed, messaging.AckOnSuccess(func(ctx context.Context, data json.RawMessage) error {
+		var evt domain.StockFailedEvent
+		if err := json.Unmarshal(data, &evt); err != nil {
+			return messaging.Permanent(fmt.Errorf("decode order.qty_failed: %w", err))
+		}
+		return u.HandleStockFailed(ctx, evt)
+	}))
+}
+
+// HandleStockConfirmed confirms the order, or with a payment provider
+// captures its payment and marks it paid. An order cancelled or failed
+// before the confirmation arrived gets its stock released instead.
+func (u *OrderService) HandleStockConfirmed(ctx context.Context, evt domain.StockConfirmedEvent) error {
+	if u.payments != nil {
+		o, err := u.repo.FindByID(evt.OrderID)
+		if err != nil {
+			return storageError(fmt.Errorf("load order %d: %w", evt.OrderID, err))
+		}
+		if o != nil && o.Status == domain.StatusPending && u.paysThroughProvider(o) {
+			return u.captureOnStockConfirmed(ctx, o)
+		}
+	}
+	confirmed, err := u.transition(ctx, evt.OrderID, domain.StatusPending, domain.StatusConfirmed, "")
+	if err != nil || confirmed != nil {
+		return err
+	}
+	return u.releaseStockIfClosed(evt.OrderID)
+}
+
+// releaseStockIfClosed publishes stock.release for an order that was
+// cancelled or failed while the product service reserved its stock.
+// Nothing else gives that unit back. The product service releases once
+// per order, so redelivered confirmations are harmless.
+func (u *OrderService) releaseStockIfClosed(id uint64) error {
+	o, err := u.repo.FindByID(id)
+	if err != nil {
+		return storageError(fmt.Errorf("load order %d: %w", id, err))
+	}
+	if o != nil && (o.Status == domain.StatusCancelled || o.Status == domain.StatusFailed) {
+		u.releaseStock(o)
+	}
+	return nil
+}
+
+func (u *OrderService) HandleStockFailed(ctx context.Context, evt domain.StockFailedEvent) error {
+	o, err := u.transition(ctx, evt.OrderID, domain.StatusPending, domain.StatusFailed, evt.Reason)
+	if o != nil {
+		u.releasePaymentOrLog(ctx, o)
+	}
+	return err
+}
+
+// transition moves an order from one status to another and returns the
+// updated order. It returns nil without error when the order is not in
+// status from, which makes redelivered events harmless. Losing a version
+// race to another writer is retried a few times from a fresh read before
+// giving up with ErrConcurrentUpdate.
+func (u *OrderService) transition(ctx context.Context, id uint64, from, to domain.OrderStatus, reason string) (*domain.Order, error) {
+	for attempt := 1; ; attempt++ {
+		o, err := u.repo.FindByID(id)
+		if err != nil {
+			return nil, storageError(fmt.Errorf("load order %d: %w", id, err))
+		}
+		if o == nil || o.Status != from {
+			log.Printf("Order %d not in status %s, ignoring transition to %s", id, from, to)
+			return nil, nil
+		}
+
+		err = u.updateStatus(ctx, o, to, reason)
+		var conflict *repository.VersionConflictError
+		switch {
+		case err == nil:
+			return o, nil
+		case errors.Is(err, repository.ErrDuplicateMessage):
+			log.Printf("Order %d: message already processed, ignoring transition to %s", id, to)
+			return nil, nil
+		case !errors.As(err, &conflict):
+			return nil, storageError(fmt.Errorf("update order %d status: %w", id, err))
+		case attempt == maxStatusUpdateAttempts:
+			return nil, fmt.Errorf("%w: %w", ErrConcurrentUpdate, err)
+		}
+		u.versionRetries.Add(1)
+	}
+}
+
+// updateStatus moves o to status to if nobody updated it since it was
+// read, records the change in the status history and broadcasts it to
+// watchers and webhooks. When called from a message handler the message
+// is added to the inbox in the same write, so a redelivery fails with
+// repository.ErrDuplicateMessage. On success o carries the new status
+// and version.
+func (u *OrderService) updateStatus(ctx context.Context, o *domain.Order, to domain.OrderStatus, reason string) error {
+	now := time.Now()
+	change := &domain.OrderStatusHistory{
+		OrderID:    o.ID,
+		FromStatus: o.Status,
+		ToStatus:   to,
+		Reason:     reason,
+		Actor:      actorFrom(ctx),
+		CreatedAt:  now,
+	}
+	var inbox *domain.InboxMessage
+	if msg, ok := messaging.MessageFromContext(ctx); ok && msg.ID != "" {
+		change.SourceEventID = msg.ID
+		inbox = &domain.InboxMessage{MessageID: msg.ID, Pattern: msg.Topic, ProcessedAt: now}
+	}
+
+	if err := u.repo.UpdateStatus(change, o.Version, inbox); err != nil {
+		return err
+	}
+	o.Status = to
+	o.Version++
+
+	sc := statusChangeOf(change)
+	u.broadcaster.Publish(ctx, sc)
+	u.notifyWebhooks(o, sc)
+	return nil
+}
+
+func statusChangeOf(change *domain.OrderStatusHistory) domain.OrderStatusChange {
+	return domain.OrderStatusChange{
+		OrderID:    change.OrderID,
+		From:       change.FromStatus,
+		To:         change.ToStatus,
+		Reason:     change.Reason,
+		OccurredAt: change.CreatedAt,
+	}
+}
+
+// notifyWebhooks queues a stored status change of o for partner webhooks.
+func (u *OrderService) notifyWebhooks(o *domain.Order, sc domain.OrderStatusChange) {
+	if u.webhooks != nil {
+		u.webhooks.Notify(o, sc)
+	}
+}
+
+// actorFrom names who caused a status change: the authenticated caller,
+// the event pattern for broker-driven changes, or "system".
+func actorFrom(ctx context.Context) string {
+	if p, ok := auth.FromContext(ctx); ok {
+		return p.ID()
+	}
+	if msg, ok := messaging.MessageFromContext(ctx); ok {
+		return "event:" + msg.Topic
+	}
+	return "system"
+}
+
+// GetStatusHistory returns the status changes of an order the caller is
+// allowed to see, oldest first.
+func (u *OrderService) GetStatusHistory(ctx context.Context, id uint64) ([]domain.OrderStatusHistory, error) {
+	if _, err := u.GetOrderById(ctx, id); err != nil {
+		return nil, err
+	}
+	history, err := u.repo.FindStatusHistory(id)
+	if err != nil {
+		return nil, storageError(err)
+	}
+	if history == nil {
+		history = []domain.OrderStatusHistory{}
+	}
+	return history, nil
+}
+
+// SubscribeStatus returns a channel of status changes for one order. It is
+// the low-level feed behind WatchOrder and the SSE endpoint.
+func (u *OrderService) SubscribeStatus(orderId uint64) (<-chan domain.OrderStatusChange, func()) {
+	return u.broadcaster.Subscribe(orderId)
+}
+
+// EnableStatusFanout relays status changes between replicas through Redis.
+func (u *OrderService) EnableStatusFanout(ctx context.Context) error {
+	if u.redisClient == nil {
+		return fmt.Errorf("redis client not configured")
+	}
+	return u.broadcaster.UseRedis(ctx, u.redisClient)
+}
+
+// WatchOrder emits the current order and then every status change until the
+// order reaches a final status or ctx is done. The channel is closed when
+// watching stops.
+func (u *OrderService) WatchOrder(ctx context.Context, id uint64) (<-chan domain.Order, error) {
+	// Subscribe before reading so a transition between the read and the
+	// subscription is not lost.
+	changes, unsubscribe := u.broadcaster.Subscribe(id)
+
+	o, err := u.GetOrderById(ctx, id)
+	if err != nil {
+		unsubscribe()
+		return nil, err
+	}
+
+	updates := make(chan domain.Order, 1)
+	updates <- *o
+	if o.Status.IsFinal() {
+		unsubscribe()
+		close(updates)
+		return updates, nil
+	}
+
+	current := *o
+	go func() {
+		defer close(updates)
+		defer unsubscribe()
+
+		resync := time.NewTicker(statusResyncInterval)
+		defer resync.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case change := <-changes:
+				if change.To == current.Status {
+					continue
+				}
+				current.Status = change.To
+			case <-resync.C:
+				latest, err := u.repo.FindByID(id)
+				if err != nil || latest == nil || latest.Status == current.Status {
+					continue
+				}
+				current = *latest
+			}
+
+			select {
+			case updates <- current:
+			case <-ctx.Done():
+				return
+			}
+			if current.Status.IsFinal() {
+				return
+			}
+		}
+	}()
+	return updates, nil
+}
+
+// WaitForFinalStatus blocks until the order reaches a final status or
+// maxWait elapses, whichever comes first, and returns the latest known
+// order. It is driven by status broadcasts rather than polling the database.
+func (u *OrderService) WaitForFinalStatus(ctx context.Context, id uint64, maxWait time.Duration) (*domain.Order, error) {
+	changes, unsubscribe := u.broadcaster.Subscribe(id)
+	defer unsubscribe()
+
+	o, err := u.GetOrderById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if o.Status.IsFinal() || maxWait <= 0 {
+		return o, nil
+	}
+
+	timer := time.NewTimer(maxWait)
+	defer timer.Stop()
+
+	for {
+		select {
+		case change := <-changes:
+			o.Status = change.To
+			if o.Status.IsFinal() {
+				return o, nil
+			}
+		case <-timer.C:
+			return o, nil
+		case <-ctx.Done():
+			return o, nil
+		}
+	}
+}
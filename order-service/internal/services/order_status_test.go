@@ -0,0 +1,216 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"order-service/internal/auth"
+	"order-service/internal/domain"
+	"order-service/internal/infra/messaging"
+	"order-service/internal/mocks"
+	"order-service/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusBroadcaster_DeliversToSubscribersOfOrder(t *testing.T) {
+	b := NewStatusBroadcaster()
+
+	ch1, unsub1 := b.Subscribe(1)
+	defer unsub1()
+	ch2, unsub2 := b.Subscribe(2)
+	defer unsub2()
+
+	b.Publish(context.Background(), domain.OrderStatusChange{OrderID: 1, From: domain.StatusPending, To: domain.StatusConfirmed})
+
+	select {
+	case change := <-ch1:
+		assert.Equal(t, domain.StatusConfirmed, change.To)
+	case <-time.After(time.Second):
+		t.Fatal("subscriber of order 1 got nothing")
+	}
+	select {
+	case <-ch2:
+		t.Fatal("subscriber of order 2 must not receive order 1 changes")
+	default:
+	}
+
+	unsub1()
+	unsub1()
+	assert.Equal(t, 1, b.SubscriberCount())
+}
+
+func TestOrderService_HandleStockEvents(t *testing.T) {
+	mockRepo := new(mocks.MockOrderRepository)
+	mockRepo.On("FindByID", uint64(1)).Return(&domain.Order{ID: 1, Status: domain.StatusPending, Version: 1}, nil)
+	mockRepo.On("FindByID", uint64(2)).Return(&domain.Order{ID: 2, Status: domain.StatusConfirmed, Version: 2}, nil)
+	mockRepo.On("UpdateStatus", uint64(1), domain.StatusPending, domain.StatusConfirmed).Return(nil)
+
+	service := NewOrderService(mockRepo, new(mocks.MockProductClient), new(mocks.MockPublisher))
+	changes, unsubscribe := service.SubscribeStatus(1)
+	defer unsubscribe()
+
+	require.NoError(t, service.HandleStockConfirmed(context.Background(), domain.StockConfirmedEvent{OrderID: 1}))
+	select {
+	case change := <-changes:
+		assert.Equal(t, domain.StatusPending, change.From)
+		assert.Equal(t, domain.StatusConfirmed, change.To)
+	case <-time.After(time.Second):
+		t.Fatal("confirmation was not broadcast")
+	}
+
+	// A redelivered or late event for an order that already moved on is a no-op
+	require.NoError(t, service.HandleStockFailed(context.Background(), domain.StockFailedEvent{OrderID: 2, Reason: "out_of_stock"}))
+
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNumberOfCalls(t, "UpdateStatus", 1)
+}
+
+func TestOrderService_WatchOrderFollowsBroadcasts(t *testing.T) {
+	mockRepo := new(mocks.MockOrderRepository)
+	mockRepo.On("FindByID", uint64(3)).Return(&domain.Order{ID: 3, Status: domain.StatusPending}, nil)
+	mockRepo.On("UpdateStatus", uint64(3), domain.StatusPending, domain.StatusFailed).Return(nil)
+
+	service := NewOrderService(mockRepo, new(mocks.MockProductClient), new(mocks.MockPublisher))
+
+	updates, err := service.WatchOrder(context.Background(), 3)
+	require.NoError(t, err)
+	assert.Equal(t, domain.StatusPending, (<-updates).Status)
+
+	require.NoError(t, service.HandleStockFailed(context.Background(), domain.StockFailedEvent{OrderID: 3}))
+
+	select {
+	case o := <-updates:
+		assert.Equal(t, domain.StatusFailed, o.Status)
+	case <-time.After(time.Second):
+		t.Fatal("watcher did not see the transition")
+	}
+	_, open := <-updates
+	assert.False(t, open, "watch ends once the order is final")
+}
+
+func TestOrderService_WaitForFinalStatus(t *testing.T) {
+	mockRepo := new(mocks.MockOrderRepository)
+	mockRepo.On("FindByID", uint64(4)).Return(&domain.Order{ID: 4, Status: domain.StatusPending}, nil)
+	mockRepo.On("UpdateStatus", uint64(4), domain.StatusPending, domain.StatusConfirmed).Return(nil)
+
+	service := NewOrderService(mockRepo, new(mocks.MockProductClient), new(mocks.MockPublisher))
+
+	t.Run("returns pending order when wait expires", func(t *testing.T) {
+		o, err := service.WaitForFinalStatus(context.Background(), 4, 20*time.Millisecond)
+		require.NoError(t, err)
+		assert.Equal(t, domain.StatusPending, o.Status)
+	})
+
+	t.Run("returns as soon as the order is final", func(t *testing.T) {
+		go func() {
+			for service.broadcaster.SubscriberCount() == 0 {
+				time.Sleep(time.Millisecond)
+			}
+			_ = service.HandleStockConfirmed(context.Background(), domain.StockConfirmedEvent{OrderID: 4})
+		}()
+
+		start := time.Now()
+		o, err := service.WaitForFinalStatus(context.Background(), 4, 5*time.Second)
+		require.NoError(t, err)
+		assert.Equal(t, domain.StatusConfirmed, o.Status)
+		assert.Less(t, time.Since(start), time.Second)
+	})
+}
+
+func TestOrderService_TransitionRecordsHistory(t *testing.T) {
+	mockRepo := new(mocks.MockOrderRepository)
+	mockRepo.On("FindByID", uint64(5)).Return(&domain.Order{ID: 5, Status: domain.StatusPending, Version: 1}, nil)
+	mockRepo.On("UpdateStatus", uint64(5), domain.StatusPending, domain.StatusFailed).Return(nil)
+
+	service := NewOrderService(mockRepo, new(mocks.MockProductClient), new(mocks.MockPublisher))
+
+	ctx := messaging.WithMessage(context.Background(), messaging.MessageInfo{ID: "msg-7", Topic: "order.qty_failed"})
+	require.NoError(t, service.HandleStockFailed(ctx, domain.StockFailedEvent{OrderID: 5, Reason: "out_of_stock"}))
+
+	change := mockRepo.LastStatusChange()
+	require.NotNil(t, change)
+	assert.Equal(t, "out_of_stock", change.Reason)
+	assert.Equal(t, "msg-7", change.SourceEventID)
+	assert.Equal(t, "event:order.qty_failed", change.Actor)
+	assert.False(t, change.CreatedAt.IsZero())
+
+	inbox := mockRepo.LastInboxMessage()
+	require.NotNil(t, inbox, "consumed messages are recorded in the inbox")
+	assert.Equal(t, "msg-7", inbox.MessageID)
+	assert.Equal(t, "order.qty_failed", inbox.Pattern)
+
+	ctx = auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "ops", Method: auth.MethodAPIKey})
+	assert.Equal(t, "api_key:ops", actorFrom(ctx))
+	assert.Equal(t, "system", actorFrom(context.Background()))
+}
+
+func TestOrderService_GetStatusHistoryRespectsOwnership(t *testing.T) {
+	mockRepo := new(mocks.MockOrderRepository)
+	mockRepo.On("FindByID", uint64(6)).Return(&domain.Order{ID: 6, CustomerID: "c1"}, nil)
+	mockRepo.On("FindStatusHistory", uint64(6)).Return([]domain.OrderStatusHistory{
+		{OrderID: 6, FromStatus: domain.StatusPending, ToStatus: domain.StatusConfirmed},
+	}, nil)
+
+	service := NewOrderService(mockRepo, new(mocks.MockProductClient), new(mocks.MockPublisher))
+
+	owner := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "c1", Method: auth.MethodJWT, CustomerID: "c1"})
+	history, err := service.GetStatusHistory(owner, 6)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, domain.StatusConfirmed, history[0].ToStatus)
+
+	other := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "c2", Method: auth.MethodJWT, CustomerID: "c2"})
+	_, err = service.GetStatusHistory(other, 6)
+	assert.Equal(t, KindNotFound, KindOf(err))
+	mockRepo.AssertNumberOfCalls(t, "FindStatusHistory", 1)
+}
+
+func TestOrderService_TransitionRetriesVersionConflicts(t *testing.T) {
+	conflict := &repository.VersionConflictError{OrderID: 7, Expected: 1}
+
+	t.Run("succeeds after a lost race", func(t *testing.T) {
+		mockRepo := new(mocks.MockOrderRepository)
+		mockRepo.On("FindByID", uint64(7)).Return(&domain.Order{ID: 7, Status: domain.StatusPending, Version: 1}, nil)
+		mockRepo.On("UpdateStatus", uint64(7), domain.StatusPending, domain.StatusConfirmed).Return(conflict).Once()
+		mockRepo.On("UpdateStatus", uint64(7), domain.StatusPending, domain.StatusConfirmed).Return(nil).Once()
+
+		service := NewOrderService(mockRepo, new(mocks.MockProductClient), new(mocks.MockPublisher))
+		require.NoError(t, service.HandleStockConfirmed(context.Background(), domain.StockConfirmedEvent{OrderID: 7}))
+		mockRepo.AssertNumberOfCalls(t, "FindByID", 2)
+		assert.Equal(t, int64(1), service.GetServiceStats()["version_retries"])
+	})
+
+	t.Run("gives up after bounded attempts", func(t *testing.T) {
+		mockRepo := new(mocks.MockOrderRepository)
+		mockRepo.On("FindByID", uint64(7)).Return(&domain.Order{ID: 7, Status: domain.StatusPending, Version: 1}, nil)
+		mockRepo.On("UpdateStatus", uint64(7), domain.StatusPending, domain.StatusConfirmed).Return(conflict)
+
+		service := NewOrderService(mockRepo, new(mocks.MockProductClient), new(mocks.MockPublisher))
+		err := service.HandleStockConfirmed(context.Background(), domain.StockConfirmedEvent{OrderID: 7})
+		assert.ErrorIs(t, err, ErrConcurrentUpdate)
+		mockRepo.AssertNumberOfCalls(t, "UpdateStatus", maxStatusUpdateAttempts)
+	})
+}
+
+func TestOrderService_DuplicateMessageIsSkipped(t *testing.T) {
+	mockRepo := new(mocks.MockOrderRepository)
+	mockRepo.On("FindByID", uint64(8)).Return(&domain.Order{ID: 8, Status: domain.StatusPending, Version: 1}, nil)
+	mockRepo.On("UpdateStatus", uint64(8), domain.StatusPending, domain.StatusConfirmed).Return(repository.ErrDuplicateMessage)
+
+	service := NewOrderService(mockRepo, new(mocks.MockProductClient), new(mocks.MockPublisher))
+	changes, unsubscribe := service.SubscribeStatus(8)
+	defer unsubscribe()
+
+	ctx := messaging.WithMessage(context.Background(), messaging.MessageInfo{ID: "msg-8", Topic: "order.qty_confirmed"})
+	require.NoError(t, service.HandleStockConfirmed(ctx, domain.StockConfirmedEvent{OrderID: 8}), "duplicates are acked, not requeued")
+
+	select {
+	case <-changes:
+		t.Fatal("a duplicate must not be broadcast")
+	case <-time.After(20 * time.Millisecond):
+	}
+	mockRepo.AssertNumberOfCalls(t, "UpdateStatus", 1)
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"order-service/internal/domain"
+	"order-service/internal/infra"
+)
+
+var (
+	ErrPaymentDeclined    = &Error{Kind: KindValidation, Code: "PAYMENT_DECLINED", Message: "payment was declined"}
+	ErrPaymentUnavailable = &Error{Kind: KindUnavailable, Code: "PAYMENT_UNAVAILABLE", Message: "payment provider unavailable"}
+)
+
+// paymentError classifies a payment provider failure. Anything but a
+// decline is worth retrying.
+func paymentError(err error) error {
+	if errors.Is(err, infra.ErrPaymentDeclined) || errors.Is(err, infra.ErrPaymentVoided) || errors.Is(err, infra.ErrUnknownAuthorization) ||
+		errors.Is(err, infra.ErrRefundExceedsCapture) {
+		return fmt.Errorf("%w: %w", ErrPaymentDeclined, err)
+	}
+	return fmt.Errorf("%w: %w", ErrPaymentUnavailable, err)
+}
+
+// paysThroughProvider reports whether o's payment is handled by the
+// payment provider, i.e. it was authorized when the order was created.
+func (u *OrderService) paysThroughProvider(o *domain.Order) bool {
+	return u.payments != nil && o.PaymentID != ""
+}
+
+// authorizePayment holds the order's price before the order is saved.
+// Free orders need no payment.
+func (u *OrderService) authorizePayment(ctx context.Context, o *domain.Order) error {
+	if u.payments == nil || o.TotalPrice == 0 {
+		return nil
+	}
+	id, err := u.payments.Authorize(ctx, o.TotalPrice)
+	if err != nil {
+		return paymentError(err)
+	}
+	o.PaymentID = id
+	return nil
+}
+
+func (u *OrderService) capturePayment(ctx context.Context, o *domain.Order) error {
+	if err := u.payments.Capture(ctx, o.PaymentID); err != nil {
+		return paymentError(fmt.Errorf("capture %s for order %d: %w", o.PaymentID, o.ID, err))
+	}
+	return nil
+}
+
+// releasePayment gives back what the order holds: the authorization is
+// voided, or refunded in full if it was captured already.
+func (u *OrderService) releasePayment(ctx context.Context, o *domain.Order) error {
+	if !u.paysThroughProvider(o) {
+		return nil
+	}
+	err := u.payments.Void(ctx, o.PaymentID)
+	if errors.Is(err, infra.ErrPaymentCaptured) {
+		err = u.payments.Refund(ctx, o.PaymentID, o.TotalPrice, fmt.Sprintf("order-%d-release", o.ID))
+	}
+	if err != nil {
+		return paymentError(fmt.Errorf("release %s for order %d: %w", o.PaymentID, o.ID, err))
+	}
+	return nil
+}
+
+// releasePaymentOrLog is releasePayment for paths that cannot retry. An
+// authorization left behind expires at the provider.
+func (u *OrderService) releasePaymentOrLog(ctx context.Context, o *domain.Order) {
+	if err := u.releasePayment(ctx, o); err != nil {
+		log.Printf("Failed to release payment of order %d: %v", o.ID, err)
+	}
+}
+
+// captureOnStockConfirmed takes the payment of an order whose stock was
+// reserved and marks it paid. A provider outage is returned so the reply
+// is redelivered; a declined capture fails the order and gives the stock
+// back.
+func (u *OrderService) captureOnStockConfirmed(ctx context.Context, o *domain.Order) error {
+	if err := u.capturePayment(ctx, o); err != nil {
+		if !errors.Is(err, ErrPaymentDeclined) {
+			return err
+		}
+		failed, terr := u.transition(ctx, o.ID, domain.StatusPending, domain.StatusFailed, "payment capture declined")
+		if terr != nil || failed == nil {
+			return terr
+		}
+		u.releaseStock(failed)
+		u.releasePaymentOrLog(ctx, failed)
+		return nil
+	}
+
+	paid, err := u.transition(ctx, o.ID, domain.StatusPending, domain.StatusPaid, "")
+	if err != nil || paid != nil {
+		return err
+	}
+	// Either a redelivery, or the order was cancelled while capturing, in
+	// which case the money goes back
+	current, err := u.repo.FindByID(o.ID)
+	if err != nil {
+		return storageError(err)
+	}
+	if current != nil && current.Status != domain.StatusPaid {
+		u.releasePaymentOrLog(ctx, current)
+	}
+	return u.releaseStockIfClosed(o.ID)
+}
+
+func (u *OrderService) releaseStock(o *domain.Order) {
+	cmd := domain.StockReleaseCommand{OrderID: o.ID, ProductId: o.ProductId}
+	if err := u.publisher.Publish(context.Background(), cmd.EventType(), cmd); err != nil {
+		log.Printf("Failed to publish stock release for order %d: %v", o.ID, err)
+	}
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"order-service/internal/domain"
+	"order-service/internal/infra"
+	"order-service/internal/infra/messaging"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newPaymentFlow is the message flow with orders paid through pay. The
+// returned func counts the stock releases published.
+func newPaymentFlow(t *testing.T, pay *infra.FakePaymentProvider) (*OrderService, *memoryOrderRepo, *messaging.MemoryBroker, func() int) {
+	t.Helper()
+	s, repo, broker := newMessageFlowService(t)
+	s.SetPaymentProvider(pay)
+
+	var mu sync.Mutex
+	released := 0
+	broker.Subscribe(domain.CommandStockRelease, func(_ context.Context, d messaging.Delivery) {
+		mu.Lock()
+		released++
+		mu.Unlock()
+		d.Ack()
+	})
+	return s, repo, broker, func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return released
+	}
+}
+
+func waitIdle(t *testing.T, b *messaging.MemoryBroker) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	require.NoError(t, b.WaitIdle(ctx))
+}
+
+func TestPayment_CapturedWhenStockIsReserved(t *testing.T) {
+	pay := infra.NewFakePaymentProvider()
+	s, repo, broker, released := newPaymentFlow(t, pay)
+
+	order, err := s.CreateOrder(context.Background(), 1, 1000)
+	require.NoError(t, err)
+	assert.Equal(t, "auth-1", order.PaymentID)
+	waitIdle(t, broker)
+
+	got, _ := repo.FindByID(order.ID)
+	assert.Equal(t, domain.StatusPaid, got.Status)
+	auth, _ := pay.Authorization("auth-1")
+	assert.True(t, auth.Captured)
+	assert.Zero(t, released())
+}
+
+func TestPayment_DeclinedAuthorizationCreatesNothing(t *testing.T) {
+	pay := infra.NewFakePaymentProvider(infra.PaymentFailure{Op: infra.PaymentAuthorize, MinAmount: 5000, Err: infra.ErrPaymentDeclined})
+	s, repo, broker, _ := newPaymentFlow(t, pay)
+
+	_, err := s.CreateOrder(context.Background(), 1, 5000)
+	require.ErrorIs(t, err, ErrPaymentDeclined)
+	assert.Equal(t, KindValidation, KindOf(err))
+	waitIdle(t, broker)
+	assert.Empty(t, repo.orders)
+}
+
+func TestPayment_VoidedWhenStockIsUnavailable(t *testing.T) {
+	pay := infra.NewFakePaymentProvider()
+	s, repo, broker, _ := newPaymentFlow(t, pay)
+
+	order, err := s.CreateOrder(context.Background(), 2, 1000)
+	require.NoError(t, err)
+	waitIdle(t, broker)
+
+	got, _ := repo.FindByID(order.ID)
+	assert.Equal(t, domain.StatusFailed, got.Status)
+	auth, _ := pay.Authorization(order.PaymentID)
+	assert.True(t, auth.Voided)
+	assert.False(t, auth.Captured)
+}
+
+func TestPayment_DeclinedCaptureFailsOrderAndReleasesStock(t *testing.T) {
+	pay := infra.NewFakePaymentProvider(infra.PaymentFailure{Op: infra.PaymentCapture, Err: infra.ErrPaymentDeclined})
+	s, repo, broker, released := newPaymentFlow(t, pay)
+
+	order, err := s.CreateOrder(context.Background(), 1, 1000)
+	require.NoError(t, err)
+	waitIdle(t, broker)
+
+	got, _ := repo.FindByID(order.ID)
+	assert.Equal(t, domain.StatusFailed, got.Status)
+	assert.Equal(t, 1, released())
+	auth, _ := pay.Authorization(order.PaymentID)
+	assert.True(t, auth.Voided)
+}
+
+func TestPayment_CaptureOutageIsRetriedByRedelivery(t *testing.T) {
+	pay := infra.NewFakePaymentProvider(infra.PaymentFailure{Op: infra.PaymentCapture, Err: infra.ErrPaymentUnavailable, Times: 2})
+	s, repo, broker, _ := newPaymentFlow(t, pay)
+
+	order, err := s.CreateOrder(context.Background(), 1, 1000)
+	require.NoError(t, err)
+	waitIdle(t, broker)
+
+	got, _ := repo.FindByID(order.ID)
+	assert.Equal(t, domain.StatusPaid, got.Status)
+	assert.Equal(t, 3, pay.Calls(infra.PaymentCapture))
+	assert.Empty(t, broker.DeadLetters())
+}
+
+func TestPayment_ConfirmationOfCancelledOrderReleasesStock(t *testing.T) {
+	s, repo, broker, released := newPaymentFlow(t, infra.NewFakePaymentProvider())
+	order := &domain.Order{ProductId: 1, TotalPrice: 1000, Status: domain.StatusCancelled}
+	require.NoError(t, repo.Save(order))
+
+	require.NoError(t, s.HandleStockConfirmed(context.Background(), domain.StockConfirmedEvent{OrderID: order.ID}))
+	waitIdle(t, broker)
+
+	got, _ := repo.FindByID(order.ID)
+	assert.Equal(t, domain.StatusCancelled, got.Status)
+	assert.Equal(t, 1, released())
+}
+
+func TestPayment_CancelVoidsAuthorization(t *testing.T) {
+	pay := infra.NewFakePaymentProvider()
+	s, repo, broker := newMessageFlowService(t)
+	s.SetPaymentProvider(pay)
+	// Nobody answers order.created, so the order stays pending
+	order := &domain.Order{ProductId: 9, TotalPrice: 300, Status: domain.StatusPending}
+	require.NoError(t, s.authorizePayment(context.Background(), order))
+	require.NoError(t, repo.Save(order))
+
+	_, err := s.CancelOrder(context.Background(), order.ID)
+	require.NoError(t, err)
+	waitIdle(t, broker)
+
+	auth, _ := pay.Authorization(order.PaymentID)
+	assert.True(t, auth.Voided)
+}
+
+func TestPayment_SagaCapturesInsteadOfSendingCommands(t *testing.T) {
+	pay := infra.NewFakePaymentProvider(infra.PaymentFailure{Op: infra.PaymentCapture, MinAmount: 5000, Err: infra.ErrPaymentDeclined})
+	f := newSagaFlowWith(t, false, pay)
+
+	paid := f.createOrder(t, 1, 1000)
+	assert.Equal(t, domain.StatusPaid, f.orderStatus(paid))
+	assert.Equal(t, []string{
+		"reserve_stock:command", "reserve_stock:succeeded",
+		"charge_payment:succeeded",
+		"confirm:succeeded",
+	}, logActions(f.saga(t, paid)))
+
+	declined := f.createOrder(t, 1, 6000)
+	assert.Equal(t, domain.StatusFailed, f.orderStatus(declined))
+	assert.Equal(t, []string{domain.CommandStockRelease}, f.compensated())
+	assert.Equal(t, "charge_payment: payment capture declined", f.saga(t, declined).FailureReason)
+	o, _ := f.orderRepo.FindByID(declined)
+	auth, _ := pay.Authorization(o.PaymentID)
+	assert.True(t, auth.Voided)
+}
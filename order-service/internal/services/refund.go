@@ -0,0 +1,229 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"order-service/internal/domain"
+	"order-service/internal/repository"
+)
+
+var (
+	ErrOrderNotRefundable    = &Error{Kind: KindConflict, Code: "ORDER_NOT_REFUNDABLE", Message: "order cannot be refunded"}
+	ErrInvalidRefund         = &Error{Kind: KindValidation, Code: "INVALID_REFUND", Message: "invalid refund"}
+	ErrRefundExceedsCaptured = &Error{Kind: KindValidation, Code: "REFUND_EXCEEDS_CAPTURED", Message: "refund exceeds the captured amount"}
+)
+
+const maxRefundReasonLength = 255
+
+// refundable reports whether an order in status s holds captured money.
+func refundable(s domain.OrderStatus) bool {
+	switch s {
+	case domain.StatusConfirmed, domain.StatusPaid, domain.StatusPartiallyRefunded:
+		return true
+	}
+	return false
+}
+
+// refundedStatus is the status of o once refunded in total has been given
+// back. With nothing refunded it is the status the order was captured in.
+func (u *OrderService) refundedStatus(o *domain.Order, refunded int64) domain.OrderStatus {
+	switch {
+	case refunded >= o.TotalPrice:
+		return domain.StatusRefunded
+	case refunded > 0:
+		return domain.StatusPartiallyRefunded
+	case u.paysThroughProvider(o):
+		return domain.StatusPaid
+	}
+	return domain.StatusConfirmed
+}
+
+// RefundOrder gives back amount of an order the caller may see, or all of
+// what is left when amount is 0. A non-zero ifVersion makes the refund
+// conditional on the order still being at that version; it fails with
+// ErrVersionMismatch otherwise. The amount is reserved on the order
+// before the payment provider is asked, so refunds racing each other can
+// never exceed the order's total. If the provider fails, the reservation
+// is released and the refund is recorded as failed. Orders not paid
+// through the provider are only recorded; order.refunded tells whoever
+// took the payment.
+func (u *OrderService) RefundOrder(ctx context.Context, id uint64, amount int64, reason string, ifVersion uint64) (*domain.Refund, error) {
+	if u.refunds == nil {
+		return nil, errors.New("refunds are not configured")
+	}
+	if amount < 0 {
+		return nil, fmt.Errorf("%w: amount must not be negative", ErrInvalidRefund)
+	}
+	if len(reason) > maxRefundReasonLength {
+		return nil, fmt.Errorf("%w: reason is longer than %d bytes", ErrInvalidRefund, maxRefundReasonLength)
+	}
+
+	refund, o, change, err := u.reserveRefund(ctx, id, amount, reason, ifVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.paysThroughProvider(o) {
+		ref := fmt.Sprintf("refund-%d", refund.ID)
+		if err := u.payments.Refund(ctx, o.PaymentID, refund.Amount, ref); err != nil {
+			err = paymentError(fmt.Errorf("refund %d of order %d: %w", refund.ID, o.ID, err))
+			u.releaseRefund(ctx, refund, err)
+			return nil, err
+		}
+	}
+
+	refund.Status = domain.RefundSucceeded
+	refund.UpdatedAt = time.Now()
+	if err := u.refunds.Complete(refund); err != nil {
+		// The money is back either way; the row just stays pending
+		log.Printf("Failed to mark refund %d of order %d succeeded: %v", refund.ID, o.ID, err)
+	}
+
+	evt := domain.OrderRefundedEvent{
+		OrderID:        o.ID,
+		RefundID:       refund.ID,
+		Amount:         refund.Amount,
+		RefundedAmount: o.RefundedAmount,
+		TotalPrice:     o.TotalPrice,
+		Status:         o.Status,
+		Reason:         refund.Reason,
+	}
+	if err := u.publisher.Publish(context.Background(), evt.EventType(), evt); err != nil {
+		log.Printf("Failed to publish refund event for order %d: %v", o.ID, err)
+	}
+	// Webhooks hear about the new status only now, as a refund that fails
+	// moves the order back
+	if change.FromStatus != change.ToStatus {
+		u.notifyWebhooks(o, statusChangeOf(change))
+	}
+	return refund, nil
+}
+
+// reserveRefund adds a pending refund to the order and its amount to the
+// order's refunded amount. It returns the refund, the updated order and
+// the status change made.
+func (u *OrderService) reserveRefund(ctx context.Context, id uint64, amount int64, reason string, ifVersion uint64) (*domain.Refund, *domain.Order, *domain.OrderStatusHistory, error) {
+	var refund *domain.Refund
+	var reserved *domain.OrderStatusHistory
+	o, err := u.adjustRefunded(ctx, id, ifVersion, func(o *domain.Order) (int64, error) {
+		if !refundable(o.Status) {
+			return 0, fmt.Errorf("%w: order is %s", ErrOrderNotRefundable, o.Status)
+		}
+		left := o.TotalPrice - o.RefundedAmount
+		want := amount
+		if want == 0 {
+			want = left
+		}
+		if want <= 0 || want > left {
+			return 0, fmt.Errorf("%w: %d requested, %d of %d left", ErrRefundExceedsCaptured, want, left, o.TotalPrice)
+		}
+		return want, nil
+	}, func(change *domain.OrderStatusHistory, version uint64, delta int64) error {
+		refund = &domain.Refund{
+			OrderID:   change.OrderID,
+			Amount:    delta,
+			Reason:    reason,
+			Status:    domain.RefundPending,
+			Actor:     change.Actor,
+			CreatedAt: change.CreatedAt,
+			UpdatedAt: change.CreatedAt,
+		}
+		reserved = change
+		return u.refunds.Reserve(refund, change, version)
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return refund, o, reserved, nil
+}
+
+// releaseRefund marks refund failed with cause and takes its amount off
+// the order again. If that fails too the amount stays reserved, which errs
+// on the side of refunding less.
+func (u *OrderService) releaseRefund(ctx context.Context, refund *domain.Refund, cause error) {
+	_, err := u.adjustRefunded(ctx, refund.OrderID, 0, func(*domain.Order) (int64, error) {
+		return -refund.Amount, nil
+	}, func(change *domain.OrderStatusHistory, version uint64, _ int64) error {
+		refund.Status = domain.RefundFailed
+		refund.FailureReason = truncate(cause.Error(), maxRefundReasonLength)
+		refund.UpdatedAt = change.CreatedAt
+		return u.refunds.Release(refund, change, version)
+	})
+	if err != nil {
+		log.Printf("Failed to release refund %d of order %d: %v", refund.ID, refund.OrderID, err)
+	}
+}
+
+// adjustRefunded changes the refunded amount of order id by what plan
+// returns and moves the order to the matching status through write. Like
+// transition it re-reads the order after a version conflict, so plan sees
+// the current order on every attempt, unless a non-zero ifVersion pins the
+// version the caller read.
+func (u *OrderService) adjustRefunded(ctx context.Context, id uint64, ifVersion uint64, plan func(*domain.Order) (int64, error), write func(*domain.OrderStatusHistory, uint64, int64) error) (*domain.Order, error) {
+	for attempt := 1; ; attempt++ {
+		o, err := u.GetOrderById(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if ifVersion != 0 && o.Version != ifVersion {
+			return nil, ErrVersionMismatch
+		}
+		delta, err := plan(o)
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		refunded := o.RefundedAmount + delta
+		change := &domain.OrderStatusHistory{
+			OrderID:    o.ID,
+			FromStatus: o.Status,
+			ToStatus:   u.refundedStatus(o, refunded),
+			Reason:     fmt.Sprintf("refunded %d of %d", refunded, o.TotalPrice),
+			Actor:      actorFrom(ctx),
+			CreatedAt:  now,
+		}
+		err = write(change, o.Version, delta)
+		var conflict *repository.VersionConflictError
+		switch {
+		case err == nil:
+			o.Status = change.ToStatus
+			o.RefundedAmount = refunded
+			o.Version++
+			if change.FromStatus != change.ToStatus {
+				u.broadcaster.Publish(ctx, statusChangeOf(change))
+			}
+			return o, nil
+		case !errors.As(err, &conflict):
+			return nil, storageError(fmt.Errorf("update refunds of order %d: %w", id, err))
+		case ifVersion != 0:
+			return nil, fmt.Errorf("%w: %w", ErrVersionMismatch, err)
+		case attempt == maxStatusUpdateAttempts:
+			return nil, fmt.Errorf("%w: %w", ErrConcurrentUpdate, err)
+		}
+		u.versionRetries.Add(1)
+	}
+}
+
+// ListRefunds returns the refunds of an order the caller may see, oldest
+// first.
+func (u *OrderService) ListRefunds(ctx context.Context, id uint64) ([]domain.Refund, error) {
+	if u.refunds == nil {
+		return nil, errors.New("refunds are not configured")
+	}
+	if _, err := u.GetOrderById(ctx, id); err != nil {
+		return nil, err
+	}
+	refunds, err := u.refunds.FindByOrderID(id)
+	if err != nil {
+		return nil, storageError(err)
+	}
+	if refunds == nil {
+		refunds = []domain.Refund{}
+	}
+	return refunds, nil
+}
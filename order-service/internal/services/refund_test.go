@@ -0,0 +1,279 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"order-service/internal/domain"
+	"order-service/internal/infra"
+	"order-service/internal/infra/messaging"
+	"order-service/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryRefundRepo stores refunds next to the orders of a memoryOrderRepo,
+// applying the same version check as its UpdateStatus.
+type memoryRefundRepo struct {
+	orders  *memoryOrderRepo
+	refunds []domain.Refund
+}
+
+func (r *memoryRefundRepo) Reserve(refund *domain.Refund, change *domain.OrderStatusHistory, version uint64) error {
+	r.orders.mu.Lock()
+	defer r.orders.mu.Unlock()
+	if err := r.adjust(change, version, refund.Amount); err != nil {
+		return err
+	}
+	refund.ID = uint64(len(r.refunds) + 1)
+	r.refunds = append(r.refunds, *refund)
+	return nil
+}
+
+func (r *memoryRefundRepo) Release(refund *domain.Refund, change *domain.OrderStatusHistory, version uint64) error {
+	r.orders.mu.Lock()
+	defer r.orders.mu.Unlock()
+	if err := r.adjust(change, version, -refund.Amount); err != nil {
+		return err
+	}
+	r.refunds[refund.ID-1] = *refund
+	return nil
+}
+
+func (r *memoryRefundRepo) adjust(change *domain.OrderStatusHistory, version uint64, delta int64) error {
+	o := r.orders.orders[change.OrderID]
+	if o.Version != version {
+		return &repository.VersionConflictError{OrderID: o.ID, Expected: version}
+	}
+	o.Status = change.ToStatus
+	o.RefundedAmount += delta
+	o.Version++
+	r.orders.orders[o.ID] = o
+	if change.FromStatus != change.ToStatus {
+		r.orders.history = append(r.orders.history, *change)
+	}
+	return nil
+}
+
+func (r *memoryRefundRepo) Complete(refund *domain.Refund) error {
+	r.orders.mu.Lock()
+	defer r.orders.mu.Unlock()
+	r.refunds[refund.ID-1] = *refund
+	return nil
+}
+
+func (r *memoryRefundRepo) FindByOrderID(orderID uint64) ([]domain.Refund, error) {
+	r.orders.mu.Lock()
+	defer r.orders.mu.Unlock()
+	var out []domain.Refund
+	for _, refund := range r.refunds {
+		if refund.OrderID == orderID {
+			out = append(out, refund)
+		}
+	}
+	return out, nil
+}
+
+type refundFlow struct {
+	orders  *OrderService
+	repo    *memoryOrderRepo
+	refunds *memoryRefundRepo
+	broker  *messaging.MemoryBroker
+	pay     *infra.FakePaymentProvider
+
+	mu     sync.Mutex
+	events []domain.OrderRefundedEvent
+}
+
+// newRefundFlow is the payment flow with refunds stored and order.refunded
+// recorded.
+func newRefundFlow(t *testing.T, pay *infra.FakePaymentProvider) *refundFlow {
+	t.Helper()
+	f := &refundFlow{pay: pay}
+	f.orders, f.repo, f.broker, _ = newPaymentFlow(t, pay)
+	f.refunds = &memoryRefundRepo{orders: f.repo}
+	f.orders.SetRefundRepository(f.refunds)
+	f.broker.Subscribe(domain.EventOrderRefunded, messaging.AckOnSuccess(func(_ context.Context, data json.RawMessage) error {
+		var evt domain.OrderRefundedEvent
+		if err := json.Unmarshal(data, &evt); err != nil {
+			return messaging.Permanent(err)
+		}
+		f.mu.Lock()
+		f.events = append(f.events, evt)
+		f.mu.Unlock()
+		return nil
+	}))
+	return f
+}
+
+// paidOrder creates an order for total and waits until it is paid.
+func (f *refundFlow) paidOrder(t *testing.T, total int64) *domain.Order {
+	t.Helper()
+	o, err := f.orders.CreateOrder(context.Background(), 1, total)
+	require.NoError(t, err)
+	waitIdle(t, f.broker)
+	o, _ = f.repo.FindByID(o.ID)
+	require.Equal(t, domain.StatusPaid, o.Status)
+	return o
+}
+
+func (f *refundFlow) refunded() []domain.OrderRefundedEvent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]domain.OrderRefundedEvent(nil), f.events...)
+}
+
+func TestRefund_PartialThenRest(t *testing.T) {
+	f := newRefundFlow(t, infra.NewFakePaymentProvider())
+	o := f.paidOrder(t, 1000)
+
+	first, err := f.orders.RefundOrder(context.Background(), o.ID, 300, "damaged box", 0)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RefundSucceeded, first.Status)
+	got, _ := f.repo.FindByID(o.ID)
+	assert.Equal(t, domain.StatusPartiallyRefunded, got.Status)
+	assert.Equal(t, int64(300), got.RefundedAmount)
+
+	// No amount refunds what is left
+	rest, err := f.orders.RefundOrder(context.Background(), o.ID, 0, "", 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(700), rest.Amount)
+	got, _ = f.repo.FindByID(o.ID)
+	assert.Equal(t, domain.StatusRefunded, got.Status)
+	assert.Equal(t, int64(1000), got.RefundedAmount)
+
+	auth, _ := f.pay.Authorization(o.PaymentID)
+	assert.Equal(t, int64(1000), auth.Refunded)
+
+	_, err = f.orders.RefundOrder(context.Background(), o.ID, 1, "", 0)
+	assert.ErrorIs(t, err, ErrOrderNotRefundable)
+
+	waitIdle(t, f.broker)
+	assert.Equal(t, []domain.OrderRefundedEvent{
+		{OrderID: o.ID, RefundID: first.ID, Amount: 300, RefundedAmount: 300, TotalPrice: 1000, Status: domain.StatusPartiallyRefunded, Reason: "damaged box"},
+		{OrderID: o.ID, RefundID: rest.ID, Amount: 700, RefundedAmount: 1000, TotalPrice: 1000, Status: domain.StatusRefunded},
+	}, f.refunded())
+
+	refunds, err := f.orders.ListRefunds(context.Background(), o.ID)
+	require.NoError(t, err)
+	require.Len(t, refunds, 2)
+	assert.Equal(t, "damaged box", refunds[0].Reason)
+	assert.Equal(t, domain.RefundSucceeded, refunds[1].Status)
+
+	f.repo.mu.Lock()
+	history := f.repo.history
+	f.repo.mu.Unlock()
+	require.Len(t, history, 3)
+	assert.Equal(t, "refunded 300 of 1000", history[1].Reason)
+	assert.Equal(t, domain.StatusRefunded, history[2].ToStatus)
+}
+
+func TestRefund_NeverExceedsCapturedTotal(t *testing.T) {
+	f := newRefundFlow(t, infra.NewFakePaymentProvider())
+	o := f.paidOrder(t, 1000)
+
+	_, err := f.orders.RefundOrder(context.Background(), o.ID, 1001, "", 0)
+	require.ErrorIs(t, err, ErrRefundExceedsCaptured)
+	assert.Equal(t, KindValidation, KindOf(err))
+
+	_, err = f.orders.RefundOrder(context.Background(), o.ID, 600, "", 0)
+	require.NoError(t, err)
+	_, err = f.orders.RefundOrder(context.Background(), o.ID, 600, "", 0)
+	require.ErrorIs(t, err, ErrRefundExceedsCaptured)
+
+	_, err = f.orders.RefundOrder(context.Background(), o.ID, -5, "", 0)
+	assert.ErrorIs(t, err, ErrInvalidRefund)
+
+	refunds, _ := f.orders.ListRefunds(context.Background(), o.ID)
+	assert.Len(t, refunds, 1)
+}
+
+func TestRefund_HonoursIfVersion(t *testing.T) {
+	f := newRefundFlow(t, infra.NewFakePaymentProvider())
+	o := f.paidOrder(t, 1000)
+
+	_, err := f.orders.RefundOrder(context.Background(), o.ID, 300, "", o.Version-1)
+	require.ErrorIs(t, err, ErrVersionMismatch)
+	assert.Equal(t, KindPreconditionFailed, KindOf(err))
+
+	_, err = f.orders.RefundOrder(context.Background(), o.ID, 300, "", o.Version)
+	require.NoError(t, err)
+	_, err = f.orders.RefundOrder(context.Background(), o.ID, 300, "", o.Version)
+	assert.ErrorIs(t, err, ErrVersionMismatch)
+
+	refunds, _ := f.orders.ListRefunds(context.Background(), o.ID)
+	assert.Len(t, refunds, 1)
+}
+
+func TestRefund_ConcurrentRefundsStayWithinTotal(t *testing.T) {
+	f := newRefundFlow(t, infra.NewFakePaymentProvider())
+	o := f.paidOrder(t, 1000)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var succeeded int64
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if r, err := f.orders.RefundOrder(context.Background(), o.ID, 300, "", 0); err == nil {
+				mu.Lock()
+				succeeded += r.Amount
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, _ := f.repo.FindByID(o.ID)
+	assert.LessOrEqual(t, succeeded, int64(900))
+	assert.Equal(t, succeeded, got.RefundedAmount)
+	auth, _ := f.pay.Authorization(o.PaymentID)
+	assert.Equal(t, succeeded, auth.Refunded)
+}
+
+func TestRefund_ProviderFailureReleasesReservation(t *testing.T) {
+	pay := infra.NewFakePaymentProvider(infra.PaymentFailure{Op: infra.PaymentRefund, Err: infra.ErrPaymentUnavailable, Times: 1})
+	f := newRefundFlow(t, pay)
+	o := f.paidOrder(t, 1000)
+
+	_, err := f.orders.RefundOrder(context.Background(), o.ID, 400, "", 0)
+	require.ErrorIs(t, err, ErrPaymentUnavailable)
+
+	got, _ := f.repo.FindByID(o.ID)
+	assert.Equal(t, domain.StatusPaid, got.Status)
+	assert.Zero(t, got.RefundedAmount)
+	refunds, _ := f.orders.ListRefunds(context.Background(), o.ID)
+	require.Len(t, refunds, 1)
+	assert.Equal(t, domain.RefundFailed, refunds[0].Status)
+	assert.NotEmpty(t, refunds[0].FailureReason)
+
+	// The released amount can be refunded again
+	_, err = f.orders.RefundOrder(context.Background(), o.ID, 1000, "", 0)
+	require.NoError(t, err)
+	waitIdle(t, f.broker)
+	assert.Len(t, f.refunded(), 1)
+}
+
+func TestRefund_OnlyCapturedOrders(t *testing.T) {
+	s, repo, _ := newMessageFlowService(t)
+	refunds := &memoryRefundRepo{orders: repo}
+	s.SetRefundRepository(refunds)
+
+	pending := &domain.Order{ProductId: 9, TotalPrice: 500, Status: domain.StatusPending}
+	require.NoError(t, repo.Save(pending))
+	_, err := s.RefundOrder(context.Background(), pending.ID, 100, "", 0)
+	assert.ErrorIs(t, err, ErrOrderNotRefundable)
+
+	// Without a payment provider the refund is only recorded and announced
+	confirmed := &domain.Order{ProductId: 9, TotalPrice: 500, Status: domain.StatusConfirmed}
+	require.NoError(t, repo.Save(confirmed))
+	r, err := s.RefundOrder(context.Background(), confirmed.ID, 500, "", 0)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RefundSucceeded, r.Status)
+	got, _ := repo.FindByID(confirmed.ID)
+	assert.Equal(t, domain.StatusRefunded, got.Status)
+}
@@ -0,0 +1,505 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"order-service/internal/domain"
+	"order-service/internal/infra"
+	"order-service/internal/infra/messaging"
+	"order-service/internal/repository"
+)
+
+var ErrSagaNotFound = &Error{Kind: KindNotFound, Code: "SAGA_NOT_FOUND", Message: "order has no saga"}
+
+// Steps of the fulfilment saga, in order.
+const (
+	SagaStepReserveStock  = "reserve_stock"
+	SagaStepChargePayment = "charge_payment"
+	SagaStepConfirm       = "confirm"
+)
+
+// Actions recorded in the saga log.
+const (
+	SagaActionCommand    = "command"
+	SagaActionRetry      = "retry"
+	SagaActionSucceeded  = "succeeded"
+	SagaActionFailed     = "failed"
+	SagaActionTimedOut   = "timed_out"
+	SagaActionCompensate = "compensate"
+	SagaActionAborted    = "aborted"
+)
+
+// SagaConfig controls how long the orchestrator waits for replies.
+type SagaConfig struct {
+	// StepTimeout is how long a step may go without a reply before its
+	// command is sent again.
+	StepTimeout time.Duration
+	// MaxAttempts bounds the commands sent for one step. When the last
+	// one goes unanswered the step counts as failed and is compensated
+	// along with the steps before it, since it may have taken effect.
+	MaxAttempts int
+	// ScanInterval is how often stalled sagas are looked for.
+	ScanInterval time.Duration
+	// BatchSize is the most stalled sagas resumed per scan.
+	BatchSize int
+}
+
+func DefaultSagaConfig() SagaConfig {
+	return SagaConfig{
+		StepTimeout:  30 * time.Second,
+		MaxAttempts:  3,
+		ScanInterval: 10 * time.Second,
+		BatchSize:    100,
+	}
+}
+
+// sagaStep is one step of the saga. A remote step publishes Command and
+// waits for a reply on OnSuccess or OnFailure; a local step runs Local
+// inline. Compensate, when set, undoes a step that succeeded once a later
+// step fails.
+type sagaStep struct {
+	Name       string
+	Command    func(o *domain.Order) domain.Event
+	OnSuccess  string
+	OnFailure  string
+	Local      func(ctx context.Context, o *domain.Order) error
+	Compensate func(o *domain.Order) domain.Event
+}
+
+// stepFailed is returned by a local step that cannot succeed, as opposed
+// to an error worth trying again.
+type stepFailed struct{ reason string }
+
+func (e stepFailed) Error() string { return e.reason }
+
+// sagaReply is the part of every reply the orchestrator needs.
+type sagaReply struct {
+	OrderID uint64 `json:"orderId"`
+	Reason  string `json:"reason"`
+}
+
+// SagaOrchestrator drives order fulfilment as a saga: reserve stock,
+// charge the payment, confirm the order. Each step's progress is saved
+// before its command is published, so a restart loses nothing; Run picks
+// up sagas whose reply is overdue. When a step fails the steps before it
+// are compensated in reverse order and the order fails.
+//
+// Commands and compensations are delivered at least once, so the services
+// receiving them must be idempotent.
+type SagaOrchestrator struct {
+	orders *OrderService
+	repo   repository.SagaRepository
+	pub    messaging.Publisher
+	cfg    SagaConfig
+	clock  infra.Clock
+	steps  []sagaStep
+
+	started   atomic.Int64
+	completed atomic.Int64
+	aborted   atomic.Int64
+	retried   atomic.Int64
+	timedOut  atomic.Int64
+}
+
+func NewSagaOrchestrator(orders *OrderService, repo repository.SagaRepository, pub messaging.Publisher, cfg SagaConfig, clock infra.Clock) *SagaOrchestrator {
+	if clock == nil {
+		clock = infra.RealClock()
+	}
+	def := DefaultSagaConfig()
+	if cfg.StepTimeout <= 0 {
+		cfg.StepTimeout = def.StepTimeout
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = def.MaxAttempts
+	}
+	if cfg.ScanInterval <= 0 {
+		cfg.ScanInterval = def.ScanInterval
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = def.BatchSize
+	}
+	o := &SagaOrchestrator{orders: orders, repo: repo, pub: pub, cfg: cfg, clock: clock}
+	o.steps = []sagaStep{
+		{
+			Name: SagaStepReserveStock,
+			Command: func(ord *domain.Order) domain.Event {
+				return domain.OrderCreatedEvent{OrderID: ord.ID, ProductId: ord.ProductId, TotalPrice: ord.TotalPrice, CreatedAt: ord.CreatedAt}
+			},
+			OnSuccess: domain.EventStockConfirmed,
+			OnFailure: domain.EventStockFailed,
+			Compensate: func(ord *domain.Order) domain.Event {
+				return domain.StockReleaseCommand{OrderID: ord.ID, ProductId: ord.ProductId}
+			},
+		},
+		o.chargeStep(),
+		{
+			Name:  SagaStepConfirm,
+			Local: o.confirm,
+		},
+	}
+	return o
+}
+
+// chargeStep asks the payment service for the money, or captures the
+// authorization taken at creation when the order service has a payment
+// provider. A capture needs no compensation of its own: an aborted saga
+// releases whatever the order holds.
+func (o *SagaOrchestrator) chargeStep() sagaStep {
+	if o.orders.payments != nil {
+		return sagaStep{Name: SagaStepChargePayment, Local: o.capture}
+	}
+	return sagaStep{
+		Name: SagaStepChargePayment,
+		Command: func(ord *domain.Order) domain.Event {
+			return domain.PaymentChargeCommand{OrderID: ord.ID, Amount: ord.TotalPrice}
+		},
+		OnSuccess: domain.EventPaymentCharged,
+		OnFailure: domain.EventPaymentFailed,
+		Compensate: func(ord *domain.Order) domain.Event {
+			return domain.PaymentRefundCommand{OrderID: ord.ID, Amount: ord.TotalPrice}
+		},
+	}
+}
+
+// Start begins the saga of a newly created order by sending its first
+// command.
+func (o *SagaOrchestrator) Start(ctx context.Context, order *domain.Order) error {
+	s := &domain.Saga{OrderID: order.ID, State: domain.SagaRunning, Version: 1}
+	o.started.Add(1)
+	return o.execute(ctx, s, order)
+}
+
+// RegisterHandlers subscribes to the replies of every step. It replaces
+// OrderService.RegisterEventHandlers when sagas are enabled.
+func (o *SagaOrchestrator) RegisterHandlers(sub messaging.Subscriber) {
+	for _, step := range o.steps {
+		for _, topic := range []string{step.OnSuccess, step.OnFailure} {
+			if topic == "" {
+				continue
+			}
+			sub.Subscribe(topic, messaging.AckOnSuccess(func(ctx context.Context, data json.RawMessage) error {
+				var r sagaReply
+				if err := json.Unmarshal(data, &r); err != nil {
+					return messaging.Permanent(fmt.Errorf("decode %s: %w", topic, err))
+				}
+				if r.OrderID == 0 {
+					return messaging.Permanent(fmt.Errorf("decode %s: orderId is missing", topic))
+				}
+				return o.HandleReply(ctx, topic, r.OrderID, r.Reason)
+			}))
+		}
+	}
+}
+
+// HandleReply applies a reply to the saga of an order. Replies the saga is
+// not waiting for, such as duplicates or ones arriving after a timeout,
+// are ignored.
+func (o *SagaOrchestrator) HandleReply(ctx context.Context, topic string, orderID uint64, reason string) error {
+	s, err := o.repo.FindByOrderID(orderID)
+	if err != nil {
+		return storageError(fmt.Errorf("load saga for order %d: %w", orderID, err))
+	}
+	if s == nil || s.State != domain.SagaRunning || s.Step >= len(o.steps) {
+		log.Printf("Saga for order %d: not waiting for a reply, ignoring %s", orderID, topic)
+		return nil
+	}
+	step := o.steps[s.Step]
+	if topic != step.OnSuccess && topic != step.OnFailure {
+		log.Printf("Saga for order %d: waiting on %s, ignoring %s", orderID, step.Name, topic)
+		return nil
+	}
+
+	order, err := o.loadOrder(s)
+	if err != nil {
+		return err
+	}
+	if topic == step.OnFailure {
+		return o.compensate(ctx, s, order, SagaActionFailed, reason, false)
+	}
+	s.Step++
+	s.Attempts = 0
+	if err := o.save(s, step.Name, SagaActionSucceeded, ""); err != nil {
+		return err
+	}
+	return o.execute(ctx, s, order)
+}
+
+// execute runs local steps until it reaches a remote one, whose command it
+// sends, or the end of the saga.
+func (o *SagaOrchestrator) execute(ctx context.Context, s *domain.Saga, order *domain.Order) error {
+	for s.Step < len(o.steps) {
+		step := o.steps[s.Step]
+		if step.Local == nil {
+			return o.send(ctx, s, order, SagaActionCommand)
+		}
+
+		err := step.Local(ctx, order)
+		var failed stepFailed
+		if errors.As(err, &failed) {
+			return o.compensate(ctx, s, order, SagaActionFailed, failed.reason, false)
+		}
+		if err != nil {
+			return err
+		}
+		s.Step++
+		s.Attempts = 0
+		if s.Step == len(o.steps) {
+			s.State = domain.SagaCompleted
+		}
+		if err := o.save(s, step.Name, SagaActionSucceeded, ""); err != nil {
+			return err
+		}
+	}
+	o.completed.Add(1)
+	return nil
+}
+
+// send publishes the current step's command. The attempt is saved first: if
+// the publish is lost, the scan sends the command again once the step
+// times out.
+func (o *SagaOrchestrator) send(ctx context.Context, s *domain.Saga, order *domain.Order, action string) error {
+	step := o.steps[s.Step]
+	s.Attempts++
+	if err := o.save(s, step.Name, action, fmt.Sprintf("attempt %d", s.Attempts)); err != nil {
+		return err
+	}
+	cmd := step.Command(order)
+	if err := o.pub.Publish(ctx, cmd.EventType(), cmd); err != nil {
+		log.Printf("Saga for order %d: failed to send %s: %v", order.ID, cmd.EventType(), err)
+	}
+	return nil
+}
+
+// compensate switches the saga to undoing the steps that succeeded. The
+// current step is undone too when it may have taken effect, i.e. when its
+// reply never came.
+func (o *SagaOrchestrator) compensate(ctx context.Context, s *domain.Saga, order *domain.Order, action, reason string, undoCurrent bool) error {
+	step := o.steps[s.Step]
+	s.State = domain.SagaCompensating
+	s.FailureReason = truncate(step.Name+": "+reason, 255)
+	s.Attempts = 0
+	if !undoCurrent {
+		s.Step--
+	}
+	if err := o.save(s, step.Name, action, reason); err != nil {
+		return err
+	}
+	return o.unwind(ctx, s, order)
+}
+
+// unwind sends the remaining compensations, newest step first, and then
+// fails the order and releases its payment. Each compensation is published
+// before it is recorded, so an interrupted unwind sends it again rather
+// than skipping it.
+func (o *SagaOrchestrator) unwind(ctx context.Context, s *domain.Saga, order *domain.Order) error {
+	for s.Step >= 0 {
+		step := o.steps[s.Step]
+		detail := "nothing to undo"
+		if step.Compensate != nil {
+			cmd := step.Compensate(order)
+			if err := o.pub.Publish(ctx, cmd.EventType(), cmd); err != nil {
+				return fmt.Errorf("compensate %s for order %d: %w", step.Name, order.ID, err)
+			}
+			detail = cmd.EventType()
+		}
+		s.Step--
+		if err := o.save(s, step.Name, SagaActionCompensate, detail); err != nil {
+			return err
+		}
+	}
+
+	// The order may have been cancelled meanwhile, which is fine
+	if _, err := o.orders.transition(ctx, order.ID, domain.StatusPending, domain.StatusFailed, s.FailureReason); err != nil {
+		return err
+	}
+	if err := o.orders.releasePayment(ctx, order); err != nil {
+		return err
+	}
+	s.State = domain.SagaAborted
+	if err := o.save(s, "", SagaActionAborted, s.FailureReason); err != nil {
+		return err
+	}
+	o.aborted.Add(1)
+	return nil
+}
+
+func (o *SagaOrchestrator) capture(ctx context.Context, order *domain.Order) error {
+	if !o.orders.paysThroughProvider(order) {
+		return nil
+	}
+	err := o.orders.capturePayment(ctx, order)
+	if errors.Is(err, ErrPaymentDeclined) {
+		return stepFailed{reason: "payment capture declined"}
+	}
+	return err
+}
+
+// confirm is the last step: the order becomes paid, or confirmed when it
+// was not paid through the provider. Finding it there already means an
+// earlier attempt got that far before being interrupted.
+func (o *SagaOrchestrator) confirm(ctx context.Context, order *domain.Order) error {
+	target := domain.StatusConfirmed
+	if o.orders.paysThroughProvider(order) {
+		target = domain.StatusPaid
+	}
+	updated, err := o.orders.transition(ctx, order.ID, domain.StatusPending, target, "")
+	if err != nil || updated != nil {
+		return err
+	}
+	current, err := o.orders.repo.FindByID(order.ID)
+	if err != nil {
+		return storageError(fmt.Errorf("load order %d: %w", order.ID, err))
+	}
+	if current == nil {
+		return stepFailed{reason: "order not found"}
+	}
+	if current.Status != target {
+		return stepFailed{reason: "order is " + string(current.Status)}
+	}
+	return nil
+}
+
+// Run resumes stalled sagas once per scan interval until ctx is done. The
+// first scan runs right away, picking up sagas a restart interrupted.
+func (o *SagaOrchestrator) Run(ctx context.Context) {
+	for {
+		if n, err := o.ResumeStalled(ctx); err != nil {
+			log.Printf("Saga scan failed: %v", err)
+		} else if n > 0 {
+			log.Printf("Resumed %d stalled sagas", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-o.clock.After(o.cfg.ScanInterval):
+		}
+	}
+}
+
+// ResumeStalled moves on up to BatchSize sagas that made no progress for
+// StepTimeout: an overdue command is sent again, or compensated once
+// MaxAttempts went unanswered, and an unfinished compensation continues.
+// It returns how many sagas it resumed.
+func (o *SagaOrchestrator) ResumeStalled(ctx context.Context) (int, error) {
+	sagas, err := o.repo.FindStalled(o.clock.Now().Add(-o.cfg.StepTimeout), o.cfg.BatchSize)
+	if err != nil {
+		return 0, storageError(fmt.Errorf("find stalled sagas: %w", err))
+	}
+	n := 0
+	for i := range sagas {
+		if err := o.resume(ctx, &sagas[i]); err != nil {
+			log.Printf("Saga for order %d: resume failed: %v", sagas[i].OrderID, err)
+			continue
+		}
+		n++
+	}
+	return n, nil
+}
+
+func (o *SagaOrchestrator) resume(ctx context.Context, s *domain.Saga) error {
+	order, err := o.loadOrder(s)
+	if err != nil {
+		return err
+	}
+	if s.State == domain.SagaCompensating {
+		return o.unwind(ctx, s, order)
+	}
+	if o.steps[s.Step].Local != nil || s.Attempts == 0 {
+		// Interrupted before the step started
+		return o.execute(ctx, s, order)
+	}
+	if s.Attempts >= o.cfg.MaxAttempts {
+		o.timedOut.Add(1)
+		return o.compensate(ctx, s, order, SagaActionTimedOut, fmt.Sprintf("no reply after %d attempts", s.Attempts), true)
+	}
+	o.retried.Add(1)
+	return o.send(ctx, s, order, SagaActionRetry)
+}
+
+func (o *SagaOrchestrator) loadOrder(s *domain.Saga) (*domain.Order, error) {
+	order, err := o.orders.repo.FindByID(s.OrderID)
+	if err != nil {
+		return nil, storageError(fmt.Errorf("load order %d: %w", s.OrderID, err))
+	}
+	if order == nil {
+		return nil, fmt.Errorf("saga %d: order %d not found", s.ID, s.OrderID)
+	}
+	return order, nil
+}
+
+// save writes s with a log entry, creating it on the first call.
+func (o *SagaOrchestrator) save(s *domain.Saga, step, action, detail string) error {
+	s.StepName = ""
+	if s.Step >= 0 && s.Step < len(o.steps) && !s.State.IsFinal() {
+		s.StepName = o.steps[s.Step].Name
+	}
+	s.UpdatedAt = o.clock.Now()
+	entry := &domain.SagaLogEntry{Step: step, Action: action, Detail: truncate(detail, 255), CreatedAt: s.UpdatedAt}
+
+	var err error
+	if s.ID == 0 {
+		s.CreatedAt = s.UpdatedAt
+		err = o.repo.Create(s, entry)
+	} else {
+		err = o.repo.Update(s, entry)
+	}
+	if err != nil {
+		return storageError(fmt.Errorf("save saga for order %d: %w", s.OrderID, err))
+	}
+	return nil
+}
+
+// SagaView is a saga as shown to operators: its state, the steps it runs
+// and everything it did so far.
+type SagaView struct {
+	domain.Saga
+	Steps []string              `json:"steps"`
+	Log   []domain.SagaLogEntry `json:"log"`
+}
+
+// Get returns the saga of an order with its log.
+func (o *SagaOrchestrator) Get(orderID uint64) (*SagaView, error) {
+	s, err := o.repo.FindByOrderID(orderID)
+	if err != nil {
+		return nil, storageError(err)
+	}
+	if s == nil {
+		return nil, ErrSagaNotFound
+	}
+	entries, err := o.repo.FindLog(s.ID)
+	if err != nil {
+		return nil, storageError(err)
+	}
+	view := &SagaView{Saga: *s, Log: entries}
+	if view.Log == nil {
+		view.Log = []domain.SagaLogEntry{}
+	}
+	for _, step := range o.steps {
+		view.Steps = append(view.Steps, step.Name)
+	}
+	return view, nil
+}
+
+func (o *SagaOrchestrator) GetStats() map[string]interface{} {
+	return map[string]interface{}{
+		"started":      o.started.Load(),
+		"completed":    o.completed.Load(),
+		"aborted":      o.aborted.Load(),
+		"retried":      o.retried.Load(),
+		"timed_out":    o.timedOut.Load(),
+		"step_timeout": o.cfg.StepTimeout.String(),
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
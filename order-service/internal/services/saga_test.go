@@ -0,0 +1,366 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"order-service/internal/domain"
+	"order-service/internal/infra"
+	"order-service/internal/infra/messaging"
+	"order-service/internal/mocks"
+	"order-service/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// memorySagaRepo keeps sagas keyed by order id.
+type memorySagaRepo struct {
+	mu        sync.Mutex
+	nextID    uint64
+	sagas     map[uint64]domain.Saga
+	log       []domain.SagaLogEntry
+	createErr error // returned by Create when set
+}
+
+func newMemorySagaRepo() *memorySagaRepo {
+	return &memorySagaRepo{sagas: map[uint64]domain.Saga{}}
+}
+
+func (r *memorySagaRepo) Create(s *domain.Saga, entry *domain.SagaLogEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.createErr != nil {
+		return r.createErr
+	}
+	if _, ok := r.sagas[s.OrderID]; ok {
+		return fmt.Errorf("duplicate saga for order %d", s.OrderID)
+	}
+	r.nextID++
+	s.ID = r.nextID
+	r.sagas[s.OrderID] = *s
+	r.appendLog(s, entry)
+	return nil
+}
+
+func (r *memorySagaRepo) FindByOrderID(orderID uint64) (*domain.Saga, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.sagas[orderID]
+	if !ok {
+		return nil, nil
+	}
+	return &s, nil
+}
+
+func (r *memorySagaRepo) Update(s *domain.Saga, entry *domain.SagaLogEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.sagas[s.OrderID].Version != s.Version {
+		return &repository.VersionConflictError{OrderID: s.OrderID, Expected: s.Version}
+	}
+	s.Version++
+	r.sagas[s.OrderID] = *s
+	r.appendLog(s, entry)
+	return nil
+}
+
+func (r *memorySagaRepo) appendLog(s *domain.Saga, entry *domain.SagaLogEntry) {
+	entry.SagaID = s.ID
+	entry.ID = uint64(len(r.log) + 1)
+	r.log = append(r.log, *entry)
+}
+
+func (r *memorySagaRepo) FindStalled(cutoff time.Time, limit int) ([]domain.Saga, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []domain.Saga
+	for _, s := range r.sagas {
+		if !s.State.IsFinal() && s.UpdatedAt.Before(cutoff) {
+			out = append(out, s)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].UpdatedAt.Before(out[j].UpdatedAt) })
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func (r *memorySagaRepo) FindLog(sagaID uint64) ([]domain.SagaLogEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []domain.SagaLogEntry
+	for _, e := range r.log {
+		if e.SagaID == sagaID {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+// fakePaymentService answers payment.charge with payment.charged, or with
+// payment.failed when the amount is above the limit.
+func fakePaymentService(b messaging.Broker, limit int64) {
+	b.Subscribe(domain.CommandPaymentCharge, messaging.AckOnSuccess(func(ctx context.Context, data json.RawMessage) error {
+		var cmd domain.PaymentChargeCommand
+		if err := json.Unmarshal(data, &cmd); err != nil {
+			return messaging.Permanent(err)
+		}
+		if cmd.Amount > limit {
+			return b.Publish(ctx, domain.EventPaymentFailed, domain.PaymentFailedEvent{OrderID: cmd.OrderID, Reason: "card declined"})
+		}
+		return b.Publish(ctx, domain.EventPaymentCharged, domain.PaymentChargedEvent{OrderID: cmd.OrderID})
+	}))
+}
+
+type sagaFlow struct {
+	orders    *OrderService
+	sagas     *SagaOrchestrator
+	sagaRepo  *memorySagaRepo
+	orderRepo *memoryOrderRepo
+	broker    *messaging.MemoryBroker
+	clock     *stepClock
+
+	mu            sync.Mutex
+	compensations []string
+}
+
+// newSagaFlow wires orders through the saga with a product service that
+// has stock for product 1 only. withPayments adds a payment service that
+// declines amounts above 2000.
+func newSagaFlow(t *testing.T, withPayments bool) *sagaFlow {
+	t.Helper()
+	return newSagaFlowWith(t, withPayments, nil)
+}
+
+// newSagaFlowWith is newSagaFlow with orders paid through pay, if not nil.
+func newSagaFlowWith(t *testing.T, withPayments bool, pay infra.PaymentProvider) *sagaFlow {
+	t.Helper()
+	f := &sagaFlow{
+		broker:    messaging.NewMemoryBroker(messaging.DefaultMemoryBrokerConfig()),
+		sagaRepo:  newMemorySagaRepo(),
+		orderRepo: newMemoryOrderRepo(),
+		clock:     &stepClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	t.Cleanup(f.broker.Close)
+
+	products := new(mocks.MockProductClient)
+	products.On("GetProductById", mock.Anything, mock.Anything).Return(&infra.ProductInfo{ID: 1, Qty: 5}, nil)
+	f.orders = NewOrderService(f.orderRepo, products, f.broker)
+	if pay != nil {
+		f.orders.SetPaymentProvider(pay)
+	}
+	f.sagas = NewSagaOrchestrator(f.orders, f.sagaRepo, f.broker, DefaultSagaConfig(), f.clock)
+	f.orders.SetSagaOrchestrator(f.sagas)
+	f.sagas.RegisterHandlers(f.broker)
+
+	fakeProductService(f.broker, map[uint64]int64{1: 3})
+	if withPayments {
+		fakePaymentService(f.broker, 2000)
+	}
+	// One subscription, so compensations are recorded in the order sent
+	f.broker.Subscribe("#", func(_ context.Context, d messaging.Delivery) {
+		if topic := d.Message().Topic; topic == domain.CommandStockRelease || topic == domain.CommandPaymentRefund {
+			f.mu.Lock()
+			f.compensations = append(f.compensations, topic)
+			f.mu.Unlock()
+		}
+		d.Ack()
+	})
+	return f
+}
+
+func (f *sagaFlow) settle(t *testing.T) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	require.NoError(t, f.broker.WaitIdle(ctx))
+}
+
+func (f *sagaFlow) createOrder(t *testing.T, productID uint64, total int64) uint64 {
+	t.Helper()
+	o, err := f.orders.CreateOrder(context.Background(), productID, total)
+	require.NoError(t, err)
+	f.settle(t)
+	return o.ID
+}
+
+func (f *sagaFlow) saga(t *testing.T, orderID uint64) *SagaView {
+	t.Helper()
+	v, err := f.sagas.Get(orderID)
+	require.NoError(t, err)
+	return v
+}
+
+func (f *sagaFlow) orderStatus(orderID uint64) domain.OrderStatus {
+	o, _ := f.orderRepo.FindByID(orderID)
+	return o.Status
+}
+
+func (f *sagaFlow) compensated() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.compensations...)
+}
+
+func logActions(v *SagaView) []string {
+	var out []string
+	for _, e := range v.Log {
+		out = append(out, e.Step+":"+e.Action)
+	}
+	return out
+}
+
+func TestSaga_ReservesChargesAndConfirms(t *testing.T) {
+	f := newSagaFlow(t, true)
+
+	id := f.createOrder(t, 1, 1000)
+
+	assert.Equal(t, domain.StatusConfirmed, f.orderStatus(id))
+	v := f.saga(t, id)
+	assert.Equal(t, domain.SagaCompleted, v.State)
+	assert.Empty(t, v.StepName)
+	assert.Equal(t, []string{SagaStepReserveStock, SagaStepChargePayment, SagaStepConfirm}, v.Steps)
+	assert.Equal(t, []string{
+		"reserve_stock:command", "reserve_stock:succeeded",
+		"charge_payment:command", "charge_payment:succeeded",
+		"confirm:succeeded",
+	}, logActions(v))
+	assert.Empty(t, f.compensated())
+
+	// A late duplicate reply changes nothing
+	require.NoError(t, f.broker.Publish(context.Background(), domain.EventPaymentFailed, domain.PaymentFailedEvent{OrderID: id}))
+	f.settle(t)
+	assert.Equal(t, domain.SagaCompleted, f.saga(t, id).State)
+	assert.Equal(t, domain.StatusConfirmed, f.orderStatus(id))
+}
+
+func TestSaga_DeclinedPaymentReleasesStock(t *testing.T) {
+	f := newSagaFlow(t, true)
+
+	id := f.createOrder(t, 1, 5000)
+
+	assert.Equal(t, domain.StatusFailed, f.orderStatus(id))
+	assert.Equal(t, []string{domain.CommandStockRelease}, f.compensated())
+	v := f.saga(t, id)
+	assert.Equal(t, domain.SagaAborted, v.State)
+	assert.Equal(t, "charge_payment: card declined", v.FailureReason)
+	assert.Equal(t, []string{
+		"reserve_stock:command", "reserve_stock:succeeded",
+		"charge_payment:command", "charge_payment:failed",
+		"reserve_stock:compensate",
+		":aborted",
+	}, logActions(v))
+	require.Len(t, f.orderRepo.history, 1)
+	assert.Equal(t, "charge_payment: card declined", f.orderRepo.history[0].Reason)
+}
+
+func TestSaga_OutOfStockHasNothingToCompensate(t *testing.T) {
+	f := newSagaFlow(t, true)
+
+	id := f.createOrder(t, 2, 1000)
+
+	assert.Equal(t, domain.StatusFailed, f.orderStatus(id))
+	assert.Empty(t, f.compensated())
+	assert.Equal(t, "reserve_stock: out of stock", f.saga(t, id).FailureReason)
+}
+
+func TestSaga_ResendsUnansweredCommandsThenCompensates(t *testing.T) {
+	f := newSagaFlow(t, false)
+	var charges int
+	var mu sync.Mutex
+	f.broker.Subscribe(domain.CommandPaymentCharge, func(_ context.Context, d messaging.Delivery) {
+		mu.Lock()
+		charges++
+		mu.Unlock()
+		d.Ack()
+	})
+
+	id := f.createOrder(t, 1, 1000)
+	require.Equal(t, SagaStepChargePayment, f.saga(t, id).StepName)
+
+	// A restarted instance picks the saga up from storage
+	cfg := DefaultSagaConfig()
+	restarted := NewSagaOrchestrator(f.orders, f.sagaRepo, f.broker, cfg, f.clock)
+
+	n, err := restarted.ResumeStalled(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, n, "nothing is overdue yet")
+
+	for attempt := 2; attempt <= cfg.MaxAttempts; attempt++ {
+		f.clock.Advance(cfg.StepTimeout + time.Second)
+		n, err = restarted.ResumeStalled(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, 1, n)
+		f.settle(t)
+		assert.Equal(t, attempt, f.saga(t, id).Attempts)
+	}
+	mu.Lock()
+	assert.Equal(t, cfg.MaxAttempts, charges)
+	mu.Unlock()
+
+	f.clock.Advance(cfg.StepTimeout + time.Second)
+	_, err = restarted.ResumeStalled(context.Background())
+	require.NoError(t, err)
+	f.settle(t)
+
+	// The charge may have gone through, so it is refunded as well
+	assert.Equal(t, []string{domain.CommandPaymentRefund, domain.CommandStockRelease}, f.compensated())
+	assert.Equal(t, domain.StatusFailed, f.orderStatus(id))
+	v := f.saga(t, id)
+	assert.Equal(t, domain.SagaAborted, v.State)
+	assert.Equal(t, "charge_payment: no reply after 3 attempts", v.FailureReason)
+	stats := restarted.GetStats()
+	assert.Equal(t, int64(2), stats["retried"])
+	assert.Equal(t, int64(1), stats["timed_out"])
+}
+
+func TestSaga_CancelledOrderIsCompensatedInsteadOfConfirmed(t *testing.T) {
+	f := newSagaFlow(t, false)
+
+	id := f.createOrder(t, 1, 1000)
+	_, err := f.orders.CancelOrder(context.Background(), id)
+	require.NoError(t, err)
+
+	require.NoError(t, f.broker.Publish(context.Background(), domain.EventPaymentCharged, domain.PaymentChargedEvent{OrderID: id}))
+	f.settle(t)
+
+	assert.Equal(t, domain.StatusCancelled, f.orderStatus(id))
+	assert.Equal(t, []string{domain.CommandPaymentRefund, domain.CommandStockRelease}, f.compensated())
+	v := f.saga(t, id)
+	assert.Equal(t, domain.SagaAborted, v.State)
+	assert.Equal(t, "confirm: order is cancelled", v.FailureReason)
+}
+
+func TestSaga_OrderFailsWhenSagaCannotStart(t *testing.T) {
+	pay := infra.NewFakePaymentProvider()
+	f := newSagaFlowWith(t, false, pay)
+	f.sagaRepo.createErr = errors.New("connection refused")
+
+	_, err := f.orders.CreateOrder(context.Background(), 1, 1000)
+	require.Error(t, err)
+	assert.Equal(t, KindUnavailable, KindOf(err))
+	f.settle(t)
+
+	require.Len(t, f.orderRepo.orders, 1)
+	for id, o := range f.orderRepo.orders {
+		assert.Equal(t, domain.StatusFailed, o.Status)
+		auth, _ := pay.Authorization(o.PaymentID)
+		assert.True(t, auth.Voided, "order %d still holds its payment", id)
+	}
+	assert.Empty(t, f.compensated())
+}
+
+func TestSaga_GetWithoutSaga(t *testing.T) {
+	f := newSagaFlow(t, false)
+	_, err := f.sagas.Get(42)
+	assert.ErrorIs(t, err, ErrSagaNotFound)
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"order-service/internal/domain"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const statusChannel = "orders:status"
+
+// StatusBroadcaster fans order status changes out to local subscribers.
+// When Redis is attached, changes are published to a Redis channel and
+// every replica delivers what it receives from that channel, so a client
+// connected to any replica sees transitions processed by another.
+type StatusBroadcaster struct {
+	mu   sync.RWMutex
+	subs map[uint64]map[chan domain.OrderStatusChange]struct{}
+
+	redisClient *redis.Client
+}
+
+func NewStatusBroadcaster() *StatusBroadcaster {
+	return &StatusBroadcaster{
+		subs: make(map[uint64]map[chan domain.OrderStatusChange]struct{}),
+	}
+}
+
+// Subscribe registers interest in one order. The returned function must be
+// called to release the subscription.
+func (b *StatusBroadcaster) Subscribe(orderId uint64) (<-chan domain.OrderStatusChange, func()) {
+	ch := make(chan domain.OrderStatusChange, 8)
+
+	b.mu.Lock()
+	if b.subs[orderId] == nil {
+		b.subs[orderId] = make(map[chan domain.OrderStatusChange]struct{})
+	}
+	b.subs[orderId][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs[orderId], ch)
+			if len(b.subs[orderId]) == 0 {
+				delete(b.subs, orderId)
+			}
+			b.mu.Unlock()
+		})
+	}
+}
+
+// Publish announces a status change to every replica, falling back to local
+// delivery when Redis is not attached or unavailable.
+func (b *StatusBroadcaster) Publish(ctx context.Context, change domain.OrderStatusChange) {
+	b.mu.RLock()
+	client := b.redisClient
+	b.mu.RUnlock()
+
+	if client != nil {
+		data, err := json.Marshal(change)
+		if err == nil {
+			ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+			err = client.Publish(ctx, statusChannel, data).Err()
+			cancel()
+			if err == nil {
+				return
+			}
+		}
+		log.Printf("Status fan-out via Redis failed, delivering locally: %v", err)
+	}
+	b.deliver(change)
+}
+
+// deliver hands the change to local subscribers without blocking; a slow
+// subscriber misses updates rather than stalling the publisher.
+func (b *StatusBroadcaster) deliver(change domain.OrderStatusChange) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for ch := range b.subs[change.OrderID] {
+		select {
+		case ch <- change:
+		default:
+		}
+	}
+}
+
+// UseRedis subscribes to the shared status channel and starts relaying its
+// messages to local subscribers until ctx is done.
+func (b *StatusBroadcaster) UseRedis(ctx context.Context, client *redis.Client) error {
+	pubsub := client.Subscribe(ctx, statusChannel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return err
+	}
+
+	b.mu.Lock()
+	b.redisClient = client
+	b.mu.Unlock()
+
+	go func() {
+		defer pubsub.Close()
+		msgs := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				b.mu.Lock()
+				b.redisClient = nil
+				b.mu.Unlock()
+				return
+			case msg, ok := <-msgs:
+				if !ok {
+					return
+				}
+				var change domain.OrderStatusChange
+				if err := json.Unmarshal([]byte(msg.Payload), &change); err != nil {
+					log.Printf("Ignoring malformed status message: %v", err)
+					continue
+				}
+				b.deliver(change)
+			}
+		}
+	}()
+	return nil
+}
+
+func (b *StatusBroadcaster) SubscriberCount() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	n := 0
+	for _, subs := range b.subs {
+		n += len(subs)
+	}
+	return n
+}
@@ -0,0 +1,670 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"order-service/internal/auth"
+	"order-service/internal/domain"
+	"order-service/internal/infra"
+	"order-service/internal/infra/messaging"
+	"order-service/internal/repository"
+)
+
+var (
+	ErrWebhookNotFound         = &Error{Kind: KindNotFound, Code: "WEBHOOK_NOT_FOUND", Message: "webhook endpoint not found"}
+	ErrWebhookDeliveryNotFound = &Error{Kind: KindNotFound, Code: "WEBHOOK_DELIVERY_NOT_FOUND", Message: "webhook delivery not found"}
+	ErrInvalidWebhook          = &Error{Kind: KindValidation, Code: "INVALID_WEBHOOK", Message: "invalid webhook endpoint"}
+)
+
+// Headers sent with every webhook delivery.
+const (
+	WebhookIDHeader        = "X-Webhook-Id"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+const (
+	minWebhookSecretLength = 16
+	maxWebhookErrorLength  = 255
+)
+
+// webhookStatuses are the statuses a webhook can be subscribed to.
+var webhookStatuses = []domain.OrderStatus{
+	domain.StatusConfirmed,
+	domain.StatusPaid,
+	domain.StatusFailed,
+	domain.StatusCancelled,
+	domain.StatusPartiallyRefunded,
+	domain.StatusRefunded,
+}
+
+// WebhookConfig controls delivery. Attempt n of a delivery that keeps
+// failing is retried after BaseDelay*2^(n-1), capped at MaxDelay, until
+// MaxAttempts is reached. An endpoint whose last DisableAfter attempts all
+// failed, across its deliveries, is disabled.
+type WebhookConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	Concurrency  int
+	Timeout      time.Duration
+	MaxAttempts  int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	DisableAfter int
+	// AllowPrivateNetworks lets endpoints point at loopback, private and
+	// link-local addresses, e.g. for local development.
+	AllowPrivateNetworks bool
+}
+
+func DefaultWebhookConfig() WebhookConfig {
+	return WebhookConfig{
+		PollInterval: time.Second,
+		BatchSize:    50,
+		Concurrency:  8,
+		Timeout:      10 * time.Second,
+		MaxAttempts:  10,
+		BaseDelay:    30 * time.Second,
+		MaxDelay:     6 * time.Hour,
+		DisableAfter: 20,
+	}
+}
+
+func (c WebhookConfig) delay(attempt int) time.Duration {
+	d := c.BaseDelay
+	for i := 1; i < attempt && d < c.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > c.MaxDelay {
+		d = c.MaxDelay
+	}
+	return d
+}
+
+// lease is how long a claimed batch stays with this replica. At most
+// Concurrency deliveries are sent at once, so the last of a full batch
+// may only finish after ceil(BatchSize/Concurrency) timeouts; one more
+// Timeout leaves room to record the outcomes.
+func (c WebhookConfig) lease() time.Duration {
+	rounds := (c.BatchSize + c.Concurrency - 1) / c.Concurrency
+	return time.Duration(rounds+1) * c.Timeout
+}
+
+// WebhookEndpointInput is what a caller sets on an endpoint. On update,
+// nil fields are left unchanged.
+type WebhookEndpointInput struct {
+	URL        *string
+	EventTypes []string
+	// Secret signs deliveries; one is generated when an endpoint is
+	// created without it.
+	Secret *string
+	// CustomerID restricts the endpoint to one customer's orders. Callers
+	// restricted to a customer always get their own.
+	CustomerID *string
+	Enabled    *bool
+}
+
+// WebhookEndpointCreated is a new endpoint together with its secret, which
+// is not shown again.
+type WebhookEndpointCreated struct {
+	*domain.WebhookEndpoint
+	Secret string `json:"secret"`
+}
+
+// WebhookDeliveryView is a delivery with its attempt log.
+type WebhookDeliveryView struct {
+	domain.WebhookDelivery
+	Log []domain.WebhookAttempt `json:"log"`
+}
+
+// WebhookService manages partner webhook endpoints and delivers order
+// status changes to them. Notify queues a delivery per subscribed endpoint
+// in the database; Run sends queued deliveries, signed with the endpoint's
+// secret, and retries failures with exponential backoff.
+type WebhookService struct {
+	repo   repository.WebhookRepository
+	client *http.Client
+	cfg    WebhookConfig
+	clock  infra.Clock
+	wake   chan struct{}
+
+	queued    atomic.Int64
+	delivered atomic.Int64
+	failed    atomic.Int64 // attempts that did not get a 2xx
+	abandoned atomic.Int64 // deliveries that ran out of attempts
+	disabled  atomic.Int64
+}
+
+func NewWebhookService(repo repository.WebhookRepository, client *http.Client, cfg WebhookConfig, clock infra.Clock) *WebhookService {
+	if clock == nil {
+		clock = infra.RealClock()
+	}
+	if client == nil {
+		client = newWebhookClient(cfg.AllowPrivateNetworks)
+	}
+	def := DefaultWebhookConfig()
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = def.PollInterval
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = def.BatchSize
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = def.Concurrency
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = def.Timeout
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = def.MaxAttempts
+	}
+	if cfg.BaseDelay <= 0 {
+		cfg.BaseDelay = def.BaseDelay
+	}
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = def.MaxDelay
+	}
+	if cfg.DisableAfter <= 0 {
+		cfg.DisableAfter = def.DisableAfter
+	}
+	return &WebhookService{repo: repo, client: client, cfg: cfg, clock: clock, wake: make(chan struct{}, 1)}
+}
+
+// SignWebhook returns the signature header value of a delivery body sent
+// at timestamp (Unix seconds): "sha256=" and the hex HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the endpoint's secret. Receivers should
+// compute it the same way, compare in constant time and reject stale
+// timestamps.
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// CreateEndpoint registers a webhook endpoint for the caller.
+func (w *WebhookService) CreateEndpoint(ctx context.Context, in WebhookEndpointInput) (*WebhookEndpointCreated, error) {
+	now := w.clock.Now()
+	e := &domain.WebhookEndpoint{Enabled: true, CreatedBy: actorFrom(ctx), CreatedAt: now, UpdatedAt: now}
+	if in.URL == nil || len(in.EventTypes) == 0 {
+		return nil, fmt.Errorf("%w: url and eventTypes are required", ErrInvalidWebhook)
+	}
+	if in.Secret == nil {
+		secret := generateWebhookSecret()
+		in.Secret = &secret
+	}
+	if err := w.applyWebhookInput(ctx, e, in); err != nil {
+		return nil, err
+	}
+	if err := w.repo.CreateEndpoint(e); err != nil {
+		return nil, storageError(err)
+	}
+	return &WebhookEndpointCreated{WebhookEndpoint: e, Secret: e.Secret}, nil
+}
+
+// UpdateEndpoint changes an endpoint the caller may see. Enabling an
+// endpoint clears its failure count, and its queued deliveries resume.
+func (w *WebhookService) UpdateEndpoint(ctx context.Context, id uint64, in WebhookEndpointInput) (*domain.WebhookEndpoint, error) {
+	e, err := w.GetEndpoint(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	wasEnabled := e.Enabled
+	if err := w.applyWebhookInput(ctx, e, in); err != nil {
+		return nil, err
+	}
+	if e.Enabled && !wasEnabled {
+		e.ConsecutiveFailures = 0
+		e.DisabledAt = nil
+		e.DisabledReason = ""
+	}
+	e.UpdatedAt = w.clock.Now()
+	if err := w.repo.UpdateEndpoint(e); err != nil {
+		return nil, storageError(err)
+	}
+	if e.Enabled && !wasEnabled {
+		w.signal()
+	}
+	return e, nil
+}
+
+// applyWebhookInput validates in and copies its set fields onto e.
+func (w *WebhookService) applyWebhookInput(ctx context.Context, e *domain.WebhookEndpoint, in WebhookEndpointInput) error {
+	if in.URL != nil {
+		u, err := url.Parse(*in.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidWebhook)
+		}
+		if !w.cfg.AllowPrivateNetworks && internalWebhookHost(u.Hostname()) {
+			return fmt.Errorf("%w: url must not point at a loopback, private or link-local address", ErrInvalidWebhook)
+		}
+		e.URL = u.String()
+	}
+	if in.EventTypes != nil {
+		for _, t := range in.EventTypes {
+			if !validWebhookEvent(t) {
+				return fmt.Errorf("%w: unknown event type %q", ErrInvalidWebhook, t)
+			}
+		}
+		e.EventTypes = in.EventTypes
+	}
+	if in.Secret != nil {
+		if len(*in.Secret) < minWebhookSecretLength {
+			return fmt.Errorf("%w: secret must be at least %d characters", ErrInvalidWebhook, minWebhookSecretLength)
+		}
+		e.Secret = *in.Secret
+	}
+	scope, ok, err := webhookScope(ctx)
+	if err != nil {
+		return err
+	}
+	if ok {
+		if in.CustomerID != nil && *in.CustomerID != scope {
+			return fmt.Errorf("%w: cannot subscribe to another customer's orders", ErrForbidden)
+		}
+		e.CustomerID = scope
+	} else if in.CustomerID != nil {
+		e.CustomerID = *in.CustomerID
+	}
+	if in.Enabled != nil {
+		e.Enabled = *in.Enabled
+	}
+	return nil
+}
+
+// webhookScope returns the customer the caller's endpoints are limited
+// to. Anonymous callers may not manage webhooks at all, as their empty
+// scope would reach the endpoints that hear about every order.
+func webhookScope(ctx context.Context) (string, bool, error) {
+	if auth.IsAnonymous(ctx) {
+		return "", false, ErrUnauthenticated
+	}
+	scope, ok := auth.CustomerScope(ctx)
+	return scope, ok, nil
+}
+
+func validWebhookEvent(t string) bool {
+	if t == domain.WebhookEventAll {
+		return true
+	}
+	for _, s := range webhookStatuses {
+		if t == domain.WebhookEventType(s) {
+			return true
+		}
+	}
+	return false
+}
+
+func generateWebhookSecret() string {
+	var b [24]byte
+	rand.Read(b[:]) // cannot fail since Go 1.24
+	return "whsec_" + hex.EncodeToString(b[:])
+}
+
+// GetEndpoint returns an endpoint the caller may see. Another customer's
+// endpoint is reported as missing.
+func (w *WebhookService) GetEndpoint(ctx context.Context, id uint64) (*domain.WebhookEndpoint, error) {
+	scope, scoped, err := webhookScope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	e, err := w.repo.FindEndpoint(id)
+	if err != nil {
+		return nil, storageError(err)
+	}
+	if e == nil || (scoped && e.CustomerID != scope) {
+		return nil, ErrWebhookNotFound
+	}
+	return e, nil
+}
+
+// ListEndpoints returns the endpoints the caller may see.
+func (w *WebhookService) ListEndpoints(ctx context.Context) ([]domain.WebhookEndpoint, error) {
+	scope, _, err := webhookScope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	endpoints, err := w.repo.ListEndpoints(scope)
+	if err != nil {
+		return nil, storageError(err)
+	}
+	if endpoints == nil {
+		endpoints = []domain.WebhookEndpoint{}
+	}
+	return endpoints, nil
+}
+
+// DeleteEndpoint removes an endpoint with its queued deliveries and log.
+func (w *WebhookService) DeleteEndpoint(ctx context.Context, id uint64) error {
+	if _, err := w.GetEndpoint(ctx, id); err != nil {
+		return err
+	}
+	if err := w.repo.DeleteEndpoint(id); err != nil {
+		return storageError(err)
+	}
+	return nil
+}
+
+// ListDeliveries returns up to limit deliveries of an endpoint, newest
+// first, starting below beforeID when it is not zero.
+func (w *WebhookService) ListDeliveries(ctx context.Context, endpointID uint64, status domain.WebhookDeliveryStatus, beforeID uint64, limit int) ([]domain.WebhookDelivery, error) {
+	if _, err := w.GetEndpoint(ctx, endpointID); err != nil {
+		return nil, err
+	}
+	switch status {
+	case "", domain.WebhookPending, domain.WebhookDelivered, domain.WebhookFailed:
+	default:
+		return nil, fmt.Errorf("%w: unknown delivery status %q", ErrInvalidWebhook, status)
+	}
+	if limit <= 0 || limit > MaxPageSize {
+		limit = DefaultPageSize
+	}
+	deliveries, err := w.repo.ListDeliveries(endpointID, status, beforeID, limit)
+	if err != nil {
+		return nil, storageError(err)
+	}
+	if deliveries == nil {
+		deliveries = []domain.WebhookDelivery{}
+	}
+	return deliveries, nil
+}
+
+// GetDelivery returns a delivery of an endpoint with its attempt log.
+func (w *WebhookService) GetDelivery(ctx context.Context, endpointID, deliveryID uint64) (*WebhookDeliveryView, error) {
+	if _, err := w.GetEndpoint(ctx, endpointID); err != nil {
+		return nil, err
+	}
+	d, err := w.repo.FindDelivery(deliveryID)
+	if err != nil {
+		return nil, storageError(err)
+	}
+	if d == nil || d.EndpointID != endpointID {
+		return nil, ErrWebhookDeliveryNotFound
+	}
+	attempts, err := w.repo.FindAttempts(deliveryID)
+	if err != nil {
+		return nil, storageError(err)
+	}
+	if attempts == nil {
+		attempts = []domain.WebhookAttempt{}
+	}
+	return &WebhookDeliveryView{WebhookDelivery: *d, Log: attempts}, nil
+}
+
+// Notify queues the status change of o for every enabled endpoint
+// subscribed to it. It is called after the change was stored, and queuing
+// is a separate write: if it fails, or the process stops in between, the
+// change is not delivered. Failures are logged.
+func (w *WebhookService) Notify(o *domain.Order, change domain.OrderStatusChange) {
+	eventType := domain.WebhookEventType(change.To)
+	endpoints, err := w.repo.FindSubscribers(o.CustomerID)
+	if err != nil {
+		log.Printf("Failed to find webhooks for order %d: %v", o.ID, err)
+		return
+	}
+
+	payload := domain.WebhookPayload{
+		ID:         messaging.NewID(),
+		Type:       eventType,
+		OccurredAt: change.OccurredAt,
+		Data:       domain.WebhookStatusData{Order: domain.NewWebhookOrder(o), From: change.From, Reason: change.Reason},
+	}
+	var body []byte
+	var deliveries []*domain.WebhookDelivery
+	now := w.clock.Now()
+	for i := range endpoints {
+		if !endpoints[i].Subscribes(eventType) {
+			continue
+		}
+		if body == nil {
+			if body, err = json.Marshal(payload); err != nil {
+				log.Printf("Failed to encode webhook for order %d: %v", o.ID, err)
+				return
+			}
+		}
+		deliveries = append(deliveries, &domain.WebhookDelivery{
+			EndpointID:    endpoints[i].ID,
+			EventID:       payload.ID,
+			EventType:     eventType,
+			OrderID:       o.ID,
+			Payload:       string(body),
+			Status:        domain.WebhookPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		})
+	}
+	if len(deliveries) == 0 {
+		return
+	}
+	if err := w.repo.Enqueue(deliveries); err != nil {
+		log.Printf("Failed to queue %s webhooks for order %d: %v", eventType, o.ID, err)
+		return
+	}
+	w.queued.Add(int64(len(deliveries)))
+	w.signal()
+}
+
+func (w *WebhookService) signal() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run sends due deliveries until ctx is done. It polls every
+// PollInterval, right away after a full batch, and when Notify queues
+// something.
+func (w *WebhookService) Run(ctx context.Context) {
+	for {
+		n, err := w.DeliverDue(ctx)
+		if err != nil {
+			log.Printf("Webhook delivery failed: %v", err)
+		}
+		if n == w.cfg.BatchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.wake:
+		case <-w.clock.After(w.cfg.PollInterval):
+		}
+	}
+}
+
+// DeliverDue claims one batch of due deliveries, sends them and returns
+// how many were claimed.
+func (w *WebhookService) DeliverDue(ctx context.Context) (int, error) {
+	now := w.clock.Now()
+	// Another replica only picks a delivery up again if this one died
+	// before recording its attempt
+	due, err := w.repo.ClaimDue(now, now.Add(w.cfg.lease()), w.cfg.BatchSize)
+	if err != nil || len(due) == 0 {
+		return 0, err
+	}
+
+	endpoints := map[uint64]*domain.WebhookEndpoint{}
+	for _, d := range due {
+		if _, ok := endpoints[d.EndpointID]; ok {
+			continue
+		}
+		e, err := w.repo.FindEndpoint(d.EndpointID)
+		if err != nil {
+			return len(due), err
+		}
+		endpoints[d.EndpointID] = e
+	}
+
+	sem := make(chan struct{}, w.cfg.Concurrency)
+	var wg sync.WaitGroup
+	for i := range due {
+		e := endpoints[due[i].EndpointID]
+		if e == nil {
+			continue // deleted since it was claimed
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(d *domain.WebhookDelivery) {
+			defer func() { <-sem; wg.Done() }()
+			w.attempt(ctx, e, d)
+		}(&due[i])
+	}
+	wg.Wait()
+	return len(due), nil
+}
+
+// attempt sends d once and records the outcome, scheduling a retry or
+// giving up, and disables e if it has now failed too often in a row.
+func (w *WebhookService) attempt(ctx context.Context, e *domain.WebhookEndpoint, d *domain.WebhookDelivery) {
+	start := w.clock.Now()
+	code, sendErr := w.send(ctx, e, d, start)
+	now := w.clock.Now()
+
+	d.Attempts++
+	d.UpdatedAt = now
+	d.LastStatusCode = code
+	a := &domain.WebhookAttempt{Attempt: d.Attempts, StatusCode: code, DurationMs: now.Sub(start).Milliseconds(), CreatedAt: now}
+	succeeded := sendErr == nil
+	switch {
+	case succeeded:
+		d.Status = domain.WebhookDelivered
+		d.DeliveredAt = &now
+		d.LastError = ""
+		w.delivered.Add(1)
+	case d.Attempts >= w.cfg.MaxAttempts:
+		d.Status = domain.WebhookFailed
+		w.abandoned.Add(1)
+	default:
+		d.NextAttemptAt = now.Add(w.cfg.delay(d.Attempts))
+	}
+	if !succeeded {
+		d.LastError = truncate(sendErr.Error(), maxWebhookErrorLength)
+		a.Error = d.LastError
+		w.failed.Add(1)
+	}
+
+	failures, err := w.repo.RecordAttempt(d, a, succeeded)
+	if err != nil {
+		// The lease runs out and the delivery is attempted again
+		log.Printf("Failed to record webhook delivery %d: %v", d.ID, err)
+		return
+	}
+	if failures >= w.cfg.DisableAfter {
+		reason := fmt.Sprintf("%d consecutive failed deliveries", failures)
+		if err := w.repo.DisableEndpoint(e.ID, now, reason); err != nil {
+			log.Printf("Failed to disable webhook endpoint %d: %v", e.ID, err)
+			return
+		}
+		w.disabled.Add(1)
+		log.Printf("Disabled webhook endpoint %d (%s): %s", e.ID, e.URL, reason)
+	}
+}
+
+// internalWebhookHost reports whether host is localhost or an IP literal
+// that blockedWebhookIP rejects. Other names are checked when dialled.
+func internalWebhookHost(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && blockedWebhookIP(ip)
+}
+
+// blockedWebhookIP reports whether ip is inside this network rather than
+// a partner's: loopback, private, link-local (which includes cloud
+// metadata endpoints) or unspecified.
+func blockedWebhookIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsUnspecified()
+}
+
+// newWebhookClient returns the client deliveries are sent with. Unless
+// allowPrivate is set it refuses to connect to a blocked address, which
+// is checked after DNS resolution, so a hostname that resolves inside the
+// network, or a redirect to one, fails as well. Proxies are not used, as
+// the address dialled would then be the proxy's.
+func newWebhookClient(allowPrivate bool) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if allowPrivate {
+		return &http.Client{Transport: transport}
+	}
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || blockedWebhookIP(ip) {
+				return fmt.Errorf("webhook destination %s is not allowed", host)
+			}
+			return nil
+		},
+	}
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Transport: transport}
+}
+
+// send POSTs d to e and returns the response status. Anything but a 2xx
+// is an error.
+func (w *WebhookService) send(ctx context.Context, e *domain.WebhookEndpoint, d *domain.WebhookDelivery, at time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, w.cfg.Timeout)
+	defer cancel()
+
+	body := []byte(d.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	ts := at.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "order-service-webhooks")
+	req.Header.Set(WebhookIDHeader, d.EventID)
+	req.Header.Set(WebhookEventHeader, d.EventType)
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(ts, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(e.Secret, ts, body))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func (w *WebhookService) GetStats() map[string]interface{} {
+	return map[string]interface{}{
+		"queued":          w.queued.Load(),
+		"delivered":       w.delivered.Load(),
+		"failed_attempts": w.failed.Load(),
+		"abandoned":       w.abandoned.Load(),
+		"disabled":        w.disabled.Load(),
+	}
+}
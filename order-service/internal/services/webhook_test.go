@@ -0,0 +1,509 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"order-service/internal/auth"
+	"order-service/internal/domain"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryWebhookRepo keeps endpoints, deliveries and attempts in slices
+// indexed by id - 1.
+type memoryWebhookRepo struct {
+	mu         sync.Mutex
+	endpoints  []*domain.WebhookEndpoint // nil once deleted
+	deliveries []domain.WebhookDelivery
+	attempts   []domain.WebhookAttempt
+}
+
+func (r *memoryWebhookRepo) CreateEndpoint(e *domain.WebhookEndpoint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e.ID = uint64(len(r.endpoints) + 1)
+	cp := *e
+	r.endpoints = append(r.endpoints, &cp)
+	return nil
+}
+
+func (r *memoryWebhookRepo) FindEndpoint(id uint64) (*domain.WebhookEndpoint, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if id == 0 || id > uint64(len(r.endpoints)) || r.endpoints[id-1] == nil {
+		return nil, nil
+	}
+	cp := *r.endpoints[id-1]
+	return &cp, nil
+}
+
+func (r *memoryWebhookRepo) ListEndpoints(customerID string) ([]domain.WebhookEndpoint, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []domain.WebhookEndpoint
+	for _, e := range r.endpoints {
+		if e != nil && (customerID == "" || e.CustomerID == customerID) {
+			out = append(out, *e)
+		}
+	}
+	return out, nil
+}
+
+func (r *memoryWebhookRepo) UpdateEndpoint(e *domain.WebhookEndpoint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cp := *e
+	r.endpoints[e.ID-1] = &cp
+	return nil
+}
+
+func (r *memoryWebhookRepo) DeleteEndpoint(id uint64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.endpoints[id-1] = nil
+	return nil
+}
+
+func (r *memoryWebhookRepo) FindSubscribers(customerID string) ([]domain.WebhookEndpoint, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []domain.WebhookEndpoint
+	for _, e := range r.endpoints {
+		if e != nil && e.Enabled && (e.CustomerID == "" || e.CustomerID == customerID) {
+			out = append(out, *e)
+		}
+	}
+	return out, nil
+}
+
+func (r *memoryWebhookRepo) Enqueue(deliveries []*domain.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, d := range deliveries {
+		d.ID = uint64(len(r.deliveries) + 1)
+		r.deliveries = append(r.deliveries, *d)
+	}
+	return nil
+}
+
+func (r *memoryWebhookRepo) ClaimDue(now, leaseUntil time.Time, limit int) ([]domain.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []domain.WebhookDelivery
+	for i := range r.deliveries {
+		d := &r.deliveries[i]
+		e := r.endpoints[d.EndpointID-1]
+		if len(out) == limit || d.Status != domain.WebhookPending || d.NextAttemptAt.After(now) || e == nil || !e.Enabled {
+			continue
+		}
+		out = append(out, *d)
+		d.NextAttemptAt = leaseUntil
+	}
+	return out, nil
+}
+
+func (r *memoryWebhookRepo) RecordAttempt(d *domain.WebhookDelivery, a *domain.WebhookAttempt, succeeded bool) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	a.ID = uint64(len(r.attempts) + 1)
+	a.DeliveryID = d.ID
+	r.attempts = append(r.attempts, *a)
+	r.deliveries[d.ID-1] = *d
+	e := r.endpoints[d.EndpointID-1]
+	if succeeded {
+		e.ConsecutiveFailures = 0
+	} else {
+		e.ConsecutiveFailures++
+	}
+	return e.ConsecutiveFailures, nil
+}
+
+func (r *memoryWebhookRepo) DisableEndpoint(id uint64, at time.Time, reason string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e := r.endpoints[id-1]
+	e.Enabled = false
+	e.DisabledAt = &at
+	e.DisabledReason = reason
+	return nil
+}
+
+func (r *memoryWebhookRepo) ListDeliveries(endpointID uint64, status domain.WebhookDeliveryStatus, beforeID uint64, limit int) ([]domain.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []domain.WebhookDelivery
+	for i := len(r.deliveries) - 1; i >= 0 && len(out) < limit; i-- {
+		d := r.deliveries[i]
+		if d.EndpointID == endpointID && (status == "" || d.Status == status) && (beforeID == 0 || d.ID < beforeID) {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+func (r *memoryWebhookRepo) FindDelivery(id uint64) (*domain.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if id == 0 || id > uint64(len(r.deliveries)) {
+		return nil, nil
+	}
+	d := r.deliveries[id-1]
+	return &d, nil
+}
+
+func (r *memoryWebhookRepo) FindAttempts(deliveryID uint64) ([]domain.WebhookAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []domain.WebhookAttempt
+	for _, a := range r.attempts {
+		if a.DeliveryID == deliveryID {
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+
+// webhookReceiver is a partner endpoint that checks signatures and answers
+// with the next queued status code, or 200 once they run out.
+type webhookReceiver struct {
+	*httptest.Server
+	secret string
+
+	mu       sync.Mutex
+	statuses []int
+	received []domain.WebhookPayload
+	badSigs  int
+}
+
+func newWebhookReceiver(t *testing.T, secret string, statuses ...int) *webhookReceiver {
+	rcv := &webhookReceiver{secret: secret, statuses: statuses}
+	rcv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		ts, _ := strconv.ParseInt(r.Header.Get(WebhookTimestampHeader), 10, 64)
+
+		rcv.mu.Lock()
+		defer rcv.mu.Unlock()
+		if r.Header.Get(WebhookSignatureHeader) != SignWebhook(rcv.secret, ts, body) {
+			rcv.badSigs++
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		status := http.StatusOK
+		if len(rcv.statuses) > 0 {
+			status, rcv.statuses = rcv.statuses[0], rcv.statuses[1:]
+		}
+		if status == http.StatusOK {
+			var p domain.WebhookPayload
+			if err := json.Unmarshal(body, &p); err == nil && p.Type == r.Header.Get(WebhookEventHeader) {
+				rcv.received = append(rcv.received, p)
+			}
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(rcv.Close)
+	return rcv
+}
+
+func (rcv *webhookReceiver) payloads() []domain.WebhookPayload {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	return append([]domain.WebhookPayload(nil), rcv.received...)
+}
+
+const testWebhookSecret = "whsec_0123456789abcdef"
+
+func testWebhookConfig() WebhookConfig {
+	cfg := DefaultWebhookConfig()
+	cfg.MaxAttempts = 4
+	cfg.BaseDelay = time.Minute
+	cfg.MaxDelay = 3 * time.Minute
+	cfg.DisableAfter = 3
+	// Receivers are httptest servers on 127.0.0.1
+	cfg.AllowPrivateNetworks = true
+	return cfg
+}
+
+func newTestWebhookService(cfg WebhookConfig) (*WebhookService, *memoryWebhookRepo, *stepClock) {
+	repo := &memoryWebhookRepo{}
+	clock := &stepClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	return NewWebhookService(repo, nil, cfg, clock), repo, clock
+}
+
+func createTestEndpoint(t *testing.T, w *WebhookService, ctx context.Context, url string, events ...string) *domain.WebhookEndpoint {
+	t.Helper()
+	secret := testWebhookSecret
+	created, err := w.CreateEndpoint(ctx, WebhookEndpointInput{URL: &url, EventTypes: events, Secret: &secret})
+	require.NoError(t, err)
+	return created.WebhookEndpoint
+}
+
+func deliverDue(t *testing.T, w *WebhookService) int {
+	t.Helper()
+	n, err := w.DeliverDue(context.Background())
+	require.NoError(t, err)
+	return n
+}
+
+func TestWebhookConfig_LeaseOutlastsAFullBatch(t *testing.T) {
+	cfg := DefaultWebhookConfig()
+	// 50 deliveries, 8 at a time, take up to 7 timeouts to send
+	assert.Equal(t, 80*time.Second, cfg.lease())
+
+	cfg.BatchSize, cfg.Concurrency = 8, 8
+	assert.Equal(t, 20*time.Second, cfg.lease())
+}
+
+func TestWebhook_OrderStatusChangesAreSignedAndFiltered(t *testing.T) {
+	s, _, broker := newMessageFlowService(t)
+	w, _, _ := newTestWebhookService(testWebhookConfig())
+	s.SetWebhookService(w)
+
+	confirmed := newWebhookReceiver(t, testWebhookSecret)
+	everything := newWebhookReceiver(t, testWebhookSecret)
+	createTestEndpoint(t, w, context.Background(), confirmed.URL, "order.confirmed")
+	createTestEndpoint(t, w, context.Background(), everything.URL, domain.WebhookEventAll)
+
+	ok, err := s.CreateOrder(context.Background(), 1, 1000)
+	require.NoError(t, err)
+	failed, err := s.CreateOrder(context.Background(), 2, 500)
+	require.NoError(t, err)
+	waitIdle(t, broker)
+	assert.Equal(t, 3, deliverDue(t, w))
+
+	got := confirmed.payloads()
+	require.Len(t, got, 1)
+	assert.Equal(t, "order.confirmed", got[0].Type)
+	assert.Equal(t, ok.ID, got[0].Data.Order.ID)
+	assert.Equal(t, domain.StatusConfirmed, got[0].Data.Order.Status)
+	assert.Equal(t, domain.StatusPending, got[0].Data.From)
+
+	types := map[uint64]string{}
+	for _, p := range everything.payloads() {
+		types[p.Data.Order.ID] = p.Type
+	}
+	assert.Equal(t, map[uint64]string{ok.ID: "order.confirmed", failed.ID: "order.failed"}, types)
+	assert.Zero(t, confirmed.badSigs+everything.badSigs)
+	assert.Zero(t, deliverDue(t, w), "delivered webhooks are not sent again")
+}
+
+func TestWebhook_CustomerEndpointsOnlyHearTheirOrders(t *testing.T) {
+	w, repo, _ := newTestWebhookService(testWebhookConfig())
+	rcv := newWebhookReceiver(t, testWebhookSecret)
+	e := createTestEndpoint(t, w, customerCtx("c1"), rcv.URL, "order.confirmed")
+	assert.Equal(t, "c1", e.CustomerID)
+
+	change := domain.OrderStatusChange{From: domain.StatusPending, To: domain.StatusConfirmed}
+	w.Notify(&domain.Order{ID: 1, CustomerID: "c2", Status: domain.StatusConfirmed}, change)
+	w.Notify(&domain.Order{ID: 2, CustomerID: "c1", Status: domain.StatusConfirmed}, change)
+	w.Notify(&domain.Order{ID: 3, CustomerID: "c1", Status: domain.StatusFailed}, domain.OrderStatusChange{To: domain.StatusFailed})
+
+	require.Len(t, repo.deliveries, 1)
+	assert.Equal(t, uint64(2), repo.deliveries[0].OrderID)
+}
+
+func TestWebhook_PayloadLeavesOutInternalFields(t *testing.T) {
+	w, repo, _ := newTestWebhookService(testWebhookConfig())
+	createTestEndpoint(t, w, context.Background(), "https://partner.example.com/hook", "order.confirmed")
+
+	o := &domain.Order{ID: 5, ProductId: 9, TotalPrice: 1000, Status: domain.StatusConfirmed, CreatedBy: "svc-checkout", PaymentID: "pay_123"}
+	w.Notify(o, domain.OrderStatusChange{From: domain.StatusPending, To: domain.StatusConfirmed})
+
+	require.Len(t, repo.deliveries, 1)
+	body := repo.deliveries[0].Payload
+	assert.Contains(t, body, `"totalPrice":1000`)
+	assert.NotContains(t, body, "pay_123")
+	assert.NotContains(t, body, "svc-checkout")
+}
+
+func TestWebhook_RetriesWithBackoffUntilDelivered(t *testing.T) {
+	w, _, clock := newTestWebhookService(testWebhookConfig())
+	rcv := newWebhookReceiver(t, testWebhookSecret, http.StatusInternalServerError, http.StatusServiceUnavailable)
+	e := createTestEndpoint(t, w, context.Background(), rcv.URL, "order.confirmed")
+
+	w.Notify(&domain.Order{ID: 7, Status: domain.StatusConfirmed}, domain.OrderStatusChange{To: domain.StatusConfirmed})
+	assert.Equal(t, 1, deliverDue(t, w))
+
+	// The first retry waits BaseDelay, the second twice as long
+	clock.Advance(59 * time.Second)
+	assert.Zero(t, deliverDue(t, w))
+	clock.Advance(time.Second)
+	assert.Equal(t, 1, deliverDue(t, w))
+	clock.Advance(time.Minute)
+	assert.Zero(t, deliverDue(t, w))
+	clock.Advance(time.Minute)
+	assert.Equal(t, 1, deliverDue(t, w))
+	require.Len(t, rcv.payloads(), 1)
+
+	deliveries, err := w.ListDeliveries(context.Background(), e.ID, domain.WebhookDelivered, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	view, err := w.GetDelivery(context.Background(), e.ID, deliveries[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, view.Attempts)
+	require.Len(t, view.Log, 3)
+	assert.Equal(t, http.StatusInternalServerError, view.Log[0].StatusCode)
+	assert.Contains(t, view.Log[1].Error, "503")
+	assert.Equal(t, http.StatusOK, view.Log[2].StatusCode)
+
+	got, _ := w.GetEndpoint(context.Background(), e.ID)
+	assert.Zero(t, got.ConsecutiveFailures)
+	assert.True(t, got.Enabled)
+}
+
+func TestWebhook_GivesUpAfterMaxAttempts(t *testing.T) {
+	cfg := testWebhookConfig()
+	cfg.DisableAfter = 100
+	w, repo, clock := newTestWebhookService(cfg)
+	rcv := newWebhookReceiver(t, testWebhookSecret, 500, 500, 500, 500, 500)
+	createTestEndpoint(t, w, context.Background(), rcv.URL, "order.failed")
+
+	w.Notify(&domain.Order{ID: 7, Status: domain.StatusFailed}, domain.OrderStatusChange{To: domain.StatusFailed})
+	for i := 0; i < 6; i++ {
+		deliverDue(t, w)
+		clock.Advance(cfg.MaxDelay)
+	}
+
+	assert.Equal(t, domain.WebhookFailed, repo.deliveries[0].Status)
+	assert.Equal(t, cfg.MaxAttempts, repo.deliveries[0].Attempts)
+	assert.Len(t, repo.attempts, cfg.MaxAttempts)
+	assert.Equal(t, int64(1), w.GetStats()["abandoned"])
+}
+
+func TestWebhook_FailingEndpointIsDisabledAndResumesWhenEnabled(t *testing.T) {
+	cfg := testWebhookConfig()
+	w, repo, clock := newTestWebhookService(cfg)
+	rcv := newWebhookReceiver(t, testWebhookSecret, 500, 500, 500)
+	e := createTestEndpoint(t, w, context.Background(), rcv.URL, domain.WebhookEventAll)
+
+	for id := uint64(1); id <= 3; id++ {
+		w.Notify(&domain.Order{ID: id, Status: domain.StatusConfirmed}, domain.OrderStatusChange{To: domain.StatusConfirmed})
+	}
+	assert.Equal(t, 3, deliverDue(t, w))
+
+	got, _ := w.GetEndpoint(context.Background(), e.ID)
+	assert.False(t, got.Enabled)
+	assert.NotNil(t, got.DisabledAt)
+	assert.Equal(t, "3 consecutive failed deliveries", got.DisabledReason)
+
+	// Disabled endpoints get nothing new and their queue is held
+	w.Notify(&domain.Order{ID: 4, Status: domain.StatusConfirmed}, domain.OrderStatusChange{To: domain.StatusConfirmed})
+	assert.Len(t, repo.deliveries, 3)
+	clock.Advance(cfg.MaxDelay)
+	assert.Zero(t, deliverDue(t, w))
+
+	enabled := true
+	got, err := w.UpdateEndpoint(context.Background(), e.ID, WebhookEndpointInput{Enabled: &enabled})
+	require.NoError(t, err)
+	assert.Zero(t, got.ConsecutiveFailures)
+	assert.Nil(t, got.DisabledAt)
+	assert.Equal(t, 3, deliverDue(t, w))
+	assert.Len(t, rcv.payloads(), 3)
+}
+
+func TestWebhook_RejectsInternalDestinations(t *testing.T) {
+	w, repo, _ := newTestWebhookService(DefaultWebhookConfig())
+	ctx := context.Background()
+
+	for _, u := range []string{
+		"http://127.0.0.1:8080/hooks",
+		"http://localhost/hooks",
+		"http://[::1]/hooks",
+		"http://10.1.2.3/hooks",
+		"http://192.168.0.10/hooks",
+		"http://169.254.169.254/latest/meta-data",
+		"http://0.0.0.0/hooks",
+	} {
+		_, err := w.CreateEndpoint(ctx, WebhookEndpointInput{URL: &u, EventTypes: []string{"*"}})
+		assert.ErrorIs(t, err, ErrInvalidWebhook, u)
+	}
+
+	// An endpoint that got past validation, e.g. through a name that
+	// resolves to loopback, is still refused when dialled
+	rcv := newWebhookReceiver(t, testWebhookSecret)
+	require.NoError(t, repo.CreateEndpoint(&domain.WebhookEndpoint{URL: rcv.URL, EventTypes: []string{"*"}, Secret: testWebhookSecret, Enabled: true}))
+	w.Notify(&domain.Order{ID: 1, Status: domain.StatusConfirmed}, domain.OrderStatusChange{OrderID: 1, From: domain.StatusPending, To: domain.StatusConfirmed})
+	assert.Equal(t, 1, deliverDue(t, w))
+
+	assert.Empty(t, rcv.payloads())
+	d, err := repo.FindDelivery(1)
+	require.NoError(t, err)
+	assert.Contains(t, d.LastError, "is not allowed")
+}
+
+func TestWebhook_AnonymousCallersCannotManageEndpoints(t *testing.T) {
+	w, _, _ := newTestWebhookService(testWebhookConfig())
+	created := createTestEndpoint(t, w, context.Background(), "https://partner.example.com/hook", domain.WebhookEventAll)
+	anon := auth.WithAnonymous(context.Background())
+	str := func(s string) *string { return &s }
+
+	_, err := w.CreateEndpoint(anon, WebhookEndpointInput{URL: str("https://partner.example"), EventTypes: []string{"*"}})
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+	_, err = w.ListEndpoints(anon)
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+	_, err = w.GetEndpoint(anon, created.ID)
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+	_, err = w.UpdateEndpoint(anon, created.ID, WebhookEndpointInput{URL: str("https://attacker.example")})
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+	_, err = w.ListDeliveries(anon, created.ID, "", 0, 0)
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+	_, err = w.GetDelivery(anon, created.ID, 1)
+	assert.ErrorIs(t, err, ErrUnauthenticated)
+	assert.ErrorIs(t, w.DeleteEndpoint(anon, created.ID), ErrUnauthenticated)
+
+	_, err = w.GetEndpoint(context.Background(), created.ID)
+	assert.NoError(t, err, "the endpoint is untouched")
+}
+
+func TestWebhook_EndpointManagement(t *testing.T) {
+	w, _, _ := newTestWebhookService(testWebhookConfig())
+	ctx := context.Background()
+	str := func(s string) *string { return &s }
+
+	_, err := w.CreateEndpoint(ctx, WebhookEndpointInput{URL: str("ftp://partner.example"), EventTypes: []string{"order.confirmed"}})
+	assert.ErrorIs(t, err, ErrInvalidWebhook)
+	_, err = w.CreateEndpoint(ctx, WebhookEndpointInput{URL: str("https://partner.example"), EventTypes: []string{"order.shipped"}})
+	assert.ErrorIs(t, err, ErrInvalidWebhook)
+	_, err = w.CreateEndpoint(ctx, WebhookEndpointInput{URL: str("https://partner.example"), EventTypes: []string{"order.confirmed"}, Secret: str("short")})
+	assert.ErrorIs(t, err, ErrInvalidWebhook)
+	_, err = w.CreateEndpoint(customerCtx("c1"), WebhookEndpointInput{URL: str("https://partner.example"), EventTypes: []string{"*"}, CustomerID: str("c2")})
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	created, err := w.CreateEndpoint(customerCtx("c1"), WebhookEndpointInput{URL: str("https://partner.example/hooks"), EventTypes: []string{"order.confirmed"}})
+	require.NoError(t, err)
+	assert.Regexp(t, `^whsec_[0-9a-f]{48}$`, created.Secret)
+	body, _ := json.Marshal(created.WebhookEndpoint)
+	assert.NotContains(t, string(body), created.Secret, "the secret is only shown on creation")
+
+	_, err = w.GetEndpoint(customerCtx("c2"), created.ID)
+	assert.ErrorIs(t, err, ErrWebhookNotFound)
+	list, err := w.ListEndpoints(customerCtx("c2"))
+	require.NoError(t, err)
+	assert.Empty(t, list)
+	list, err = w.ListEndpoints(ctx)
+	require.NoError(t, err)
+	assert.Len(t, list, 1)
+
+	updated, err := w.UpdateEndpoint(customerCtx("c1"), created.ID, WebhookEndpointInput{EventTypes: []string{"order.failed", "order.refunded"}})
+	require.NoError(t, err)
+	assert.Equal(t, "https://partner.example/hooks", updated.URL)
+	assert.Equal(t, []string{"order.failed", "order.refunded"}, updated.EventTypes)
+
+	_, err = w.ListDeliveries(ctx, created.ID, "lost", 0, 0)
+	assert.ErrorIs(t, err, ErrInvalidWebhook)
+	_, err = w.GetDelivery(ctx, created.ID, 1)
+	assert.ErrorIs(t, err, ErrWebhookDeliveryNotFound)
+
+	assert.ErrorIs(t, w.DeleteEndpoint(customerCtx("c2"), created.ID), ErrWebhookNotFound)
+	require.NoError(t, w.DeleteEndpoint(customerCtx("c1"), created.ID))
+	_, err = w.GetEndpoint(ctx, created.ID)
+	assert.ErrorIs(t, err, ErrWebhookNotFound)
+}
@@ -16,20 +16,24 @@ async function bootstrap() {
     }),
   );
 
-  app.connectMicroservice<MicroserviceOptions>({
-    transport: Transport.RMQ,
-    options: {
-      urls: [process.env.RABBITMQ_URL],
-      queue: 'order_events_queue',
-      queueOptions: {
-        durable: true,
+  // A server binds one routing key, so the queue gets a second consumer
+  // for the stock.release compensation sent by order-service
+  for (const routingKey of ['order.*', 'stock.release']) {
+    app.connectMicroservice<MicroserviceOptions>({
+      transport: Transport.RMQ,
+      options: {
+        urls: [process.env.RABBITMQ_URL],
+        queue: 'order_events_queue',
+        queueOptions: {
+          durable: true,
+        },
+        exchange: 'order.exchange',
+        exchangeType: 'topic',
+        routingKey,
+        wildcards: true,
       },
-      exchange: 'order.exchange',
-      exchangeType: 'topic',
-      routingKey: 'order.*',
-      wildcards: true,
-    },
-  });
+    });
+  }
 
   await app.startAllMicroservices();
   await app.listen(3000);
@@ -3,7 +3,7 @@ import { ProductController } from './product.controller';
 import { ProductService } from '../services/product.service';
 import { ClientProxy } from '@nestjs/microservices';
 import { CreateProductDto } from '../dtos/create-product.dto';
-import { Logger } from '@nestjs/common';
+import { BadRequestException, Logger } from '@nestjs/common';
 
 describe('ProductController', () => {
   let controller: ProductController;
@@ -21,8 +21,10 @@ describe('ProductController', () => {
   beforeEach(async () => {
     const mockProductService = {
       findOne: jest.fn(),
+      findByIds: jest.fn(),
       create: jest.fn(),
       decrementQty: jest.fn(),
+      releaseQty: jest.fn(),
     };
 
     const mockClient = {
@@ -81,6 +83,25 @@ describe('ProductController', () => {
     });
   });
 
+  describe('findByIds', () => {
+    it('should parse ids and return matching products', async () => {
+      // Arrange
+      productService.findByIds.mockResolvedValue([mockProduct]);
+
+      // Act
+      const result = await controller.findByIds('1,2');
+
+      // Assert
+      expect(result).toEqual([mockProduct]);
+      expect(productService.findByIds).toHaveBeenCalledWith([1, 2]);
+    });
+
+    it('should reject malformed ids', () => {
+      expect(() => controller.findByIds('1,abc')).toThrow(BadRequestException);
+      expect(productService.findByIds).not.toHaveBeenCalled();
+    });
+  });
+
   describe('create', () => {
     it('should create a new product', async () => {
       // Arrange
@@ -119,6 +140,7 @@ describe('ProductController', () => {
 
       // Assert
       expect(productService.decrementQty).toHaveBeenCalledWith(
+        orderData.orderId,
         orderData.productId,
       );
       expect(client.emit).toHaveBeenCalledWith('order.qty_confirmed', {
@@ -138,6 +160,7 @@ describe('ProductController', () => {
 
       // Assert
       expect(productService.decrementQty).toHaveBeenCalledWith(
+        orderData.orderId,
         orderData.productId,
       );
       expect(client.emit).toHaveBeenCalledWith('order.qty_failed', {
@@ -150,6 +173,34 @@ describe('ProductController', () => {
     });
   });
 
+  describe('handleStockRelease', () => {
+    it('should give the reserved unit back', async () => {
+      // Arrange
+      productService.releaseQty.mockResolvedValue({ ...mockProduct, qty: 11 });
+
+      // Act
+      await controller.handleStockRelease({ orderId: 123, productId: 1 });
+
+      // Assert
+      expect(productService.releaseQty).toHaveBeenCalledWith(123);
+      expect(client.emit).not.toHaveBeenCalled();
+    });
+  });
+
+  describe('handleOrderCancelled', () => {
+    it('should release the stock reserved for the order', async () => {
+      // Arrange
+      productService.releaseQty.mockResolvedValue(null);
+
+      // Act
+      await controller.handleOrderCancelled({ orderId: 123 });
+
+      // Assert
+      expect(productService.releaseQty).toHaveBeenCalledWith(123);
+      expect(client.emit).not.toHaveBeenCalled();
+    });
+  });
+
   describe('handleAny', () => {
     it('should log wildcard pattern warnings', async () => {
       // Arrange
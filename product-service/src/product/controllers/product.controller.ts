@@ -8,6 +8,8 @@ import {
   Put,
   Logger,
   Inject,
+  Query,
+  BadRequestException,
 } from '@nestjs/common';
 import { ProductService } from '../services/product.service';
 import { Product } from '../domain/product';
@@ -29,6 +31,25 @@ export class ProductController {
     @Inject('PRODUCT_PUBLISHER') private readonly client: ClientProxy,
   ) {}
 
+  // Batch lookup used by order-service: GET /products?ids=1,2,3.
+  // Unknown ids are omitted from the response.
+  @Get()
+  findByIds(@Query('ids') ids?: string): Promise<Product[]> {
+    const parsed = (ids ?? '')
+      .split(',')
+      .filter((id) => id.trim() !== '')
+      .map((id) => Number(id));
+    if (parsed.some((id) => !Number.isInteger(id) || id <= 0)) {
+      throw new BadRequestException(
+        'ids must be a comma-separated list of positive integers',
+      );
+    }
+    if (parsed.length > 500) {
+      throw new BadRequestException('at most 500 ids per request');
+    }
+    return this.productService.findByIds(parsed);
+  }
+
   @Get(':id')
   findOne(@Param('id', ParseIntPipe) id: number): Promise<Product> {
     return this.productService.findOne(id);
@@ -46,7 +67,10 @@ export class ProductController {
       `Received order.created for order ${orderId}, product ${productId}`,
     );
 
-    const product = await this.productService.decrementQty(productId);
+    const product = await this.productService.decrementQty(
+      orderId,
+      productId,
+    );
 
     if (!product) {
       this.logger.log(`Order failed. Insufficient stock`);
@@ -62,6 +86,35 @@ export class ProductController {
     await this.client.emit('order.qty_confirmed', { orderId }).toPromise();
   }
 
+  // Sent by order-service when an order fails after order.created, e.g.
+  // because its payment was declined. Orders that reserved nothing are
+  // ignored.
+  @EventPattern('stock.release')
+  async handleStockRelease(@Payload() data: any) {
+    const { orderId, productId } = data;
+    this.logger.log(
+      `Received stock.release for order ${orderId}, product ${productId}`,
+    );
+
+    const product = await this.productService.releaseQty(orderId);
+    if (!product) {
+      this.logger.log(`Nothing to release for order ${orderId}`);
+    }
+  }
+
+  // A pending order was cancelled. If its order.created was handled
+  // first, the unit reserved for it goes back into stock.
+  @EventPattern('order.cancelled')
+  async handleOrderCancelled(@Payload() data: any) {
+    const { orderId } = data;
+    this.logger.log(`Received order.cancelled for order ${orderId}`);
+
+    const product = await this.productService.releaseQty(orderId);
+    if (!product) {
+      this.logger.log(`Nothing to release for order ${orderId}`);
+    }
+  }
+
   @EventPattern('*')
   async handleAny(@Payload() data: any, @Ctx() ctx: RmqContext) {
     this.logger.warn(`⚠️ Wildcard caught pattern: ${ctx.getPattern()}`);
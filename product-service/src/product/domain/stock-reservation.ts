@@ -0,0 +1,26 @@
+import {
+  Column,
+  CreateDateColumn,
+  Entity,
+  Index,
+  PrimaryGeneratedColumn,
+} from 'typeorm';
+
+// The unit of stock an order holds. There is at most one per order, so a
+// redelivered order.created or stock.release changes qty only once.
+@Entity('stock_reservations')
+export class StockReservation {
+  @PrimaryGeneratedColumn()
+  id: number;
+
+  @Index({ unique: true })
+  @Column()
+  orderId: number;
+
+  @Column()
+  productId: number;
+  @Column({ type: 'timestamp', nullable: true })
+  releasedAt: Date | null;
+  @CreateDateColumn({ type: 'timestamp' })
+  createdAt: Date;
+}
@@ -6,11 +6,12 @@ import { Redis } from './infra/redis';
 import { MessageBroker } from './infra/message-broker';
 import { TypeOrmModule } from '@nestjs/typeorm';
 import { Product } from './domain/product';
+import { StockReservation } from './domain/stock-reservation';
 import { ClientsModule, Transport } from '@nestjs/microservices';
 
 @Module({
   imports: [
-    TypeOrmModule.forFeature([Product]),
+    TypeOrmModule.forFeature([Product, StockReservation]),
     ClientsModule.register([
       {
         name: 'ORDER_EVENTS',
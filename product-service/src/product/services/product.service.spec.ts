@@ -2,17 +2,26 @@ import { Test, TestingModule } from '@nestjs/testing';
 import { getRepositoryToken } from '@nestjs/typeorm';
 import { CACHE_MANAGER } from '@nestjs/cache-manager';
 import { NotFoundException } from '@nestjs/common';
-import { Repository } from 'typeorm';
+import { In, QueryFailedError, Repository } from 'typeorm';
 import { Cache } from 'cache-manager';
 import { ClientProxy } from '@nestjs/microservices';
 import { ProductService } from './product.service';
 import { Product } from '../domain/product';
+import { StockReservation } from '../domain/stock-reservation';
 
 describe('ProductService', () => {
   let service: ProductService;
   let productRepo: jest.Mocked<Repository<Product>>;
   let cacheManager: jest.Mocked<Cache>;
   let client: jest.Mocked<ClientProxy>;
+  let manager: {
+    findOne: jest.Mock;
+    insert: jest.Mock;
+    increment: jest.Mock;
+    createQueryBuilder: jest.Mock;
+    transaction: jest.Mock;
+  };
+  let updateResult: jest.Mock;
 
   const mockProduct: Product = {
     id: 1,
@@ -23,10 +32,27 @@ describe('ProductService', () => {
   };
 
   beforeEach(async () => {
+    updateResult = jest.fn();
+    const queryBuilder = {
+      update: jest.fn().mockReturnThis(),
+      set: jest.fn().mockReturnThis(),
+      where: jest.fn().mockReturnThis(),
+      execute: updateResult,
+    };
+    manager = {
+      findOne: jest.fn(),
+      insert: jest.fn(),
+      increment: jest.fn(),
+      createQueryBuilder: jest.fn().mockReturnValue(queryBuilder),
+      transaction: jest.fn((work) => work(manager)),
+    };
+
     const mockProductRepo = {
       findOne: jest.fn(),
+      find: jest.fn(),
       create: jest.fn(),
       save: jest.fn(),
+      manager,
     };
 
     const mockCacheManager = {
@@ -117,6 +143,27 @@ describe('ProductService', () => {
     });
   });
 
+  describe('findByIds', () => {
+    it('should query all requested ids at once', async () => {
+      // Arrange
+      productRepo.find.mockResolvedValue([mockProduct]);
+
+      // Act
+      const result = await service.findByIds([1, 2]);
+
+      // Assert
+      expect(result).toEqual([mockProduct]);
+      expect(productRepo.find).toHaveBeenCalledWith({
+        where: { id: In([1, 2]) },
+      });
+    });
+
+    it('should not hit the database for an empty list', async () => {
+      await expect(service.findByIds([])).resolves.toEqual([]);
+      expect(productRepo.find).not.toHaveBeenCalled();
+    });
+  });
+
   describe('create', () => {
     it('should create and save product, then emit event', async () => {
       // Arrange
@@ -146,52 +193,104 @@ describe('ProductService', () => {
   });
 
   describe('decrementQty', () => {
-    it('should decrement quantity when product exists and has stock', async () => {
-      // Arrange
-      const productId = 1;
-      const productWithStock = { ...mockProduct, qty: 5 };
-      const updatedProduct = { ...productWithStock, qty: 4 };
+    const reservation = { orderId: 123, productId: 1, releasedAt: null };
 
-      productRepo.findOne.mockResolvedValue(productWithStock);
-      productRepo.save.mockResolvedValue(updatedProduct);
+    it('should reserve one unit for a new order', async () => {
+      // Arrange
+      manager.findOne
+        .mockResolvedValueOnce(null)
+        .mockResolvedValueOnce({ ...mockProduct, qty: 4 });
+      updateResult.mockResolvedValue({ affected: 1 });
 
       // Act
-      const result = await service.decrementQty(productId);
+      const result = await service.decrementQty(123, 1);
 
       // Assert
-      expect(productRepo.findOne).toHaveBeenCalledWith({
-        where: { id: productId },
+      expect(result?.qty).toBe(4);
+      expect(manager.insert).toHaveBeenCalledWith(StockReservation, {
+        orderId: 123,
+        productId: 1,
       });
-      expect(productRepo.save).toHaveBeenCalledWith(updatedProduct);
-      expect(result).toEqual(updatedProduct);
+    });
+
+    it('should not take another unit for a redelivered order', async () => {
+      // Arrange
+      manager.findOne
+        .mockResolvedValueOnce(reservation)
+        .mockResolvedValueOnce(reservation)
+        .mockResolvedValueOnce({ ...mockProduct, qty: 4 });
+
+      // Act
+      const result = await service.decrementQty(123, 1);
+
+      // Assert
       expect(result?.qty).toBe(4);
+      expect(manager.createQueryBuilder).not.toHaveBeenCalled();
+      expect(manager.insert).not.toHaveBeenCalled();
     });
 
-    it('should return null when product not found', async () => {
+    it('should confirm the reservation a concurrent delivery made', async () => {
       // Arrange
-      const productId = 999;
-      productRepo.findOne.mockResolvedValue(null);
+      const duplicate = new QueryFailedError('INSERT', [], {
+        code: 'ER_DUP_ENTRY',
+      } as unknown as Error);
+      manager.transaction.mockRejectedValueOnce(duplicate);
+      manager.findOne
+        .mockResolvedValueOnce(reservation)
+        .mockResolvedValueOnce({ ...mockProduct, qty: 4 });
+
+      // Act
+      const result = await service.decrementQty(123, 1);
+
+      // Assert
+      expect(result?.qty).toBe(4);
+    });
+
+    it('should return null when the product is missing or out of stock', async () => {
+      // Arrange
+      manager.findOne.mockResolvedValueOnce(null);
+      updateResult.mockResolvedValue({ affected: 0 });
 
       // Act
-      const result = await service.decrementQty(productId);
+      const result = await service.decrementQty(123, 1);
 
       // Assert
       expect(result).toBeNull();
-      expect(productRepo.save).not.toHaveBeenCalled();
+      expect(manager.insert).not.toHaveBeenCalled();
     });
+  });
 
-    it('should return null when product quantity is zero', async () => {
+  describe('releaseQty', () => {
+    it('should give back the unit the order reserved', async () => {
       // Arrange
-      const productId = 1;
-      const outOfStockProduct = { ...mockProduct, qty: 0 };
-      productRepo.findOne.mockResolvedValue(outOfStockProduct);
+      updateResult.mockResolvedValue({ affected: 1 });
+      manager.findOne
+        .mockResolvedValueOnce({ orderId: 123, productId: 1 })
+        .mockResolvedValueOnce({ ...mockProduct, qty: 5 });
+
+      // Act
+      const result = await service.releaseQty(123);
+
+      // Assert
+      expect(result?.qty).toBe(5);
+      expect(manager.increment).toHaveBeenCalledWith(
+        Product,
+        { id: 1 },
+        'qty',
+        1,
+      );
+    });
+
+    it('should ignore orders that reserved nothing or were released', async () => {
+      // Arrange
+      updateResult.mockResolvedValue({ affected: 0 });
 
       // Act
-      const result = await service.decrementQty(productId);
+      const result = await service.releaseQty(123);
 
       // Assert
       expect(result).toBeNull();
-      expect(productRepo.save).not.toHaveBeenCalled();
+      expect(manager.increment).not.toHaveBeenCalled();
     });
   });
 });
@@ -1,7 +1,8 @@
 import { Inject, Injectable, NotFoundException } from '@nestjs/common';
 import { InjectRepository } from '@nestjs/typeorm';
 import { Product } from '../domain/product';
-import { Repository } from 'typeorm';
+import { StockReservation } from '../domain/stock-reservation';
+import { EntityManager, In, QueryFailedError, Repository } from 'typeorm';
 import { CACHE_MANAGER } from '@nestjs/cache-manager';
 import { Cache } from 'cache-manager';
 import { ClientProxy } from '@nestjs/microservices';
@@ -28,6 +29,11 @@ export class ProductService {
     return product;
   }
 
+  async findByIds(ids: number[]): Promise<Product[]> {
+    if (ids.length === 0) return [];
+    return this.productRepo.find({ where: { id: In(ids) } });
+  }
+
   async create(data: Partial<Product>): Promise<Product> {
     const product = this.productRepo.create(data);
     const saved = await this.productRepo.save(product);
@@ -36,14 +42,88 @@ export class ProductService {
     return saved;
   }
 
-  async decrementQty(productId: number): Promise<Product | null> {
-    const product = await this.productRepo.findOne({
-      where: { id: productId },
+  // Reserves one unit for an order. The reservation is stored in the same
+  // transaction as the decrement and is unique per order, so a redelivered
+  // order.created confirms the existing reservation instead of taking
+  // another unit. Returns null when nothing could be reserved.
+  async decrementQty(
+    orderId: number,
+    productId: number,
+  ): Promise<Product | null> {
+    try {
+      return await this.productRepo.manager.transaction((manager) =>
+        this.reserve(manager, orderId, productId),
+      );
+    } catch (err) {
+      if (!isDuplicateEntry(err)) throw err;
+      // A concurrent delivery of the same order reserved first
+      return this.reserved(this.productRepo.manager, orderId);
+    }
+  }
+
+  private async reserve(
+    manager: EntityManager,
+    orderId: number,
+    productId: number,
+  ): Promise<Product | null> {
+    const existing = await manager.findOne(StockReservation, {
+      where: { orderId },
     });
-    if (!product) return null;
-    if (product.qty <= 0) return null;
+    if (existing) return this.reserved(manager, orderId);
+
+    const result = await manager
+      .createQueryBuilder()
+      .update(Product)
+      .set({ qty: () => 'qty - 1' })
+      .where('id = :productId AND qty > 0', { productId })
+      .execute();
+    if (!result.affected) return null;
 
-    product.qty -= 1;
-    return this.productRepo.save(product);
+    await manager.insert(StockReservation, { orderId, productId });
+    return manager.findOne(Product, { where: { id: productId } });
   }
+
+  // The product still reserved for an order, or null when the order has
+  // none or it was already released.
+  private async reserved(
+    manager: EntityManager,
+    orderId: number,
+  ): Promise<Product | null> {
+    const reservation = await manager.findOne(StockReservation, {
+      where: { orderId },
+    });
+    if (!reservation || reservation.releasedAt) return null;
+    return manager.findOne(Product, { where: { id: reservation.productId } });
+  }
+
+  // Gives back the unit reserved for an order. The reservation is marked
+  // released with a conditional update, so of several deliveries of the
+  // same release only one increments qty, and an order that never
+  // reserved anything changes nothing. Returns null in both cases.
+  async releaseQty(orderId: number): Promise<Product | null> {
+    return this.productRepo.manager.transaction(async (manager) => {
+      const result = await manager
+        .createQueryBuilder()
+        .update(StockReservation)
+        .set({ releasedAt: () => 'CURRENT_TIMESTAMP' })
+        .where('orderId = :orderId AND releasedAt IS NULL', { orderId })
+        .execute();
+      if (!result.affected) return null;
+
+      const reservation = await manager.findOne(StockReservation, {
+        where: { orderId },
+      });
+      await manager.increment(Product, { id: reservation.productId }, 'qty', 1);
+      return manager.findOne(Product, {
+        where: { id: reservation.productId },
+      });
+    });
+  }
+}
+
+function isDuplicateEntry(err: unknown): boolean {
+  return (
+    err instanceof QueryFailedError &&
+    (err.driverError as { code?: string })?.code === 'ER_DUP_ENTRY'
+  );
 }